@@ -0,0 +1,260 @@
+package htmlsanitizer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PolicyBuilder builds an AllowList step by step, e.g.
+//
+//	NewPolicy().
+//		AllowElements("p", "a").
+//		AllowAttrs("href").AsURL().OnElements("a").
+//		AllowAttrs("class").Matching(re).Globally().
+//		Build()
+//
+// The names are validated as soon as they are added, and the first error is
+// returned by AllowList or Build. A PolicyBuilder is not safe for concurrent
+// use.
+type PolicyBuilder struct {
+	list *AllowList
+	err  error
+
+	// required attributes by the element names, which are set once the
+	// AllowList is built, so they are not relaxed by the Merge
+	require map[string][]string
+}
+
+// AttrBuilder allows some attributes, either on some elements or globally.
+type AttrBuilder struct {
+	b        *PolicyBuilder
+	names    []string
+	urlAttr  bool
+	pattern  *regexp.Regexp
+	required bool
+}
+
+// NewPolicy creates a new PolicyBuilder, which allows nothing. The content of
+// the same NonHTMLTags as the DefaultAllowList is removed as a whole.
+func NewPolicy() *PolicyBuilder {
+	list := new(AllowList)
+	for _, tag := range DefaultAllowList.NonHTMLTags {
+		list.NonHTMLTags = append(list.NonHTMLTags, tag.Clone())
+	}
+
+	return &PolicyBuilder{list: list}
+}
+
+// fail keeps the first error.
+func (b *PolicyBuilder) fail(field, value, reason string) {
+	if b.err == nil {
+		b.err = &PolicyError{Field: field, Value: value, Reason: reason}
+	}
+}
+
+// validElements checks whether the names can be allowed as elements.
+func (b *PolicyBuilder) validElements(field string, names []string) bool {
+	for _, name := range names {
+		reason := validName(name)
+		for _, tag := range b.list.NonHTMLTags {
+			if reason == "" && tag.Name == name {
+				reason = "element with non-HTML content is not allowed"
+			}
+		}
+		if reason != "" {
+			b.fail(field, name, reason)
+			return false
+		}
+	}
+
+	return b.err == nil
+}
+
+// AllowElements allows the elements without any attributes. The names must
+// be lowercase.
+func (b *PolicyBuilder) AllowElements(names ...string) *PolicyBuilder {
+	if !b.validElements("AllowElements", names) {
+		return b
+	}
+
+	other := new(AllowList)
+	for _, name := range names {
+		other.Tags = append(other.Tags, &Tag{Name: name})
+	}
+	b.list = b.list.Merge(other)
+	return b
+}
+
+// AllowNamespacePrefixes allows the namespace prefixes in the names of the
+// elements and attributes, such as epub for epub:type, see
+// AllowList.NamespacePrefixes.
+func (b *PolicyBuilder) AllowNamespacePrefixes(prefixes ...string) *PolicyBuilder {
+	for _, prefix := range prefixes {
+		reason := validName(prefix)
+		if reason == "" && strings.IndexByte(prefix, ':') >= 0 {
+			reason = "illegal character in name"
+		}
+		if reason != "" {
+			b.fail("AllowNamespacePrefixes", prefix, reason)
+			return b
+		}
+	}
+
+	b.list = b.list.Merge(&AllowList{NamespacePrefixes: prefixes})
+	return b
+}
+
+// AllowAttrs starts to allow the attributes, which must be lowercase. The
+// attributes take effect once OnElements or Globally is called.
+func (b *PolicyBuilder) AllowAttrs(names ...string) *AttrBuilder {
+	if b.err == nil {
+		for _, name := range names {
+			reason := validName(name)
+			if reason == "" && strings.HasPrefix(name, "on") {
+				reason = "event handler attributes are not allowed"
+			}
+			if reason != "" {
+				b.fail("AllowAttrs", name, reason)
+				break
+			}
+		}
+	}
+
+	return &AttrBuilder{b: b, names: names}
+}
+
+// AsURL makes the attributes URL-related ones, of which the values are
+// sanitized by the URLSanitizer.
+func (a *AttrBuilder) AsURL() *AttrBuilder {
+	a.urlAttr = true
+	return a
+}
+
+// Matching restricts the values of the attributes with the pattern, see
+// Tag.AttrPattern.
+func (a *AttrBuilder) Matching(pattern *regexp.Regexp) *AttrBuilder {
+	if pattern == nil {
+		a.b.fail("Matching", strings.Join(a.names, ","), "pattern must not be nil")
+	}
+	a.pattern = pattern
+	return a
+}
+
+// Required makes the attributes required, the elements without any of them
+// are removed, as well as their end tags, see Tag.Require. Combined with
+// Matching, an element is allowed only if the attributes have some values,
+// e.g.
+//
+//	AllowAttrs("type").Matching(regexp.MustCompile(`^button$`)).Required().OnElements("button")
+//
+// The attributes can not be required globally.
+func (a *AttrBuilder) Required() *AttrBuilder {
+	a.required = true
+	return a
+}
+
+// valid checks the unsafe combinations of the attributes.
+func (a *AttrBuilder) valid(field string) bool {
+	for _, name := range a.names {
+		switch {
+		case a.b.err != nil:
+			return false
+		case urlAttrNames[name] && !a.urlAttr:
+			a.b.fail(field, name, "URL-related attribute must be allowed AsURL")
+		case name == "style" && a.pattern == nil:
+			a.b.fail(field, name, "style attribute must be Matching a pattern")
+		}
+	}
+
+	return a.b.err == nil
+}
+
+// patterns returns the pattern of each attribute, if any.
+func (a *AttrBuilder) patterns() map[string]*regexp.Regexp {
+	var ret map[string]*regexp.Regexp
+	for _, name := range a.names {
+		setPattern(&ret, name, a.pattern)
+	}
+	return ret
+}
+
+// OnElements allows the attributes on the elements, which are allowed as
+// well. If an attribute is allowed more than once on an element, its value
+// matches either of the patterns, if any.
+func (a *AttrBuilder) OnElements(names ...string) *PolicyBuilder {
+	b := a.b
+	if !a.valid("OnElements") || !b.validElements("OnElements", names) {
+		return b
+	}
+
+	other := new(AllowList)
+	for _, name := range names {
+		tag := &Tag{Name: name, AttrPattern: a.patterns()}
+		if a.urlAttr {
+			tag.URLAttr = a.names
+		} else {
+			tag.Attr = a.names
+		}
+		other.Tags = append(other.Tags, tag)
+
+		if a.required {
+			if b.require == nil {
+				b.require = make(map[string][]string)
+			}
+			b.require[name] = unionStrings(b.require[name], a.names)
+		}
+	}
+	b.list = b.list.Merge(other)
+	return b
+}
+
+// Globally allows the attributes on all the allowed elements. URL-related
+// attributes are never allowed globally.
+func (a *AttrBuilder) Globally() *PolicyBuilder {
+	b := a.b
+	if a.urlAttr && len(a.names) > 0 {
+		b.fail("Globally", a.names[0], "URL-related attributes are not allowed globally")
+	}
+	if a.required && len(a.names) > 0 {
+		b.fail("Globally", a.names[0], "attributes can not be required globally")
+	}
+	if !a.valid("Globally") {
+		return b
+	}
+
+	b.list = b.list.Merge(&AllowList{
+		GlobalAttr:        a.names,
+		GlobalAttrPattern: a.patterns(),
+	})
+	return b
+}
+
+// AllowList returns the validated AllowList built by b.
+func (b *PolicyBuilder) AllowList() (*AllowList, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	list := b.list.Clone()
+	for _, tag := range list.Tags {
+		tag.Require = append([]string(nil), b.require[tag.Name]...)
+	}
+	if err := list.Validate(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Build returns a new HTMLSanitizer using the validated AllowList built by b,
+// which is compiled into a Policy.
+func (b *PolicyBuilder) Build() (*HTMLSanitizer, error) {
+	list, err := b.AllowList()
+	if err != nil {
+		return nil, err
+	}
+
+	f := &HTMLSanitizer{AllowList: list}
+	f.SetPolicy(list.Compile())
+	return f, nil
+}
@@ -0,0 +1,295 @@
+package htmlsanitizer_test
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleNewPolicy() {
+	sanitizer, err := htmlsanitizer.NewPolicy().
+		AllowElements("p", "a").
+		AllowAttrs("href").AsURL().OnElements("a").
+		AllowAttrs("class").Matching(regexp.MustCompile(`^[a-z-]+$`)).Globally().
+		Build()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	output, _ := sanitizer.SanitizeString(`<p class="intro x"><a href="/" class="link" title="x">link</a><b>bold</b></p>`)
+	fmt.Println(output)
+	// Output:
+	// <p><a href="/" class="link">link</a>bold</p>
+}
+
+func TestPolicyBuilderErrors(t *testing.T) {
+	re := regexp.MustCompile(`^[a-z]+$`)
+	cases := []struct {
+		name   string
+		build  func() *htmlsanitizer.PolicyBuilder
+		field  string
+		value  string
+		reason string
+	}{
+		{
+			name:   "uppercase element",
+			build:  func() *htmlsanitizer.PolicyBuilder { return htmlsanitizer.NewPolicy().AllowElements("p", "B") },
+			field:  "AllowElements",
+			value:  "B",
+			reason: "name must be lowercase",
+		},
+		{
+			name:   "illegal element",
+			build:  func() *htmlsanitizer.PolicyBuilder { return htmlsanitizer.NewPolicy().AllowElements("a b") },
+			field:  "AllowElements",
+			value:  "a b",
+			reason: "illegal character in name",
+		},
+		{
+			name:   "script element",
+			build:  func() *htmlsanitizer.PolicyBuilder { return htmlsanitizer.NewPolicy().AllowElements("script") },
+			field:  "AllowElements",
+			value:  "script",
+			reason: "element with non-HTML content is not allowed",
+		},
+		{
+			name: "style on elements",
+			build: func() *htmlsanitizer.PolicyBuilder {
+				return htmlsanitizer.NewPolicy().AllowAttrs("title").OnElements("style")
+			},
+			field:  "OnElements",
+			value:  "style",
+			reason: "element with non-HTML content is not allowed",
+		},
+		{
+			name: "uppercase attribute",
+			build: func() *htmlsanitizer.PolicyBuilder {
+				return htmlsanitizer.NewPolicy().AllowAttrs("Title").OnElements("p")
+			},
+			field:  "AllowAttrs",
+			value:  "Title",
+			reason: "name must be lowercase",
+		},
+		{
+			name: "event handler",
+			build: func() *htmlsanitizer.PolicyBuilder {
+				return htmlsanitizer.NewPolicy().AllowAttrs("onclick").Matching(re).Globally()
+			},
+			field:  "AllowAttrs",
+			value:  "onclick",
+			reason: "event handler attributes are not allowed",
+		},
+		{
+			name: "URL attribute globally",
+			build: func() *htmlsanitizer.PolicyBuilder {
+				return htmlsanitizer.NewPolicy().AllowAttrs("href").AsURL().Globally()
+			},
+			field:  "Globally",
+			value:  "href",
+			reason: "URL-related attributes are not allowed globally",
+		},
+		{
+			name: "URL attribute not AsURL",
+			build: func() *htmlsanitizer.PolicyBuilder {
+				return htmlsanitizer.NewPolicy().AllowAttrs("alt", "src").OnElements("img")
+			},
+			field:  "OnElements",
+			value:  "src",
+			reason: "URL-related attribute must be allowed AsURL",
+		},
+		{
+			name: "style attribute without pattern",
+			build: func() *htmlsanitizer.PolicyBuilder {
+				return htmlsanitizer.NewPolicy().AllowAttrs("style").OnElements("p")
+			},
+			field:  "OnElements",
+			value:  "style",
+			reason: "style attribute must be Matching a pattern",
+		},
+		{
+			name: "nil pattern",
+			build: func() *htmlsanitizer.PolicyBuilder {
+				return htmlsanitizer.NewPolicy().AllowAttrs("class").Matching(nil).Globally()
+			},
+			field:  "Matching",
+			value:  "class",
+			reason: "pattern must not be nil",
+		},
+		{
+			name: "required globally",
+			build: func() *htmlsanitizer.PolicyBuilder {
+				return htmlsanitizer.NewPolicy().AllowAttrs("class").Required().Globally()
+			},
+			field:  "Globally",
+			value:  "class",
+			reason: "attributes can not be required globally",
+		},
+		{
+			name: "namespace prefix not allowed",
+			build: func() *htmlsanitizer.PolicyBuilder {
+				return htmlsanitizer.NewPolicy().AllowAttrs("epub:type").OnElements("p")
+			},
+			field:  "tags[0].attr[0]",
+			value:  "epub:type",
+			reason: "namespace prefix not allowed",
+		},
+		{
+			name: "namespaced prefix",
+			build: func() *htmlsanitizer.PolicyBuilder {
+				return htmlsanitizer.NewPolicy().AllowNamespacePrefixes("epub:x")
+			},
+			field:  "AllowNamespacePrefixes",
+			value:  "epub:x",
+			reason: "illegal character in name",
+		},
+		{
+			name: "first error wins",
+			build: func() *htmlsanitizer.PolicyBuilder {
+				return htmlsanitizer.NewPolicy().AllowElements("P").AllowElements("script")
+			},
+			field:  "AllowElements",
+			value:  "P",
+			reason: "name must be lowercase",
+		},
+	}
+
+	for _, c := range cases {
+		_, err := c.build().Build()
+		var policyErr *htmlsanitizer.PolicyError
+		if !errors.As(err, &policyErr) {
+			t.Errorf("%s: expect a PolicyError, got %v", c.name, err)
+			continue
+		}
+		if policyErr.Field != c.field || policyErr.Value != c.value || policyErr.Reason != c.reason {
+			t.Errorf("%s: unexpected error %s", c.name, err)
+		}
+	}
+}
+
+func TestPolicyBuilder(t *testing.T) {
+	b := htmlsanitizer.NewPolicy().
+		AllowElements("p", "img", "span").
+		AllowAttrs("src").AsURL().Matching(regexp.MustCompile(`^https://img\.example\.com/`)).OnElements("img").
+		AllowAttrs("alt").OnElements("img").
+		AllowAttrs("style").Matching(regexp.MustCompile(`^color: *(red|blue);?$`)).OnElements("span").
+		AllowAttrs("dir").Matching(regexp.MustCompile(`^(ltr|rtl)$`)).Globally()
+	sanitizer, err := b.Build()
+	if err != nil {
+		t.Fatalf("unable to Build err: %s", err)
+	}
+
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `<img src="https://img.example.com/x.png" alt="x">`,
+			expected: `<img src="https://img.example.com/x.png" alt="x">`,
+		},
+		{
+			input:    `<img src="https://example.com/x.png" alt="x">`,
+			expected: `<img alt="x">`,
+		},
+		{
+			// the pattern is matched against the sanitized URL
+			input:    `<img src="javascript:alert(1)//https://img.example.com/">`,
+			expected: `<img>`,
+		},
+		{
+			input:    `<span style="color: red">x</span><span style="background: url(x)">y</span>`,
+			expected: `<span style="color: red">x</span><span>y</span>`,
+		},
+		{
+			// the value is unescaped before matched
+			input:    `<span style="color: &#114;ed">x</span><span style="color: red&#59;&#10;x">y</span>`,
+			expected: `<span style="color: &#114;ed">x</span><span>y</span>`,
+		},
+		{
+			input:    `<p dir="rtl" style="color: red">x</p><p dir="auto">y</p>`,
+			expected: `<p dir="rtl">x</p><p>y</p>`,
+		},
+		{
+			input:    `<script>alert(1)</script><b>z</b>`,
+			expected: `z`,
+		},
+	}
+
+	for _, c := range cases {
+		ret, err := sanitizer.SanitizeString(c.input)
+		if err != nil {
+			t.Errorf("unable to SanitizeString err: %s", err)
+		}
+		if ret != c.expected {
+			t.Errorf("expect %#v, got %#v", c.expected, ret)
+		}
+
+		// the same as the uncompiled AllowList
+		list, _ := b.AllowList()
+		ret, _ = (&htmlsanitizer.HTMLSanitizer{AllowList: list}).SanitizeString(c.input)
+		if ret != c.expected {
+			t.Errorf("expect %#v with the AllowList, got %#v", c.expected, ret)
+		}
+	}
+}
+
+func TestPolicyBuilderMerge(t *testing.T) {
+	list, err := htmlsanitizer.NewPolicy().
+		AllowAttrs("class").Matching(regexp.MustCompile(`^a$`)).OnElements("p").
+		AllowAttrs("class").Matching(regexp.MustCompile(`^b$`)).OnElements("p").
+		AllowAttrs("title").Matching(regexp.MustCompile(`^c$`)).OnElements("p").
+		AllowAttrs("title").OnElements("p").
+		AllowList()
+	if err != nil {
+		t.Fatalf("unable to build AllowList err: %s", err)
+	}
+
+	sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: list}
+	data := `<p class="a">1</p><p class="b">2</p><p class="c">3</p><p title="x">4</p>`
+	expected := `<p class="a">1</p><p class="b">2</p><p>3</p><p title="x">4</p>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+}
+
+func TestPolicyBuilderNamespacePrefixes(t *testing.T) {
+	sanitizer, err := htmlsanitizer.NewPolicy().
+		AllowElements("p").
+		AllowAttrs("epub:type").Matching(regexp.MustCompile(`^(footnote|noteref)$`)).OnElements("p").
+		AllowAttrs("xml:lang").Globally().
+		AllowNamespacePrefixes("epub").
+		Build()
+	if err != nil {
+		t.Fatalf("unable to Build err: %s", err)
+	}
+
+	data := `<p epub:type="footnote" xml:lang="en">1</p><p epub:type="x" epub:role="x">2</p>`
+	expected := `<p epub:type="footnote" xml:lang="en">1</p><p>2</p>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+}
+
+func TestPolicyBuilderRequired(t *testing.T) {
+	sanitizer, err := htmlsanitizer.NewPolicy().
+		AllowAttrs("type").Matching(regexp.MustCompile(`^button$`)).Required().OnElements("button").
+		AllowAttrs("type", "disabled").Matching(regexp.MustCompile(`^(checkbox|disabled)?$`)).Required().OnElements("input").
+		AllowAttrs("checked").OnElements("input").
+		// allowing the elements again does not relax the requirements
+		AllowElements("button", "input").
+		AllowAttrs("class").Globally().
+		Build()
+	if err != nil {
+		t.Fatalf("unable to Build err: %s", err)
+	}
+
+	data := `<button type="button" class="x">1</button><button type="submit">2</button><button class="x">3</button>` +
+		`<input type="checkbox" disabled checked><input type="checkbox" checked>`
+	expected := `<button type="button" class="x">1</button>23<input type="checkbox" disabled checked>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+}
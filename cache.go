@@ -0,0 +1,196 @@
+package htmlsanitizer
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+)
+
+// cacheEntryOverhead is the estimated memory used by each cache entry
+// besides its sanitized result.
+const cacheEntryOverhead = 128
+
+// CacheStats is the statistics of a Cache.
+type CacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+
+	// Entries is the number of the cached results.
+	Entries int
+
+	// Bytes is the estimated memory used by the cached results.
+	Bytes int64
+}
+
+type cacheEntry struct {
+	key    [sha256.Size]byte
+	result string
+}
+
+// Cache is a bounded LRU cache of the sanitized results of a HTMLSanitizer,
+// which is keyed by the hash of the input and the Fingerprint of the
+// sanitizer. A Cache is safe for concurrent use.
+//
+// Only the sanitizer with a Policy is cached, see HTMLSanitizer.SetPolicy,
+// whose fingerprint is computed only once. Any change to the Policy, the
+// URLSanitizerName, the StyleSanitizerName, the Limits or the Document takes
+// effect immediately, and all the cached results are dropped. As the
+// Fingerprint, a custom URLSanitizer or StyleSanitizer is identified by its
+// name only, so the name must be changed whenever the func behaves
+// differently.
+//
+// The cache is bypassed if there is no Policy, a custom URLSanitizer or
+// StyleSanitizer has no name, or the OnViolation or the Hook of the
+// sanitizer is set, e.g. by UGCPolicy and EmbedPolicy. Results with errors
+// are never cached.
+type Cache struct {
+	f        *HTMLSanitizer
+	maxBytes int64
+
+	mu    sync.Mutex
+	lru   *list.List // of *cacheEntry, the most recently used first
+	items map[[sha256.Size]byte]*list.Element
+	stats CacheStats
+
+	// fingerprint of the configuration of the cached results
+	fingerprint [sha256.Size]byte
+}
+
+// NewCache returns a new Cache of the sanitized results of f, using at most
+// maxBytes of memory. The results are cached only while f has a Policy.
+func (f *HTMLSanitizer) NewCache(maxBytes int64) *Cache {
+	return &Cache{
+		f:        f,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		items:    make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// cacheable checks whether the results of f can be cached.
+func (f *HTMLSanitizer) cacheable() bool {
+	switch {
+	case f.Policy() == nil, f.OnViolation != nil, f.Hook != nil:
+		return false
+	case f.URLSanitizer != nil && f.URLSanitizerName == "":
+		return false
+	case f.StyleSanitizer != nil && f.StyleSanitizerName == "":
+		return false
+	}
+	return true
+}
+
+// SanitizeString sanitizes the HTML string, or returns the cached result.
+func (c *Cache) SanitizeString(data string) (string, error) {
+	if !c.f.cacheable() {
+		return c.f.SanitizeString(data)
+	}
+
+	fingerprint := c.f.fingerprint()
+	key := cacheKey(fingerprint, data)
+	if ret, ok := c.get(fingerprint, key); ok {
+		return ret, nil
+	}
+
+	ret, err := c.f.SanitizeString(data)
+	if err != nil {
+		return ret, err
+	}
+
+	c.add(fingerprint, key, ret)
+	return ret, nil
+}
+
+// Sanitize sanitizes the HTML data, or returns a copy of the cached result.
+func (c *Cache) Sanitize(data []byte) ([]byte, error) {
+	ret, err := c.SanitizeString(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(ret), nil
+}
+
+// Stats returns the statistics of c.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stats
+}
+
+// Purge drops all the cached results.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.purge()
+}
+
+func (c *Cache) purge() {
+	c.lru.Init()
+	c.items = make(map[[sha256.Size]byte]*list.Element)
+	c.stats.Entries = 0
+	c.stats.Bytes = 0
+}
+
+// get looks up the cached result, and drops all the cached results if the
+// configuration has changed.
+func (c *Cache) get(fingerprint, key [sha256.Size]byte) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if fingerprint != c.fingerprint {
+		c.purge()
+		c.fingerprint = fingerprint
+	}
+
+	e, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return "", false
+	}
+
+	c.stats.Hits++
+	c.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry).result, true
+}
+
+func (c *Cache) add(fingerprint, key [sha256.Size]byte, result string) {
+	size := int64(len(result)) + cacheEntryOverhead
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// the configuration has changed during sanitizing
+	if fingerprint != c.fingerprint {
+		return
+	}
+	if _, ok := c.items[key]; ok {
+		return
+	}
+
+	for c.stats.Bytes+size > c.maxBytes {
+		e := c.lru.Back()
+		c.lru.Remove(e)
+		delete(c.items, e.Value.(*cacheEntry).key)
+		c.stats.Entries--
+		c.stats.Bytes -= int64(len(e.Value.(*cacheEntry).result)) + cacheEntryOverhead
+		c.stats.Evictions++
+	}
+
+	c.items[key] = c.lru.PushFront(&cacheEntry{key: key, result: result})
+	c.stats.Entries++
+	c.stats.Bytes += size
+}
+
+func cacheKey(fingerprint [sha256.Size]byte, data string) (key [sha256.Size]byte) {
+	h := sha256.New()
+	_, _ = h.Write(fingerprint[:])
+	_, _ = h.Write([]byte(data))
+	h.Sum(key[:0])
+	return
+}
@@ -0,0 +1,240 @@
+package htmlsanitizer_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleCache() {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.SetPolicy(sanitizer.Compile())
+	cache := sanitizer.NewCache(64 << 20)
+
+	for i := 0; i < 3; i++ {
+		output, _ := cache.SanitizeString(`<a href="javascript:alert(1)">link</a>`)
+		fmt.Println(output)
+	}
+
+	stats := cache.Stats()
+	fmt.Println(stats.Hits, stats.Misses, stats.Entries)
+	// Output:
+	// <a>link</a>
+	// <a>link</a>
+	// <a>link</a>
+	// 2 1 1
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.SetPolicy(sanitizer.Compile())
+	cache := sanitizer.NewCache(1 << 20)
+	data := `<p class="x"><a href="http://example.com/">link</a></p>`
+
+	check := func(expected string, hits uint64) {
+		t.Helper()
+		ret, err := cache.SanitizeString(data)
+		if err != nil {
+			t.Errorf("unable to SanitizeString err: %s", err)
+		}
+		if ret != expected {
+			t.Errorf("expect %#v, got %#v", expected, ret)
+		}
+		if stats := cache.Stats(); stats.Hits != hits {
+			t.Errorf("expect %d hits, got %+v", hits, stats)
+		}
+	}
+
+	check(data, 0)
+	check(data, 1)
+
+	// Policy updated
+	sanitizer.UpdatePolicy(func(l *htmlsanitizer.AllowList) {
+		l.GlobalAttr = nil
+	})
+	check(`<p><a href="http://example.com/">link</a></p>`, 1)
+	check(`<p><a href="http://example.com/">link</a></p>`, 2)
+
+	// URLSanitizer replaced, which is identified by the URLSanitizerName
+	for _, host := range []string{"example.org", "example.net"} {
+		host := host
+		sanitizer.URLSanitizer = func(rawURL string) (string, bool) {
+			return "http://" + host + "/", true
+		}
+		sanitizer.URLSanitizerName = host
+		check(`<p><a href="http://`+host+`/">link</a></p>`, 2)
+	}
+	sanitizer.URLSanitizer = htmlsanitizer.DefaultURLSanitizer
+	check(`<p><a href="http://example.net/">link</a></p>`, 3)
+	sanitizer.URLSanitizer = nil
+	check(`<p><a href="http://example.com/">link</a></p>`, 3)
+
+	// Policy swapped
+	sanitizer.UpdatePolicy(func(l *htmlsanitizer.AllowList) {
+		l.RemoveTag("a")
+	})
+	check(`<p>link</p>`, 3)
+	check(`<p>link</p>`, 4)
+
+	// Limits changed, the errors are not cached
+	sanitizer.Limits.MaxInputBytes = 16
+	for i := 0; i < 2; i++ {
+		if _, err := cache.SanitizeString(data); err == nil {
+			t.Errorf("expect a LimitError")
+		}
+	}
+	if stats := cache.Stats(); stats.Entries != 0 {
+		t.Errorf("expect the errors not cached, got %+v", stats)
+	}
+
+	// the AllowList, a copy of the last Policy, is not cached without the
+	// Policy
+	sanitizer.Limits.MaxInputBytes = 0
+	sanitizer.SetPolicy(nil)
+	check(`<p>link</p>`, 4)
+	check(`<p>link</p>`, 4)
+}
+
+func TestCacheEviction(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.SetPolicy(sanitizer.Compile())
+	cache := sanitizer.NewCache(4096)
+
+	for i := 0; i < 100; i++ {
+		data := fmt.Sprintf("<p>%d%s</p>", i, strings.Repeat("x", 100))
+		ret, _ := cache.Sanitize([]byte(data))
+		if string(ret) != data {
+			t.Errorf("expect %#v, got %#v", data, string(ret))
+		}
+
+		// modifying the returned data does not affect the cache
+		ret[0] = 'x'
+	}
+
+	stats := cache.Stats()
+	if stats.Bytes > 4096 || stats.Entries == 0 || stats.Evictions != uint64(100-stats.Entries) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// the most recently used ones are kept
+	ret, _ := cache.Sanitize([]byte("<p>99" + strings.Repeat("x", 100) + "</p>"))
+	if ret[0] != '<' || cache.Stats().Hits != 1 {
+		t.Errorf("expect a hit, got %+v", cache.Stats())
+	}
+
+	// too large to cache
+	_, _ = cache.SanitizeString(strings.Repeat("x", 4096))
+	if cache.Stats().Entries != stats.Entries {
+		t.Errorf("expect the large result not cached, got %+v", cache.Stats())
+	}
+
+	cache.Purge()
+	if stats := cache.Stats(); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Errorf("expect an empty cache, got %+v", stats)
+	}
+}
+
+func TestCacheBypass(t *testing.T) {
+	var violations int
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.SetPolicy(sanitizer.Compile())
+	sanitizer.OnViolation = func(v htmlsanitizer.Violation) {
+		violations++
+	}
+	cache := sanitizer.NewCache(1 << 20)
+
+	for i := 0; i < 2; i++ {
+		_, _ = cache.SanitizeString(`<script>alert(1)</script>`)
+	}
+	if stats := cache.Stats(); violations != 2 || stats.Hits != 0 || stats.Entries != 0 {
+		t.Errorf("expect the cache bypassed, got %d violations, %+v", violations, stats)
+	}
+
+	// the custom sanitizers without names are never cached, since they can
+	// not be told apart
+	sanitizer.OnViolation = nil
+	for name, configure := range map[string]func(f *htmlsanitizer.HTMLSanitizer){
+		"URLSanitizer": func(f *htmlsanitizer.HTMLSanitizer) {
+			f.URLSanitizer = htmlsanitizer.DefaultURLSanitizer
+		},
+		"StyleSanitizer": func(f *htmlsanitizer.HTMLSanitizer) {
+			f.StyleSanitizer = htmlsanitizer.EmailStyleSanitizer
+		},
+		"UGCPolicy": func(f *htmlsanitizer.HTMLSanitizer) {
+			f.Hook = htmlsanitizer.UGCPolicy().Hook
+		},
+	} {
+		f := htmlsanitizer.NewHTMLSanitizer()
+		f.SetPolicy(f.Compile())
+		configure(f)
+		cache := f.NewCache(1 << 20)
+		for i := 0; i < 2; i++ {
+			_, _ = cache.SanitizeString(`<b>x</b>`)
+		}
+		if stats := cache.Stats(); stats.Hits != 0 || stats.Entries != 0 {
+			t.Errorf("%s: expect the cache bypassed, got %+v", name, stats)
+		}
+	}
+
+	// named ones are cached, and renaming drops the cached results
+	sanitizer.StyleSanitizer = htmlsanitizer.EmailStyleSanitizer
+	sanitizer.StyleSanitizerName = "v1"
+	for _, name := range []string{"v1", "v1", "v2"} {
+		sanitizer.StyleSanitizerName = name
+		_, _ = cache.SanitizeString(`<b>x</b>`)
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 2 || stats.Entries != 1 {
+		t.Errorf("expect the cache invalidated by the StyleSanitizerName, got %+v", stats)
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.SetPolicy(sanitizer.Compile())
+	cache := sanitizer.NewCache(2048)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				data := fmt.Sprintf("<b>%d</b>", (i+j)%32)
+				if ret, _ := cache.SanitizeString(data); ret != data {
+					t.Errorf("expect %#v, got %#v", data, ret)
+				}
+				if j%50 == 0 {
+					sanitizer.UpdatePolicy(func(l *htmlsanitizer.AllowList) {})
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if stats := cache.Stats(); stats.Hits+stats.Misses != 800 || stats.Bytes > 2048 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func BenchmarkCache(b *testing.B) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.SetPolicy(sanitizer.Compile())
+	data := strings.Repeat(policyTestData, 16)
+
+	b.Run("Sanitize", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = sanitizer.SanitizeString(data)
+		}
+	})
+	b.Run("Cache", func(b *testing.B) {
+		cache := sanitizer.NewCache(1 << 20)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = cache.SanitizeString(data)
+		}
+	})
+}
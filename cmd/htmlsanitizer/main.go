@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"io"
 	"log"
@@ -12,12 +13,23 @@ import (
 )
 
 var (
-	srcFilePath = flag.String("src", "", "could be either source file path, or the source URL")
+	srcFilePath  = flag.String("src", "", "could be either source file path, or the source URL")
+	policyPath   = flag.String("policy", "", "path of the JSON policy file, the default allowlist is used if empty")
+	exportPolicy = flag.Bool("export-policy", false, "export the default allowlist as JSON to stdout")
 )
 
 func main() {
 	flag.Parse()
 
+	if *exportPolicy {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(htmlsanitizer.DefaultAllowList); err != nil {
+			log.Fatalf("unable to export the default allowlist: %s", err)
+		}
+		return
+	}
+
 	if len(*srcFilePath) == 0 {
 		flag.CommandLine.Usage()
 		return
@@ -42,6 +54,19 @@ func main() {
 	defer src.Close()
 
 	san := htmlsanitizer.NewHTMLSanitizer()
+	if len(*policyPath) > 0 {
+		// decoded from the file, os.ReadFile requires Go 1.16
+		file, err := os.Open(*policyPath)
+		if err != nil {
+			log.Fatalf("unable to read policy file: %s", err)
+		}
+		err = json.NewDecoder(file).Decode(san.AllowList)
+		file.Close()
+		if err != nil {
+			log.Fatalf("unable to load policy: %s", err)
+		}
+	}
+
 	writer := san.NewWriter(os.Stdout)
 	if _, err := io.Copy(writer, src); err != nil {
 		log.Printf("unable to sanitize HTML content: %s", err)
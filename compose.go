@@ -0,0 +1,376 @@
+package htmlsanitizer
+
+import "regexp"
+
+// hasString checks whether s contains item.
+func hasString(s []string, item string) bool {
+	for _, v := range s {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+// removeStrings returns s without any of items, in place.
+func removeStrings(s []string, items ...string) []string {
+	n := 0
+	for _, v := range s {
+		if !hasString(items, v) {
+			s[n] = v
+			n++
+		}
+	}
+	return s[:n]
+}
+
+// AddAttr allows the attributes for t, which must be lowercase. The
+// attributes already allowed are ignored.
+func (t *Tag) AddAttr(names ...string) {
+	for _, name := range names {
+		if !hasString(t.Attr, name) && !hasString(t.URLAttr, name) {
+			t.Attr = append(t.Attr, name)
+		}
+	}
+}
+
+// AddURLAttr allows the URL-related attributes for t, which must be
+// lowercase. An attribute already allowed by Attr becomes a URL-related one.
+func (t *Tag) AddURLAttr(names ...string) {
+	t.Attr = removeStrings(t.Attr, names...)
+	for _, name := range names {
+		if !hasString(t.URLAttr, name) {
+			t.URLAttr = append(t.URLAttr, name)
+		}
+	}
+}
+
+// RemoveAttr removes the attributes from both the Attr and URLAttr of t,
+// along with their patterns.
+func (t *Tag) RemoveAttr(names ...string) {
+	t.Attr = removeStrings(t.Attr, names...)
+	t.URLAttr = removeStrings(t.URLAttr, names...)
+	for _, name := range names {
+		delete(t.AttrPattern, name)
+	}
+}
+
+// setPattern sets the pattern of attr in patterns, a nil pattern is removed.
+func setPattern(patterns *map[string]*regexp.Regexp, attr string, pattern *regexp.Regexp) {
+	if pattern == nil {
+		delete(*patterns, attr)
+		return
+	}
+
+	if *patterns == nil {
+		*patterns = make(map[string]*regexp.Regexp)
+	}
+	(*patterns)[attr] = pattern
+}
+
+// unionPattern returns the pattern matching the values matched by either a
+// or b. A nil pattern matches any value.
+func unionPattern(a, b *regexp.Regexp) *regexp.Regexp {
+	if a == nil || b == nil {
+		return nil
+	}
+	if a.String() == b.String() {
+		return a
+	}
+	return regexp.MustCompile("(?:" + a.String() + ")|(?:" + b.String() + ")")
+}
+
+// intersectPattern returns the pattern matching the values matched by both a
+// and b. Since it's not possible for different patterns, ok is false if both
+// of them are set and different.
+func intersectPattern(a, b *regexp.Regexp) (pattern *regexp.Regexp, ok bool) {
+	switch {
+	case a == nil:
+		return b, true
+	case b == nil || a.String() == b.String():
+		return a, true
+	}
+	return nil, false
+}
+
+// mergeTag merges the attributes of src into dst.
+func mergeTag(dst, src *Tag) {
+	for _, attr := range unionStrings(src.URLAttr, src.Attr) {
+		pattern := src.AttrPattern[attr]
+		if hasString(dst.Attr, attr) || hasString(dst.URLAttr, attr) {
+			pattern = unionPattern(dst.AttrPattern[attr], pattern)
+		}
+		setPattern(&dst.AttrPattern, attr, pattern)
+	}
+
+	dst.AddURLAttr(src.URLAttr...)
+	dst.AddAttr(src.Attr...)
+
+	// required by both of them
+	var require []string
+	for _, attr := range dst.Require {
+		if hasString(src.Require, attr) {
+			require = append(require, attr)
+		}
+	}
+	dst.Require = require
+}
+
+// widenGlobalAttrs widens the patterns of the attributes of tag allowed
+// globally by l, but not by its own tag. Since the tag patterns take
+// precedence over the global ones, the attributes match either of them.
+func widenGlobalAttrs(tag *Tag, l *AllowList) {
+	own := l.findTag(tag.Name)
+	for attr, pattern := range tag.AttrPattern {
+		if !hasString(l.GlobalAttr, attr) {
+			continue
+		}
+		if own != nil && (hasString(own.Attr, attr) || hasString(own.URLAttr, attr)) {
+			continue
+		}
+		setPattern(&tag.AttrPattern, attr, unionPattern(pattern, l.GlobalAttrPattern[attr]))
+	}
+}
+
+// uniqueTags returns the deep copy of the tags which take effect, i.e. the
+// first one of the tags with the same name, in order.
+func uniqueTags(tags []*Tag) []*Tag {
+	var ret []*Tag
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		if tag == nil || seen[tag.Name] {
+			continue
+		}
+		seen[tag.Name] = true
+		ret = append(ret, tag.Clone())
+	}
+	return ret
+}
+
+// unionStrings returns the union of a and b, in order.
+func unionStrings(a, b []string) []string {
+	var ret []string
+	for _, s := range [][]string{a, b} {
+		for _, item := range s {
+			if !hasString(ret, item) {
+				ret = append(ret, item)
+			}
+		}
+	}
+	return ret
+}
+
+// Merge returns a new AllowList allowing everything allowed by either l or
+// other, e.g. base.Merge(tables). Both l and other are not modified.
+//
+// The attributes of the tags with the same name are merged. If an attribute
+// is a URL-related one in either of them, it's a URL-related one in the new
+// AllowList, so it's always sanitized by the URLSanitizer. An attribute
+// allowed by both of them, for the tag or globally, matches either of the
+// patterns, if any. Only the attributes required by both of them are
+// required. The SVG and the MathML lists, and the CustomElements with the
+// same patterns, are merged the same way.
+func (l *AllowList) Merge(other *AllowList) *AllowList {
+	if l == nil {
+		return other.Clone()
+	}
+	if other == nil {
+		return l.Clone()
+	}
+
+	ret := &AllowList{
+		Tags:              uniqueTags(l.Tags),
+		GlobalAttr:        unionStrings(l.GlobalAttr, other.GlobalAttr),
+		GlobalAttrPattern: clonePatterns(l.GlobalAttrPattern),
+		NonHTMLTags:       uniqueTags(append(append([]*Tag(nil), l.NonHTMLTags...), other.NonHTMLTags...)),
+		SVG:               l.SVG.Merge(other.SVG),
+		MathML:            l.MathML.Merge(other.MathML),
+		NamespacePrefixes: unionStrings(l.NamespacePrefixes, other.NamespacePrefixes),
+	}
+	if len(l.CustomElements) > 0 || len(other.CustomElements) > 0 {
+		custom := (&AllowList{Tags: customTags(l.CustomElements)}).Merge(&AllowList{Tags: customTags(other.CustomElements)})
+		ret.CustomElements = fromCustomTags(custom.Tags)
+	}
+	for _, attr := range other.GlobalAttr {
+		pattern := other.GlobalAttrPattern[attr]
+		if hasString(l.GlobalAttr, attr) {
+			pattern = unionPattern(l.GlobalAttrPattern[attr], pattern)
+		}
+		setPattern(&ret.GlobalAttrPattern, attr, pattern)
+	}
+
+	for _, tag := range uniqueTags(other.Tags) {
+		if dst := ret.findTag(tag.Name); dst != nil {
+			mergeTag(dst, tag)
+			continue
+		}
+		ret.Tags = append(ret.Tags, tag)
+	}
+	for _, tag := range ret.Tags {
+		widenGlobalAttrs(tag, l)
+		widenGlobalAttrs(tag, other)
+	}
+
+	return ret
+}
+
+// Subtract returns a new AllowList without the tags and global attributes
+// allowed by other, e.g. base.Subtract(images). Both l and other are not
+// modified.
+//
+// The tags of other are removed as a whole regardless of their attributes,
+// use Tag.RemoveAttr to remove some attributes of a tag. The NonHTMLTags are
+// never subtracted, since they define how the content is parsed rather than
+// what is allowed. The SVG and the MathML lists of other are subtracted from
+// the ones of l, and the CustomElements with the same patterns as other are
+// removed.
+func (l *AllowList) Subtract(other *AllowList) *AllowList {
+	ret := l.Clone()
+	if ret == nil || other == nil {
+		return ret
+	}
+
+	for _, tag := range other.Tags {
+		if tag != nil {
+			ret.RemoveTag(tag.Name)
+		}
+	}
+	ret.GlobalAttr = removeStrings(ret.GlobalAttr, other.GlobalAttr...)
+	for _, attr := range other.GlobalAttr {
+		delete(ret.GlobalAttrPattern, attr)
+	}
+	ret.SVG = l.SVG.Subtract(other.SVG)
+	ret.MathML = l.MathML.Subtract(other.MathML)
+	custom := &AllowList{Tags: customTags(ret.CustomElements)}
+	for _, tag := range customTags(other.CustomElements) {
+		custom.RemoveTag(tag.Name)
+	}
+	ret.CustomElements = fromCustomTags(custom.Tags)
+
+	return ret
+}
+
+// attrAllowedByList reports whether attr is allowed for tag by l, either by
+// the tag itself or globally, whether it's a URL-related one, and its
+// pattern if any.
+func (l *AllowList) attrAllowedByList(tag *Tag, attr string) (ok, urlAttr bool, pattern *regexp.Regexp) {
+	switch {
+	case hasString(tag.URLAttr, attr):
+		return true, true, tag.AttrPattern[attr]
+	case hasString(tag.Attr, attr):
+		return true, false, tag.AttrPattern[attr]
+	case hasString(l.GlobalAttr, attr):
+		return true, false, l.GlobalAttrPattern[attr]
+	}
+	return false, false, nil
+}
+
+// addAttr allows attr for t with the pattern.
+func (t *Tag) addAttr(attr string, urlAttr bool, pattern *regexp.Regexp) {
+	if urlAttr {
+		t.AddURLAttr(attr)
+	} else {
+		t.AddAttr(attr)
+	}
+	setPattern(&t.AttrPattern, attr, pattern)
+}
+
+// Intersect returns a new AllowList allowing only what is allowed by both l
+// and other. Both l and other are not modified.
+//
+// An attribute is kept for a tag if both of them allow it for the tag,
+// either by the tag itself or globally. If it's a URL-related one in either
+// of them, it's a URL-related one in the new AllowList. If both of them
+// restrict its value with different patterns, the attribute is dropped. The
+// attributes required by either of them are required, and a tag is dropped
+// if any of them is dropped. The NonHTMLTags of both are kept, since they
+// define how the content is parsed rather than what is allowed. The SVG and
+// the MathML are allowed only if both of them allow them, and their lists
+// are intersected. Only the CustomElements with the same patterns in both
+// are kept, of which the attributes are intersected the same as the tags.
+func (l *AllowList) Intersect(other *AllowList) *AllowList {
+	if l == nil || other == nil {
+		return nil
+	}
+
+	ret := &AllowList{
+		NonHTMLTags: uniqueTags(append(append([]*Tag(nil), l.NonHTMLTags...), other.NonHTMLTags...)),
+		SVG:         l.SVG.Intersect(other.SVG),
+		MathML:      l.MathML.Intersect(other.MathML),
+
+		// the prefixes of both are kept, so the names are still valid
+		NamespacePrefixes: unionStrings(l.NamespacePrefixes, other.NamespacePrefixes),
+	}
+	for _, attr := range l.GlobalAttr {
+		if !hasString(other.GlobalAttr, attr) || hasString(ret.GlobalAttr, attr) {
+			continue
+		}
+		if pattern, ok := intersectPattern(l.GlobalAttrPattern[attr], other.GlobalAttrPattern[attr]); ok {
+			ret.GlobalAttr = append(ret.GlobalAttr, attr)
+			setPattern(&ret.GlobalAttrPattern, attr, pattern)
+		}
+	}
+
+	for _, tag := range uniqueTags(l.Tags) {
+		otherTag := other.findTag(tag.Name)
+		if otherTag == nil {
+			continue
+		}
+
+		newTag := &Tag{Name: tag.Name}
+		for _, attr := range unionStrings(tag.URLAttr, tag.Attr) {
+			ok, urlAttr, pattern := l.attrAllowedByList(tag, attr)
+			otherOK, otherURLAttr, otherPattern := other.attrAllowedByList(otherTag, attr)
+			if !ok || !otherOK {
+				continue
+			}
+			if pattern, ok := intersectPattern(pattern, otherPattern); ok {
+				newTag.addAttr(attr, urlAttr || otherURLAttr, pattern)
+			}
+		}
+
+		// the global attributes of l allowed only by the tag of other
+		for _, attr := range unionStrings(otherTag.URLAttr, otherTag.Attr) {
+			if !hasString(l.GlobalAttr, attr) || hasString(ret.GlobalAttr, attr) {
+				continue
+			}
+			if pattern, ok := intersectPattern(l.GlobalAttrPattern[attr], otherTag.AttrPattern[attr]); ok {
+				newTag.addAttr(attr, hasString(otherTag.URLAttr, attr), pattern)
+			}
+		}
+
+		newTag.Require = unionStrings(tag.Require, otherTag.Require)
+		if ret.requireAllowed(newTag) {
+			ret.Tags = append(ret.Tags, newTag)
+		}
+	}
+
+	if len(l.CustomElements) > 0 && len(other.CustomElements) > 0 {
+		custom := (&AllowList{Tags: customTags(l.CustomElements), GlobalAttr: l.GlobalAttr, GlobalAttrPattern: l.GlobalAttrPattern}).
+			Intersect(&AllowList{Tags: customTags(other.CustomElements), GlobalAttr: other.GlobalAttr, GlobalAttrPattern: other.GlobalAttrPattern})
+		ret.CustomElements = fromCustomTags(custom.Tags)
+	}
+
+	return ret
+}
+
+// requireAllowed checks whether all the required attributes of tag are
+// allowed by l.
+func (l *AllowList) requireAllowed(tag *Tag) bool {
+	for _, attr := range tag.Require {
+		if ok, _, _ := l.attrAllowedByList(tag, attr); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// findTag finds the first tag named name, case sensitive.
+func (l *AllowList) findTag(name string) *Tag {
+	for _, tag := range l.Tags {
+		if tag != nil && tag.Name == name {
+			return tag
+		}
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package htmlsanitizer_test
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleAllowList_Merge() {
+	base := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "p"},
+			{Name: "img", Attr: []string{"alt"}, URLAttr: []string{"src"}},
+		},
+		GlobalAttr: []string{"class"},
+	}
+	tables := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "table"},
+			{Name: "tr"},
+			{Name: "td", Attr: []string{"colspan", "rowspan"}},
+		},
+	}
+	images := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{{Name: "img"}},
+	}
+
+	// base + tables - images
+	sanitizer := &htmlsanitizer.HTMLSanitizer{
+		AllowList: base.Merge(tables).Subtract(images),
+	}
+	output, _ := sanitizer.SanitizeString(`<p class="x"><img src="/x.png"></p><table><tr><td colspan="2">1</td></tr></table>`)
+	fmt.Print(output)
+	// Output:
+	// <p class="x"></p><table><tr><td colspan="2">1</td></tr></table>
+}
+
+func ExampleTag_AddAttr() {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.FindTag([]byte("a")).RemoveAttr("target")
+	sanitizer.FindTag([]byte("img")).AddAttr("title")
+
+	output, _ := sanitizer.SanitizeString(`<a href="/" target="_blank">link</a><img src="/x.png" title="x">`)
+	fmt.Print(output)
+	// Output:
+	// <a href="/">link</a><img src="/x.png" title="x">
+}
+
+func TestCloneDeep(t *testing.T) {
+	list := htmlsanitizer.DefaultAllowList.Clone()
+	list.FindTag([]byte("a")).AddAttr("onclick")
+	list.NonHTMLTags[0].Name = "x"
+
+	if htmlsanitizer.DefaultAllowList.FindTag([]byte("a")).Attr[0] == "onclick" ||
+		len(htmlsanitizer.DefaultAllowList.FindTag([]byte("a")).Attr) != 3 ||
+		htmlsanitizer.DefaultAllowList.NonHTMLTags[0].Name != "script" {
+		t.Errorf("expect the DefaultAllowList not modified")
+	}
+}
+
+func TestTagAttr(t *testing.T) {
+	tag := &htmlsanitizer.Tag{Name: "a", Attr: []string{"rel", "href"}}
+	tag.AddAttr("rel", "target")
+	tag.AddURLAttr("href", "ping")
+	tag.AddAttr("ping")
+
+	expected := &htmlsanitizer.Tag{Name: "a", Attr: []string{"rel", "target"}, URLAttr: []string{"href", "ping"}}
+	if !reflect.DeepEqual(tag, expected) {
+		t.Errorf("expect %+v, got %+v", expected, tag)
+	}
+
+	tag.RemoveAttr("rel", "ping", "unknown")
+	expected = &htmlsanitizer.Tag{Name: "a", Attr: []string{"target"}, URLAttr: []string{"href"}}
+	if !reflect.DeepEqual(tag, expected) {
+		t.Errorf("expect %+v, got %+v", expected, tag)
+	}
+}
+
+func TestAllowListCompose(t *testing.T) {
+	a := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "a", Attr: []string{"rel", "href"}},
+			{Name: "b", Attr: []string{"title"}},
+			{Name: "i"},
+			{Name: "a", Attr: []string{"onclick"}},
+		},
+		GlobalAttr:  []string{"class", "id"},
+		NonHTMLTags: []*htmlsanitizer.Tag{{Name: "script"}},
+	}
+	b := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "b", Attr: []string{"id"}, URLAttr: []string{"title"}},
+			{Name: "a", Attr: []string{"target", "class"}, URLAttr: []string{"href"}},
+			{Name: "u"},
+		},
+		GlobalAttr:  []string{"class", "rel"},
+		NonHTMLTags: []*htmlsanitizer.Tag{{Name: "style"}},
+	}
+	aFingerprint, bFingerprint := a.Fingerprint(), b.Fingerprint()
+
+	testCases := []struct {
+		name     string
+		got      *htmlsanitizer.AllowList
+		expected *htmlsanitizer.AllowList
+	}{
+		{
+			name: "merge",
+			got:  a.Merge(b),
+			expected: &htmlsanitizer.AllowList{
+				Tags: []*htmlsanitizer.Tag{
+					{Name: "a", Attr: []string{"rel", "target", "class"}, URLAttr: []string{"href"}},
+					{Name: "b", Attr: []string{"id"}, URLAttr: []string{"title"}},
+					{Name: "i"},
+					{Name: "u"},
+				},
+				GlobalAttr:  []string{"class", "id", "rel"},
+				NonHTMLTags: []*htmlsanitizer.Tag{{Name: "script"}, {Name: "style"}},
+			},
+		},
+		{
+			name: "subtract",
+			got:  a.Subtract(b),
+			expected: &htmlsanitizer.AllowList{
+				Tags:        []*htmlsanitizer.Tag{{Name: "i"}},
+				GlobalAttr:  []string{"id"},
+				NonHTMLTags: []*htmlsanitizer.Tag{{Name: "script"}},
+			},
+		},
+		{
+			name: "intersect",
+			got:  a.Intersect(b),
+			expected: &htmlsanitizer.AllowList{
+				Tags: []*htmlsanitizer.Tag{
+					{Name: "a", Attr: []string{"rel"}, URLAttr: []string{"href"}},
+					{Name: "b", Attr: []string{"id"}, URLAttr: []string{"title"}},
+				},
+				GlobalAttr:  []string{"class"},
+				NonHTMLTags: []*htmlsanitizer.Tag{{Name: "script"}, {Name: "style"}},
+			},
+		},
+	}
+
+	for _, item := range testCases {
+		if item.got.Fingerprint() != item.expected.Fingerprint() {
+			t.Errorf("%s: expect %s, got %s", item.name, dumpAllowList(item.expected), dumpAllowList(item.got))
+		}
+	}
+
+	if a.Fingerprint() != aFingerprint || b.Fingerprint() != bFingerprint {
+		t.Errorf("expect the operands not modified")
+	}
+
+	if a.Merge(nil).Fingerprint() != aFingerprint || a.Subtract(nil).Fingerprint() != aFingerprint {
+		t.Errorf("expect a nil AllowList to change nothing")
+	}
+	if a.Intersect(nil) != nil {
+		t.Errorf("expect nothing allowed by intersecting with a nil AllowList")
+	}
+}
+
+func TestAllowListComposeRequire(t *testing.T) {
+	a := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "button", Attr: []string{"type", "name"}, Require: []string{"type"}},
+			{Name: "input", Attr: []string{"type"}, Require: []string{"type"}},
+		},
+	}
+	b := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "button", Attr: []string{"type", "name"}, Require: []string{"name"}},
+			{Name: "input", Attr: []string{"name"}, Require: []string{"name"}},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		list     *htmlsanitizer.AllowList
+		expected string
+	}{
+		{
+			// only the attributes required by both
+			name: "Merge",
+			list: a.Merge(b),
+			expected: `<button>1</button><button type="x">2</button><button name="x">3</button><input><input type="x" name="x">` +
+				`<button type="x" name="x">4</button>`,
+		},
+		{
+			// the input is dropped, since it can never have both type and name
+			name:     "Intersect",
+			list:     a.Intersect(b),
+			expected: `123<button type="x" name="x">4</button>`,
+		},
+	}
+
+	data := `<button>1</button><button type="x">2</button><button name="x">3</button><input><input type="x" name="x">` +
+		`<button type="x" name="x">4</button>`
+	for _, c := range cases {
+		if err := c.list.Validate(); err != nil {
+			t.Errorf("%s: expect a valid AllowList, got %s", c.name, err)
+		}
+		ret, _ := (&htmlsanitizer.HTMLSanitizer{AllowList: c.list}).SanitizeString(data)
+		if ret != c.expected {
+			t.Errorf("%s: expect %#v, got %#v", c.name, c.expected, ret)
+		}
+	}
+
+	if a.Tags[0].Require[0] != "type" || len(b.Tags[1].Require) != 1 {
+		t.Errorf("expect the operands not modified, got %s and %s", dumpAllowList(a), dumpAllowList(b))
+	}
+}
+
+func TestAllowListComposeSVG(t *testing.T) {
+	icons := &htmlsanitizer.AllowList{SVG: &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{{Name: "svg", Attr: []string{"viewbox"}}, {Name: "path", Attr: []string{"d"}}},
+	}}
+	shapes := &htmlsanitizer.AllowList{SVG: &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{{Name: "svg"}, {Name: "rect"}},
+	}}
+
+	cases := []struct {
+		name     string
+		list     *htmlsanitizer.AllowList
+		expected string
+	}{
+		{"Merge", icons.Merge(shapes), `<svg viewBox="1"><path d="1"></path><rect></rect></svg>`},
+		{"Merge nil", (&htmlsanitizer.AllowList{}).Merge(shapes), `<svg><rect></rect></svg>`},
+		{"Intersect", icons.Intersect(shapes), `<svg></svg>`},
+		{"Intersect nil", icons.Intersect(&htmlsanitizer.AllowList{}), ``},
+		{"Subtract", icons.Subtract(&htmlsanitizer.AllowList{SVG: &htmlsanitizer.AllowList{Tags: []*htmlsanitizer.Tag{{Name: "path"}}}}),
+			`<svg viewBox="1"></svg>`},
+	}
+
+	data := `<svg viewbox="1"><path d="1"></path><rect></rect></svg>`
+	for _, c := range cases {
+		if err := c.list.Validate(); err != nil {
+			t.Errorf("%s: expect a valid AllowList, got %s", c.name, err)
+		}
+		ret, _ := (&htmlsanitizer.HTMLSanitizer{AllowList: c.list}).SanitizeString(data)
+		if ret != c.expected {
+			t.Errorf("%s: expect %#v, got %#v", c.name, c.expected, ret)
+		}
+	}
+
+	if len(icons.SVG.Tags) != 2 || len(shapes.SVG.Tags) != 2 {
+		t.Errorf("expect the operands not modified, got %s and %s", dumpAllowList(icons.SVG), dumpAllowList(shapes.SVG))
+	}
+}
+
+func dumpAllowList(l *htmlsanitizer.AllowList) string {
+	ret := fmt.Sprintf("global=%v nonHTML=", l.GlobalAttr)
+	for _, tag := range l.NonHTMLTags {
+		ret += tag.Name + ","
+	}
+	for _, tag := range l.Tags {
+		ret += fmt.Sprintf(" %+v", *tag)
+	}
+	return ret
+}
+
+func TestAllowListComposePattern(t *testing.T) {
+	lower := regexp.MustCompile(`^[a-z]+$`)
+	digit := regexp.MustCompile(`^[0-9]+$`)
+	a := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "p", Attr: []string{"title", "dir"}, AttrPattern: map[string]*regexp.Regexp{"title": lower, "dir": lower}},
+		},
+		GlobalAttr:        []string{"id", "class"},
+		GlobalAttrPattern: map[string]*regexp.Regexp{"id": lower},
+	}
+	b := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "p", Attr: []string{"title", "dir"}, AttrPattern: map[string]*regexp.Regexp{"title": digit}},
+		},
+		GlobalAttr:        []string{"id", "class"},
+		GlobalAttrPattern: map[string]*regexp.Regexp{"id": digit, "class": digit},
+	}
+
+	cases := []struct {
+		name     string
+		list     *htmlsanitizer.AllowList
+		expected string
+	}{
+		{
+			name:     "Merge",
+			list:     a.Merge(b),
+			expected: `<p title="x" dir="x" id="x" class="x">1</p><p title="1" dir="1" id="1" class="1">2</p><p dir="-" class="-">3</p>`,
+		},
+		{
+			name:     "Intersect",
+			list:     a.Intersect(b),
+			expected: `<p dir="x">1</p><p class="1">2</p><p>3</p>`,
+		},
+		{
+			name:     "Subtract",
+			list:     a.Subtract(&htmlsanitizer.AllowList{GlobalAttr: []string{"id"}}),
+			expected: `<p title="x" dir="x" class="x">1</p><p class="1">2</p><p class="-">3</p>`,
+		},
+	}
+
+	data := `<p title="x" dir="x" id="x" class="x">1</p><p title="1" dir="1" id="1" class="1">2</p><p title="-" dir="-" id="-" class="-">3</p>`
+	for _, c := range cases {
+		ret, _ := (&htmlsanitizer.HTMLSanitizer{AllowList: c.list}).SanitizeString(data)
+		if ret != c.expected {
+			t.Errorf("%s: expect %#v, got %#v", c.name, c.expected, ret)
+		}
+	}
+}
+
+func TestAllowListMergeGlobalPattern(t *testing.T) {
+	lower := regexp.MustCompile(`^[a-z]+$`)
+	digit := regexp.MustCompile(`^[0-9]+$`)
+	a := &htmlsanitizer.AllowList{
+		Tags:              []*htmlsanitizer.Tag{{Name: "p"}, {Name: "span"}},
+		GlobalAttr:        []string{"id", "class"},
+		GlobalAttrPattern: map[string]*regexp.Regexp{"id": lower},
+	}
+	b := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "p", Attr: []string{"id", "class"}, AttrPattern: map[string]*regexp.Regexp{"id": digit, "class": digit}},
+			{Name: "span", Attr: []string{"class"}, AttrPattern: map[string]*regexp.Regexp{"class": digit}},
+		},
+	}
+
+	// the tag patterns of b do not restrict the global attributes of a
+	data := `<p id="x" class="x">1</p><p id="1" class="1">2</p><p id="-" class="-">3</p><span class="x">4</span>`
+	expected := `<p id="x" class="x">1</p><p id="1" class="1">2</p><p class="-">3</p><span class="x">4</span>`
+	for name, list := range map[string]*htmlsanitizer.AllowList{"a.Merge(b)": a.Merge(b), "b.Merge(a)": b.Merge(a)} {
+		ret, _ := (&htmlsanitizer.HTMLSanitizer{AllowList: list}).SanitizeString(data)
+		if ret != expected {
+			t.Errorf("%s: expect %#v, got %#v", name, expected, ret)
+		}
+	}
+
+	if len(b.Tags[1].AttrPattern) != 1 {
+		t.Errorf("expect the operands not modified, got %+v", b.Tags[1])
+	}
+}
@@ -0,0 +1,141 @@
+package htmlsanitizer
+
+import (
+	"regexp"
+)
+
+// CustomElementName matches the valid custom element names in lowercase,
+// which can be used as the CustomElement.Pattern to allow all the custom
+// elements, see
+// https://html.spec.whatwg.org/multipage/custom-elements.html#valid-custom-element-name .
+// Only the ASCII names are matched.
+var CustomElementName = regexp.MustCompile(`^[a-z][a-z0-9._]*-[a-z0-9._-]*$`)
+
+// CustomElementPrefix returns the pattern matching the custom elements with
+// the prefix, e.g. ds for <ds-card> and <ds-callout>.
+func CustomElementPrefix(prefix string) *regexp.Regexp {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(prefix) + `-[a-z0-9._-]+$`)
+}
+
+// reservedCustomElementNames are the names with a hyphen which are not valid
+// custom element names, since they are used by the SVG and the MathML.
+var reservedCustomElementNames = map[string]bool{
+	"annotation-xml":   true,
+	"color-profile":    true,
+	"font-face":        true,
+	"font-face-src":    true,
+	"font-face-uri":    true,
+	"font-face-format": true,
+	"font-face-name":   true,
+	"missing-glyph":    true,
+}
+
+// validCustomElementName checks whether the lowercase name is a valid custom
+// element name, which is never a standard element.
+func validCustomElementName(name []byte) bool {
+	if len(name) == 0 || name[0] < 'a' || name[0] > 'z' || reservedCustomElementNames[string(name)] {
+		return false
+	}
+
+	hyphen := false
+	for _, b := range name {
+		switch {
+		case b == '-':
+			hyphen = true
+		case 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '.', b == '_':
+		default:
+			return false
+		}
+	}
+	return hyphen
+}
+
+// CustomElement allows the custom elements, such as <ds-card>, of which the
+// names match the Pattern, without listing every one of them in the Tags.
+type CustomElement struct {
+	// Pattern matches the lowercase names of the elements, which should be
+	// anchored, such as ^ds-[a-z0-9-]+$ . Only the valid custom element
+	// names are matched, so it never matches the standard elements, nor the
+	// reserved names such as font-face.
+	Pattern *regexp.Regexp
+
+	// Attr, URLAttr, AttrPattern and Require specify the attributes of the
+	// matched elements, the same as the ones of Tag.
+	Attr        []string
+	URLAttr     []string
+	AttrPattern map[string]*regexp.Regexp
+	Require     []string
+}
+
+// clone returns a deep copy of c.
+func (c *CustomElement) clone() *CustomElement {
+	if c == nil {
+		return nil
+	}
+
+	return &CustomElement{
+		Pattern:     c.Pattern,
+		Attr:        append([]string(nil), c.Attr...),
+		URLAttr:     append([]string(nil), c.URLAttr...),
+		AttrPattern: clonePatterns(c.AttrPattern),
+		Require:     append([]string(nil), c.Require...),
+	}
+}
+
+// tag returns the Tag named by the pattern, with the attributes of c.
+func (c *CustomElement) tag() *Tag {
+	tag := &Tag{Attr: c.Attr, URLAttr: c.URLAttr, AttrPattern: c.AttrPattern, Require: c.Require}
+	if c.Pattern != nil {
+		tag.Name = c.Pattern.String()
+	}
+	return tag
+}
+
+// findCustomElement returns the Tag of the lowercase name if it matches any
+// of the elements, of which the compiled rules are the tags if not nil.
+func findCustomElement(elements []*CustomElement, rules []*Tag, name []byte) *Tag {
+	if len(elements) == 0 || !validCustomElementName(name) {
+		return nil
+	}
+
+	for i, c := range elements {
+		if c == nil || c.Pattern == nil || !c.Pattern.Match(name) {
+			continue
+		}
+
+		tag := &Tag{Name: string(name), Attr: c.Attr, URLAttr: c.URLAttr, AttrPattern: c.AttrPattern, Require: c.Require}
+		if rules != nil {
+			tag.rule = rules[i]
+		}
+		return tag
+	}
+	return nil
+}
+
+// customTags returns the custom elements as the tags named by the patterns,
+// so they are composed the same as the tags.
+func customTags(elements []*CustomElement) []*Tag {
+	ret := make([]*Tag, 0, len(elements))
+	for _, c := range elements {
+		if c != nil && c.Pattern != nil {
+			ret = append(ret, c.tag())
+		}
+	}
+	return ret
+}
+
+// fromCustomTags returns the custom elements of the tags returned by
+// customTags.
+func fromCustomTags(tags []*Tag) []*CustomElement {
+	var ret []*CustomElement
+	for _, tag := range tags {
+		ret = append(ret, &CustomElement{
+			Pattern:     regexp.MustCompile(tag.Name),
+			Attr:        tag.Attr,
+			URLAttr:     tag.URLAttr,
+			AttrPattern: tag.AttrPattern,
+			Require:     tag.Require,
+		})
+	}
+	return ret
+}
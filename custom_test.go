@@ -0,0 +1,232 @@
+package htmlsanitizer_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleCustomElement() {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.AllowList.CustomElements = []*htmlsanitizer.CustomElement{{
+		Pattern:     htmlsanitizer.CustomElementPrefix("ds"),
+		Attr:        []string{"variant"},
+		AttrPattern: map[string]*regexp.Regexp{"variant": regexp.MustCompile(`^(info|warning)$`)},
+	}}
+
+	data := `<ds-card variant="info" onclick="alert(1)"><ds-callout variant="x">hello</ds-callout></ds-card><x-card>world</x-card>`
+	output, _ := sanitizer.SanitizeString(data)
+	fmt.Println(output)
+	// Output:
+	// <ds-card variant="info"><ds-callout>hello</ds-callout></ds-card>world
+}
+
+func customElements() *htmlsanitizer.AllowList {
+	list := htmlsanitizer.DefaultAllowList.Clone()
+	list.Tags = append(list.Tags, &htmlsanitizer.Tag{Name: "ds-card", Attr: []string{"title"}})
+	list.CustomElements = []*htmlsanitizer.CustomElement{
+		{
+			Pattern: regexp.MustCompile(`^ds-icon$`),
+			Attr:    []string{"name"},
+			Require: []string{"name"},
+		},
+		{
+			Pattern: htmlsanitizer.CustomElementPrefix("ds"),
+			Attr:    []string{"variant"},
+			URLAttr: []string{"href"},
+		},
+		{
+			// any other custom element, without any attributes
+			Pattern: htmlsanitizer.CustomElementName,
+		},
+	}
+	return list
+}
+
+func TestCustomElements(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{`<DS-Callout VARIANT="info" class="x" title="x">a</DS-CALLOUT>`, `<ds-callout variant="info" class="x">a</ds-callout>`},
+		{`<ds-link href="javascript:alert(1)">a</ds-link><ds-link href="https://example.com/">b</ds-link>`,
+			`<ds-link>a</ds-link><ds-link href="https://example.com/">b</ds-link>`},
+		{`<my-element variant="x" id="y">a</my-element><a.b-c_d>b</a.b-c_d>`, `<my-element id="y">a</my-element><a.b-c_d>b</a.b-c_d>`},
+
+		// the Tags take precedence, and the first matched pattern wins
+		{`<ds-card title="x" variant="y">a</ds-card>`, `<ds-card title="x">a</ds-card>`},
+		{`<ds-icon name="x" variant="y"></ds-icon><ds-icon>a</ds-icon>`, `<ds-icon name="x"></ds-icon>a`},
+
+		// only the valid custom element names are matched
+		{`<font-face>a</font-face><missing-glyph>b</missing-glyph><annotation-xml>c</annotation-xml>`, `abc`},
+		{`<ds_card>a</ds_card><ds:card>b</ds:card><1-card>c</1-card><-card>d</-card>`, `abcd`},
+		{`<iframe src="https://example.com/">a</iframe><form>b</form>`, `ab`},
+		{`<ds-card/>`, `<ds-card />`},
+	}
+
+	for _, compiled := range []bool{false, true} {
+		sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: customElements()}
+		if compiled {
+			sanitizer.SetPolicy(sanitizer.Compile())
+		}
+
+		for _, item := range testCases {
+			ret, err := sanitizer.SanitizeString(item.in)
+			if err != nil {
+				t.Errorf("unable to SanitizeString err: %s", err)
+			}
+			if ret != item.expected {
+				t.Errorf("compiled %v: expect %#v, got %#v", compiled, item.expected, ret)
+			}
+		}
+	}
+
+	// the patterns never match the standard elements
+	list := &htmlsanitizer.AllowList{CustomElements: []*htmlsanitizer.CustomElement{{Pattern: regexp.MustCompile(`.*`)}}}
+	for _, policy := range []interface {
+		FindTag(p []byte) *htmlsanitizer.Tag
+	}{list, list.Compile()} {
+		for _, name := range []string{"script", "iframe", "svg", "p", "font-face"} {
+			if tag := policy.FindTag([]byte(name)); tag != nil {
+				t.Errorf("expect %s not matched, got %+v", name, tag)
+			}
+		}
+		if tag := policy.FindTag([]byte("X-Y")); tag == nil || tag.Name != "x-y" {
+			t.Errorf("expect x-y matched, got %+v", tag)
+		}
+	}
+}
+
+func TestCustomElementsHook(t *testing.T) {
+	sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: customElements()}
+	sanitizer.SetPolicy(sanitizer.Compile())
+	sanitizer.Hook = htmlsanitizer.HookFunc(func(dst []htmlsanitizer.Token, t htmlsanitizer.Token) []htmlsanitizer.Token {
+		if t.Type == htmlsanitizer.StartTagToken && t.Data == "ds-callout" {
+			// the output of the Hook is checked by the matched pattern
+			t.Attr = append(t.Attr, htmlsanitizer.Attribute{Name: "variant", Value: "warning"}, htmlsanitizer.Attribute{Name: "name", Value: "x"})
+			t.Data = "ds-alert"
+		}
+		return append(dst, t)
+	})
+
+	data := `<ds-callout>a</ds-callout>`
+	expected := `<ds-alert variant="warning">a</ds-callout>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+}
+
+func TestCustomElementsCompose(t *testing.T) {
+	a := &htmlsanitizer.AllowList{CustomElements: []*htmlsanitizer.CustomElement{
+		{Pattern: htmlsanitizer.CustomElementPrefix("ds"), Attr: []string{"variant", "title"}},
+		{Pattern: htmlsanitizer.CustomElementPrefix("x")},
+	}}
+	b := &htmlsanitizer.AllowList{CustomElements: []*htmlsanitizer.CustomElement{
+		{Pattern: htmlsanitizer.CustomElementPrefix("ds"), Attr: []string{"variant", "name"}},
+	}}
+
+	cases := []struct {
+		name     string
+		list     *htmlsanitizer.AllowList
+		expected string
+	}{
+		{"Merge", a.Merge(b), `<ds-card variant="1" title="2" name="3"></ds-card><x-card></x-card>`},
+		{"Intersect", a.Intersect(b), `<ds-card variant="1"></ds-card>`},
+		{"Subtract", a.Subtract(b), `<x-card></x-card>`},
+	}
+
+	data := `<ds-card variant="1" title="2" name="3"></ds-card><x-card></x-card>`
+	for _, c := range cases {
+		if err := c.list.Validate(); err != nil {
+			t.Errorf("%s: expect a valid AllowList, got %s", c.name, err)
+		}
+		ret, _ := (&htmlsanitizer.HTMLSanitizer{AllowList: c.list}).SanitizeString(data)
+		if ret != c.expected {
+			t.Errorf("%s: expect %#v, got %#v", c.name, c.expected, ret)
+		}
+	}
+
+	if len(a.CustomElements[0].Attr) != 2 || len(b.CustomElements) != 1 {
+		t.Errorf("expect the operands not modified, got %+v and %+v", a.CustomElements, b.CustomElements)
+	}
+}
+
+func TestCustomElementsJSON(t *testing.T) {
+	list := &htmlsanitizer.AllowList{
+		CustomElements: []*htmlsanitizer.CustomElement{{
+			Pattern:     regexp.MustCompile(`^ds-[a-z]+$`),
+			Attr:        []string{"variant"},
+			URLAttr:     []string{"href"},
+			AttrPattern: map[string]*regexp.Regexp{"variant": regexp.MustCompile(`^info$`)},
+			Require:     []string{"variant"},
+		}},
+	}
+
+	data, err := json.Marshal(list)
+	expected := `{"version":1,"tags":[],"globalAttr":[],"nonHTMLTags":[],"customElements":[{"pattern":"^ds-[a-z]+$",` +
+		`"attr":["variant"],"urlAttr":["href"],"attrPattern":{"variant":"^info$"},"require":["variant"]}]}`
+	if err != nil || string(data) != expected {
+		t.Errorf("expect %s, got %s, err: %v", expected, data, err)
+	}
+
+	decoded := new(htmlsanitizer.AllowList)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("unable to unmarshal err: %s", err)
+	}
+	if again, _ := json.Marshal(decoded); string(again) != expected || decoded.Fingerprint() != list.Fingerprint() {
+		t.Errorf("expect the same AllowList after unmarshal, got %+v", decoded)
+	}
+
+	// the order of the patterns matters
+	reordered := customElements()
+	reordered.CustomElements[0], reordered.CustomElements[1] = reordered.CustomElements[1], reordered.CustomElements[0]
+	if customElements().Fingerprint() == reordered.Fingerprint() {
+		t.Errorf("expect the order of the CustomElements to change the fingerprint")
+	}
+	withoutCustom := customElements()
+	withoutCustom.CustomElements = nil
+	if customElements().Fingerprint() == withoutCustom.Fingerprint() {
+		t.Errorf("expect the CustomElements to change the fingerprint")
+	}
+}
+
+func TestCustomElementsInvalid(t *testing.T) {
+	testCases := []struct {
+		in    string
+		field string
+	}{
+		{`{"version": 1, "customElements": [{"pattern": "("}]}`, "customElements[0].pattern"},
+		{`{"version": 1, "customElements": [null]}`, "customElements[0]"},
+		{`{"version": 1, "customElements": [{"pattern": "^ds-"}, {"pattern": "^ds-"}]}`, "customElements[1].pattern"},
+		{`{"version": 1, "customElements": [{"pattern": "^ds-", "attr": ["onclick"]}]}`, "customElements[0].attr[0]"},
+		{`{"version": 1, "customElements": [{"pattern": "^ds-", "attr": ["title"], "urlAttr": ["title"]}]}`, "customElements[0].urlAttr[0]"},
+		{`{"version": 1, "customElements": [{"pattern": "^ds-", "attrPattern": {"title": "^x$"}}]}`, "customElements[0].attrPattern.title"},
+		{`{"version": 1, "customElements": [{"pattern": "^ds-", "require": ["title"]}]}`, "customElements[0].require[0]"},
+		{`{"version": 1, "customElements": [{"pattern": "^ds-", "attr": ["epub:type"]}]}`, "customElements[0].attr[0]"},
+		{`{"version": 1, "customElements": [{"pattern": "^ds-", "urlAttr": ["href"]}], "globalAttr": ["href"]}`, "globalAttr[0]"},
+	}
+
+	for _, item := range testCases {
+		var list htmlsanitizer.AllowList
+		err := json.Unmarshal([]byte(item.in), &list)
+
+		var policyErr *htmlsanitizer.PolicyError
+		if !errors.As(err, &policyErr) || policyErr.Field != item.field {
+			t.Errorf("expect a PolicyError at %s for %s, got %v", item.field, item.in, err)
+		}
+	}
+
+	for field, list := range map[string]*htmlsanitizer.AllowList{
+		"customElements[0].pattern": {CustomElements: []*htmlsanitizer.CustomElement{{}}},
+		"svg.customElements":        {SVG: &htmlsanitizer.AllowList{CustomElements: []*htmlsanitizer.CustomElement{{Pattern: htmlsanitizer.CustomElementName}}}},
+	} {
+		var policyErr *htmlsanitizer.PolicyError
+		if err := list.Validate(); !errors.As(err, &policyErr) || policyErr.Field != field {
+			t.Errorf("expect a PolicyError at %s, got %v", field, err)
+		}
+	}
+}
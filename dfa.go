@@ -2,9 +2,6 @@ package htmlsanitizer
 
 import (
 	"bytes"
-	"html"
-	"io"
-	"strings"
 	"unicode"
 )
 
@@ -45,272 +42,392 @@ func legalKeywordByte(b byte) bool {
 	return true
 }
 
-type writer struct {
-	*HTMLSanitizer
-	w     io.Writer
-	state state
+// legalNameByte checks whether b is legal in tag and attribute names, which
+// may be namespaced such as xlink:href, or contain '_' and '.' such as v_on.
+// The tag names still start with legalKeywordByte.
+func legalNameByte(b byte) bool {
+	return b == ':' || b == '_' || b == '.' || legalKeywordByte(b)
+}
+
+// attrNameByte checks whether b is in an attribute name. As browsers do, the
+// attribute names consume all the bytes except the whitespaces, '/', '>' and
+// '=', so a name like x@class is never split into another attribute, and
+// only the whole name is checked against the AllowList. NUL is kept in the
+// name, which browsers replace with U+FFFD.
+func attrNameByte(b byte) bool {
+	switch b {
+	case ' ', '\t', '\n', '\f', '\r', '/', '>', '=':
+		return false
+	}
+	return true
+}
+
+// equalLower checks whether the lowercase of p equals to s.
+func equalLower(p []byte, s string) bool {
+	if len(p) != len(s) {
+		return false
+	}
+
+	for i, b := range p {
+		if 'A' <= b && b <= 'Z' {
+			b += 'a' - 'A'
+		}
+		if b != s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	ltSign     = []byte(`<`)
+	endTagSign = []byte(`</`)
+)
+
+// rawAttr locates an attribute in attrBuf, of which the lowercase name is
+// attrBuf[start:nameEnd], and the value is attrBuf[nameEnd:end].
+type rawAttr struct {
+	start, nameEnd, end int
+
+	bare bool
+	pos  Position
+}
+
+// tokenHandler handles the tokens found by the lexer.
+type tokenHandler interface {
+	// startTagName is called once the name of a start tag is read, and
+	// reports whether the attributes of the tag are needed.
+	startTagName() bool
+
+	// rawTextElement returns the lowercase tag name if the tag is one of the
+	// elements containing raw text, such as <script>, otherwise "".
+	rawTextElement(name []byte) string
+
+	startTag(selfClosing bool) error
+	endTag() error
+	text(p []byte, pos Position) error
+	rawText(p []byte, pos Position) error
+
+	// flush is called once a token is completed, or all the input data is
+	// consumed.
+	flush() error
+}
+
+// lexer is the state machine splitting the HTML content into tokens, in
+// O(n) time. The HTML content can be split into multiple chunks.
+type lexer struct {
+	h      tokenHandler
+	limits Limits
+	state  state
 
 	// input data
 	data []byte
 	off  int
 
 	// tmp data
-	tagName    []byte
-	tag        *Tag
-	nonHTMLTag *Tag
-	attr       []byte
-	val        []byte
-	quote      byte
-	lastByte   byte // last byte for ATTRGAP
+	tagName  []byte
+	rawTag   string // name of the current raw text element
+	collect  bool   // whether to collect attributes of the current tag
+	attr     []byte
+	val      []byte
+	quote    byte
+	lastByte byte // last byte for ATTRGAP
+
+	// attributes of the current tag
+	attrs   []rawAttr
+	attrBuf []byte
+
+	// position of data
+	consumed int64 // bytes consumed by previous writes
+	trackPos bool
+	cur      cursor
+	tagPos   Position
+	attrPos  Position
+
+	// resource usage for limits
+	attrCnt  int
+	limitErr *LimitError
+}
 
-	// buf for write
-	buf []byte
+func newLexer(h tokenHandler, limits Limits, trackPos bool) lexer {
+	var l lexer
+	l.reset(h, limits, trackPos)
+	return l
 }
 
-func (w *writer) flush() (n int, err error) {
-	if len(w.buf) == 0 {
-		return
+// reset resets the lexer to its initial state, but keeps the allocated
+// buffers.
+func (l *lexer) reset(h tokenHandler, limits Limits, trackPos bool) {
+	*l = lexer{
+		h:        h,
+		limits:   limits,
+		trackPos: trackPos,
+		cur:      newCursor(),
+
+		tagName: l.tagName[:0],
+		attr:    l.attr[:0],
+		val:     l.val[:0],
+		attrs:   l.attrs[:0],
+		attrBuf: l.attrBuf[:0],
 	}
+}
 
-	n, err = w.w.Write(w.buf)
+// position returns the Position of data[off]. Only the Offset is available
+// if positions are not tracked.
+func (l *lexer) position(off int) Position {
+	if !l.trackPos {
+		return Position{Offset: l.consumed + int64(off)}
+	}
+	return l.cur.at(l.data, off)
+}
 
-	// reset buf
-	w.buf = w.buf[:0]
-	return
+// advance moves the position forward by the first n bytes of data.
+func (l *lexer) advance(n int) {
+	if l.trackPos {
+		l.cur.next(l.data[:n])
+	}
+	l.consumed += int64(n)
 }
 
-func (w *writer) safeAppend(p []byte) {
-	danger := false
-	for _, b := range p {
-		switch b {
-		case '\'', '<', '>', '"':
-			danger = true
-		}
+// limitError creates a LimitError at the current offset, and makes all the
+// subsequent writes fail.
+func (l *lexer) limitError(kind LimitKind, limit int64) error {
+	l.limitErr = &LimitError{
+		Kind:     kind,
+		Limit:    limit,
+		Position: l.position(l.off),
 	}
+	return l.limitErr
+}
 
-	// fast path
-	if !danger {
-		w.buf = append(w.buf, p...)
-		return
+// markTag records the position of a tag starting at the current offset.
+func (l *lexer) markTag() {
+	if l.trackPos {
+		l.tagPos = l.cur.at(l.data, l.off)
 	}
+}
 
-	for _, b := range p {
-		switch b {
-		case '\'':
-			w.buf = append(w.buf, `&#39;`...)
-		case '<':
-			w.buf = append(w.buf, `&lt;`...)
-		case '>':
-			w.buf = append(w.buf, `&gt;`...)
-		case '"':
-			w.buf = append(w.buf, `&#34;`...)
-		default:
-			w.buf = append(w.buf, b)
-		}
+func (l *lexer) checkTagName() error {
+	if max := l.limits.MaxTagNameLen; max > 0 && len(l.tagName) > max {
+		return l.limitError(LimitTagNameLen, int64(max))
 	}
+	return nil
 }
 
-// write tag attribute and its sanitzed value if legal.
-func (w *writer) safeAppendAttr() {
-	if w.tag == nil {
-		return
+func (l *lexer) checkAttrVal() error {
+	if max := l.limits.MaxAttrValueLen; max > 0 && len(l.val) > max {
+		return l.limitError(LimitAttrValueLen, int64(max))
 	}
+	return nil
+}
+
+// newAttr counts a new attribute for the current tag.
+func (l *lexer) newAttr() error {
+	if l.trackPos {
+		l.attrPos = l.cur.at(l.data, l.off)
+	}
+
+	l.attrCnt++
+	if max := l.limits.MaxAttrsPerTag; max > 0 && l.attrCnt > max {
+		return l.limitError(LimitAttrsPerTag, int64(max))
+	}
+	return nil
+}
 
-	attrName := bytes.ToLower(w.attr)
-	ok, urlAttr := w.tag.attrExists(attrName)
-	if !ok && !w.attrExists(attrName) {
+// endAttr saves the current attribute for the current tag.
+func (l *lexer) endAttr(bare bool) {
+	if !l.collect {
 		return
 	}
 
-	attrVal := w.val
-	if urlAttr {
-		// unescape first
-		rawURL := html.UnescapeString(string(w.val))
-		newURL, ok := w.urlSanitizer(rawURL)
-		if !ok {
-			return
+	a := rawAttr{start: len(l.attrBuf), bare: bare, pos: l.attrPos}
+	for _, b := range l.attr {
+		if 'A' <= b && b <= 'Z' {
+			b += 'a' - 'A'
 		}
-		attrVal = []byte(newURL)
+		l.attrBuf = append(l.attrBuf, b)
+	}
+	a.nameEnd = len(l.attrBuf)
+	if !bare {
+		l.attrBuf = append(l.attrBuf, l.val...)
 	}
+	a.end = len(l.attrBuf)
 
-	w.buf = append(w.buf, ' ')
-	w.buf = append(w.buf, attrName...)
-	w.buf = append(w.buf, `="`...)
-	w.safeAppend(attrVal)
-	w.buf = append(w.buf, '"')
+	l.attrs = append(l.attrs, a)
 }
 
-func (w *writer) shouldKeepNonHTMLContent() bool {
-	return w.nonHTMLTag != nil && w.tag != nil && w.nonHTMLTag.Name == w.tag.Name
+func (l *lexer) isEndTagOfNonHTMLElement(p []byte) bool {
+	return l.rawTag != "" && equalLower(p, l.rawTag)
 }
 
-func (w *writer) isEndTagOfNonHTMLElement(p []byte) bool {
-	if w.nonHTMLTag == nil {
-		return false
+// feed the next chunk of the HTML content.
+func (l *lexer) feed(p []byte) (n int, err error) {
+	if l.limitErr != nil {
+		return 0, l.limitErr
+	}
+
+	truncated := false
+	if max := l.limits.MaxInputBytes; max > 0 && l.consumed+int64(len(p)) > max {
+		p = p[:max-l.consumed]
+		truncated = true
 	}
-	return w.nonHTMLTag.Name == strings.ToLower(string(p))
-}
 
-func (w *writer) Write(p []byte) (n int, err error) {
 	// reset data
-	w.data = p
-	w.off = 0
+	l.data = p
+	l.off = 0
 
-	for err == nil && w.off < len(p) {
-		switch w.state {
+	for err == nil && l.off < len(p) {
+		switch l.state {
 		case sNORMAL:
-			err = w.sNORMAL()
+			err = l.sNORMAL()
 		case sNONHTML:
-			err = w.sNONHTML()
+			err = l.sNONHTML()
 		case sLTSIGN:
-			err = w.sLTSIGN()
+			err = l.sLTSIGN()
 		case sTAGNAME:
-			err = w.sTAGNAME()
+			err = l.sTAGNAME()
 		case sTAGEND:
-			err = w.sTAGEND()
+			err = l.sTAGEND()
 		case sATTRGAP:
-			err = w.sATTRGAP()
+			err = l.sATTRGAP()
 		case sATTRNAME:
-			err = w.sATTRNAME()
+			err = l.sATTRNAME()
 		case sEQUALSIGN:
-			err = w.sEQUALSIGN()
+			err = l.sEQUALSIGN()
 		case sATTRSPACE:
-			err = w.sATTRSPACE()
+			err = l.sATTRSPACE()
 		case sATTRVAL:
-			err = w.sATTRVAL()
+			err = l.sATTRVAL()
 		case sVALSPACE:
-			err = w.sVALSPACE()
+			err = l.sVALSPACE()
 		case sATTRQVAL:
-			err = w.sATTRQVAL()
+			err = l.sATTRQVAL()
 		case sETAGSTART:
-			err = w.sETAGSTART()
+			err = l.sETAGSTART()
 		case sETAGNAME:
-			err = w.sETAGNAME()
+			err = l.sETAGNAME()
 		case sERRTAG:
-			err = w.sERRTAG()
+			err = l.sERRTAG()
 		case sETAGATTR:
-			err = w.sETAGATTR()
+			err = l.sETAGATTR()
 		case sETAGEND:
-			err = w.sETAGEND()
+			err = l.sETAGEND()
 		default:
 			panic("unknown state")
 		}
 	}
 
-	n = w.off
+	if err == nil && truncated {
+		err = l.limitError(LimitInputBytes, l.limits.MaxInputBytes)
+	}
+
+	n = l.off
+	l.advance(n)
 	return
 }
 
-func (w *writer) sNORMAL() (err error) {
-	for ; w.off < len(w.data); w.off++ {
-		switch b := w.data[w.off]; b {
-		case '<':
-			w.state = sLTSIGN
-			w.off++
-
-			_, err = w.flush()
-			return
-		case '>':
-			w.buf = append(w.buf, `&gt;`...)
-		default:
-			w.buf = append(w.buf, b)
+func (l *lexer) sNORMAL() error {
+	start := l.off
+	i := bytes.IndexByte(l.data[start:], '<')
+	if i < 0 {
+		l.off = len(l.data)
+		if err := l.h.text(l.data[start:], l.position(start)); err != nil {
+			return err
 		}
+		return l.h.flush()
 	}
 
-	_, err = w.flush()
-	return
-}
+	l.off = start + i
+	if err := l.h.text(l.data[start:l.off], l.position(start)); err != nil {
+		return err
+	}
 
-func (w *writer) sNONHTML() (err error) {
-	for ; w.off < len(w.data); w.off++ {
-		switch b := w.data[w.off]; b {
-		case '<':
-			w.state = sLTSIGN
-			w.off++
+	l.markTag()
+	l.state = sLTSIGN
+	l.off++
 
-			_, err = w.flush()
-			return
-		case '>':
-			if w.shouldKeepNonHTMLContent() {
-				w.buf = append(w.buf, `&gt;`...)
-			}
-		default:
-			if w.shouldKeepNonHTMLContent() {
-				w.buf = append(w.buf, b)
-			}
+	return l.h.flush()
+}
+
+func (l *lexer) sNONHTML() error {
+	start := l.off
+	i := bytes.IndexByte(l.data[start:], '<')
+	if i < 0 {
+		l.off = len(l.data)
+		if err := l.h.rawText(l.data[start:], l.position(start)); err != nil {
+			return err
 		}
+		return l.h.flush()
 	}
 
-	_, err = w.flush()
-	return
+	l.off = start + i
+	if err := l.h.rawText(l.data[start:l.off], l.position(start)); err != nil {
+		return err
+	}
+
+	l.markTag()
+	l.state = sLTSIGN
+	l.off++
+
+	return l.h.flush()
 }
 
-func (w *writer) sLTSIGN() error {
-	switch b := w.data[w.off]; {
+func (l *lexer) sLTSIGN() error {
+	switch b := l.data[l.off]; {
 	case b == '/':
-		w.off++
+		l.off++
 
-		w.state = sETAGSTART
-		w.tagName = nil
+		l.state = sETAGSTART
+		l.tagName = l.tagName[:0]
 
-	case w.nonHTMLTag != nil:
-		w.state = sNONHTML
-		if w.shouldKeepNonHTMLContent() {
-			w.buf = append(w.buf, `&lt;`...)
-		}
+	case l.rawTag != "":
+		l.state = sNONHTML
+		return l.h.rawText(ltSign, l.tagPos)
 
 	default:
-		w.off++
+		l.off++
 
 		if legalKeywordByte(b) {
-			w.state = sTAGNAME
-			if len(w.tagName) > 0 {
-				w.tagName = w.tagName[:0]
-			}
-			w.tagName = append(w.tagName, b)
-			w.tag = nil
+			l.state = sTAGNAME
+			l.tagName = append(l.tagName[:0], b)
+			l.attrCnt = 0
 			return nil
 		}
 
-		w.state = sERRTAG
+		l.state = sERRTAG
 	}
 	return nil
 }
 
-func (w *writer) sTAGNAME() error {
-	for ; w.off < len(w.data); w.off++ {
-		switch b := w.data[w.off]; b {
-		case '>':
-			w.tag = w.FindTag(w.tagName)
-			w.nonHTMLTag = w.checkNonHTMLTag(w.tagName)
-			// no w.off++
-			w.state = sTAGEND
-
-			if w.tag == nil {
-				return nil
-			}
+// beginAttrs gets ready for the attributes of the current start tag.
+func (l *lexer) beginAttrs() {
+	l.collect = l.h.startTagName()
+	l.attrs = l.attrs[:0]
+	l.attrBuf = l.attrBuf[:0]
+}
 
-			w.buf = append(w.buf, '<')
-			w.buf = append(w.buf, w.tag.Name...)
+func (l *lexer) sTAGNAME() error {
+	for ; l.off < len(l.data); l.off++ {
+		switch b := l.data[l.off]; b {
+		case '>':
+			l.beginAttrs()
+			// no l.off++
+			l.state = sTAGEND
 			return nil
 
 		default:
-			if legalKeywordByte(b) {
-				w.tagName = append(w.tagName, b)
+			if legalNameByte(b) {
+				l.tagName = append(l.tagName, b)
+				if err := l.checkTagName(); err != nil {
+					return err
+				}
 				continue
 			}
-			w.off++
-			w.state = sATTRGAP
-			w.lastByte = b
+			l.off++
+			l.state = sATTRGAP
+			l.lastByte = b
 
-			w.tag = w.FindTag(w.tagName)
-			w.nonHTMLTag = w.checkNonHTMLTag(w.tagName)
-			if w.tag == nil {
-				return nil
-			}
-
-			w.buf = append(w.buf, '<')
-			w.buf = append(w.buf, w.tag.Name...)
+			l.beginAttrs()
 			return nil
 		}
 	}
@@ -318,171 +435,136 @@ func (w *writer) sTAGNAME() error {
 	return nil
 }
 
-func (w *writer) sTAGEND() error {
-	// eat '>'
-	w.off++
-	w.state = sNORMAL
-
-	if w.nonHTMLTag != nil {
-		if w.lastByte == '/' {
-			w.nonHTMLTag = nil
-		} else {
-			w.state = sNONHTML
-		}
+func (l *lexer) sTAGEND() error {
+	selfClosing := l.lastByte == '/'
+	l.lastByte = 0
+
+	if err := l.h.startTag(selfClosing); err != nil {
+		return err
 	}
 
-	if w.tag == nil {
-		// illegal tag, just reset the buf
-		if len(w.buf) > 0 {
-			w.buf = w.buf[:0]
+	// eat '>'
+	l.off++
+	l.state = sNORMAL
+
+	if !selfClosing {
+		if l.rawTag = l.h.rawTextElement(l.tagName); l.rawTag != "" {
+			l.state = sNONHTML
 		}
-		w.lastByte = 0
-		return nil
 	}
 
-	if w.lastByte == '/' {
-		w.buf = append(w.buf, ` /`...)
-		w.lastByte = 0
-	}
-	w.buf = append(w.buf, '>')
-	_, err := w.flush()
-	return err
+	return l.h.flush()
 }
 
-func (w *writer) sATTRGAP() error {
-	for ; w.off < len(w.data); w.off++ {
-		switch b := w.data[w.off]; {
+func (l *lexer) sATTRGAP() error {
+	for ; l.off < len(l.data); l.off++ {
+		switch b := l.data[l.off]; {
 		case b == '>':
-			// no w.off++
-			w.state = sTAGEND
+			// no l.off++
+			l.state = sTAGEND
 			return nil
 
-		case legalKeywordByte(b):
-			w.off++
-
-			// reset
-			if len(w.attr) > 0 {
-				w.attr = w.attr[:0]
+		// '=' starts an attribute name here
+		case b == '=' || attrNameByte(b):
+			if err := l.newAttr(); err != nil {
+				return err
 			}
+			l.off++
 
-			w.attr = append(w.attr, b)
-			w.state = sATTRNAME
+			l.attr = append(l.attr[:0], b)
+			l.state = sATTRNAME
 			return nil
 
 		default:
-			w.lastByte = b
+			l.lastByte = b
 		}
 	}
 	return nil
 }
 
-func (w *writer) sATTRNAME() error {
-	for ; w.off < len(w.data); w.off++ {
-		switch b := w.data[w.off]; {
+func (l *lexer) sATTRNAME() error {
+	for ; l.off < len(l.data); l.off++ {
+		switch b := l.data[l.off]; {
 		case b == '=':
-			w.off++
-			w.state = sEQUALSIGN
-			return nil
-		case unicode.IsSpace(rune(b)):
-			w.off++
-			w.state = sATTRSPACE
+			l.off++
+			l.state = sEQUALSIGN
 			return nil
-		case legalKeywordByte(b):
-			w.attr = append(w.attr, b)
+		case attrNameByte(b):
+			l.attr = append(l.attr, b)
 			continue
+		case b != '/' && b != '>':
+			l.off++
+			l.state = sATTRSPACE
+			return nil
 		default:
-			// no w.off++
-			w.lastByte = 0
-			w.state = sATTRGAP
-
-			if w.tag == nil {
-				return nil
-			}
+			// no l.off++
+			l.lastByte = 0
+			l.state = sATTRGAP
 
 			// name only attribute for HTML5
-			attrName := bytes.ToLower(w.attr)
-			if ok, _ := w.tag.attrExists(attrName); ok || w.attrExists(attrName) {
-				w.buf = append(w.buf, ' ')
-				w.buf = append(w.buf, attrName...)
-			}
+			l.endAttr(true)
 			return nil
 		}
 	}
 	return nil
 }
 
-func (w *writer) sEQUALSIGN() error {
+func (l *lexer) sEQUALSIGN() error {
 	// reset
-	if len(w.val) > 0 {
-		w.val = w.val[:0]
-	}
+	l.val = l.val[:0]
 
-	switch b := w.data[w.off]; {
+	switch b := l.data[l.off]; {
 	case b == '>':
-		// no w.off++
-		w.safeAppendAttr()
-		w.state = sTAGEND
+		// no l.off++
+		l.endAttr(false)
+		l.state = sTAGEND
 	case b == '\'' || b == '"':
-		w.off++
-		w.quote = b
-		w.state = sATTRQVAL
+		l.off++
+		l.quote = b
+		l.state = sATTRQVAL
 	case unicode.IsSpace(rune(b)):
-		w.off++
-		w.state = sVALSPACE
+		l.off++
+		l.state = sVALSPACE
 	default:
-		w.off++
-		w.state = sATTRVAL
-		w.val = append(w.val, b)
+		l.off++
+		l.state = sATTRVAL
+		l.val = append(l.val, b)
 	}
 
 	return nil
 }
 
-func (w *writer) sATTRSPACE() error {
-	for ; w.off < len(w.data); w.off++ {
-		switch b := w.data[w.off]; {
+func (l *lexer) sATTRSPACE() error {
+	for ; l.off < len(l.data); l.off++ {
+		switch b := l.data[l.off]; {
 		case b == '=':
-			w.off++
-			w.state = sEQUALSIGN
+			l.off++
+			l.state = sEQUALSIGN
 			return nil
 		case unicode.IsSpace(rune(b)):
 			continue
-		case legalKeywordByte(b), b == '>':
-			if w.tag != nil {
-				attrName := bytes.ToLower(w.attr)
-				ok, urlAttr := w.tag.attrExists(attrName)
-				if (ok && !urlAttr) || w.attrExists(attrName) {
-					w.buf = append(w.buf, ' ')
-					w.buf = append(w.buf, attrName...)
-				}
-			}
+		case attrNameByte(b), b == '>':
+			l.endAttr(true)
 
 			if b == '>' {
-				// no w.off++
-				w.state = sTAGEND
+				// no l.off++
+				l.state = sTAGEND
 				return nil
 			}
 
-			w.off++
-			if len(w.attr) > 0 {
-				w.attr = w.attr[:0]
+			if err := l.newAttr(); err != nil {
+				return err
 			}
-			w.attr = append(w.attr, b)
-			w.state = sATTRNAME
+			l.off++
+			l.attr = append(l.attr[:0], b)
+			l.state = sATTRNAME
 			return nil
 		default:
-			if w.tag != nil {
-				attrName := bytes.ToLower(w.attr)
-				ok, urlAttr := w.tag.attrExists(attrName)
-				if (ok && !urlAttr) || w.attrExists(attrName) {
-					w.buf = append(w.buf, ' ')
-					w.buf = append(w.buf, attrName...)
-				}
-			}
+			l.endAttr(true)
 
-			w.off++
-			w.lastByte = b
-			w.state = sATTRGAP
+			l.off++
+			l.lastByte = b
+			l.state = sATTRGAP
 			return nil
 		}
 	}
@@ -490,158 +572,148 @@ func (w *writer) sATTRSPACE() error {
 	return nil
 }
 
-func (w *writer) sATTRVAL() error {
-	for ; w.off < len(w.data); w.off++ {
-		switch b := w.data[w.off]; {
+func (l *lexer) sATTRVAL() error {
+	for ; l.off < len(l.data); l.off++ {
+		switch b := l.data[l.off]; {
 		case b == '>':
-			// no w.off++
-			w.state = sTAGEND
-			w.safeAppendAttr()
+			// no l.off++
+			l.state = sTAGEND
+			l.endAttr(false)
 			return nil
 		case unicode.IsSpace(rune(b)):
-			w.off++
-			w.lastByte = 0
-			w.state = sATTRGAP
-			w.safeAppendAttr()
+			l.off++
+			l.lastByte = 0
+			l.state = sATTRGAP
+			l.endAttr(false)
 			return nil
 		default:
-			w.val = append(w.val, b)
+			l.val = append(l.val, b)
+			if err := l.checkAttrVal(); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
-func (w *writer) sVALSPACE() error {
-	for ; w.off < len(w.data); w.off++ {
-		switch b := w.data[w.off]; {
+func (l *lexer) sVALSPACE() error {
+	for ; l.off < len(l.data); l.off++ {
+		switch b := l.data[l.off]; {
 		case b == '>':
-			// no w.off++
-			w.safeAppendAttr()
-			w.state = sTAGEND
+			// no l.off++
+			l.endAttr(false)
+			l.state = sTAGEND
 			return nil
 		case b == '\'' || b == '"':
-			w.off++
-			w.quote = b
-			w.state = sATTRQVAL
+			l.off++
+			l.quote = b
+			l.state = sATTRQVAL
 			return nil
 		case unicode.IsSpace(rune(b)):
 			continue
 		default:
-			w.off++
-			w.state = sATTRVAL
-			// reset
-			if len(w.val) > 0 {
-				w.val = w.val[:0]
-			}
-			w.val = append(w.val, b)
+			l.off++
+			l.state = sATTRVAL
+			l.val = append(l.val[:0], b)
 			return nil
 		}
 	}
 	return nil
 }
 
-func (w *writer) sATTRQVAL() error {
-	for ; w.off < len(w.data); w.off++ {
-		switch b := w.data[w.off]; b {
-		case w.quote:
-			w.off++
-			w.safeAppendAttr()
-			w.lastByte = 0
-			w.state = sATTRGAP
-			return nil
-		default:
-			w.val = append(w.val, b)
-		}
+func (l *lexer) sATTRQVAL() error {
+	start := l.off
+	end := bytes.IndexByte(l.data[start:], l.quote)
+	if end < 0 {
+		end = len(l.data)
+	} else {
+		end += start
+	}
+
+	// fail at the first byte exceeding the limit
+	if max := l.limits.MaxAttrValueLen; max > 0 && len(l.val)+end-start > max {
+		l.off = start + max - len(l.val)
+		l.val = append(l.val, l.data[start:l.off+1]...)
+		return l.checkAttrVal()
+	}
+
+	l.val = append(l.val, l.data[start:end]...)
+	l.off = end
+	if end == len(l.data) {
+		return nil
 	}
+
+	l.off++
+	l.endAttr(false)
+	l.lastByte = 0
+	l.state = sATTRGAP
 	return nil
 }
 
-func (w *writer) sETAGSTART() error {
-	switch b := w.data[w.off]; {
+func (l *lexer) sETAGSTART() error {
+	switch b := l.data[l.off]; {
 	case legalKeywordByte(b):
-		w.off++
-		// reset
-		if len(w.tagName) > 0 {
-			w.tagName = w.tagName[:0]
-		}
-		w.tagName = append(w.tagName, b)
-		w.state = sETAGNAME
+		l.off++
+		l.tagName = append(l.tagName[:0], b)
+		l.state = sETAGNAME
 
-	case w.nonHTMLTag != nil:
-		w.state = sNONHTML
-		if w.shouldKeepNonHTMLContent() {
-			w.buf = append(w.buf, `&lt;/`...)
-		}
+	case l.rawTag != "":
+		l.state = sNONHTML
+		return l.h.rawText(endTagSign, l.tagPos)
 
 	default:
-		// no w.off++
-		w.state = sERRTAG
+		// no l.off++
+		l.state = sERRTAG
 	}
 
 	return nil
 }
 
-func (w *writer) sETAGNAME() error {
-	for ; w.off < len(w.data); w.off++ {
-		switch b := w.data[w.off]; {
+// otherEndTagInNonHTML treats the end tag in the content of a raw text
+// element as raw text.
+func (l *lexer) otherEndTagInNonHTML() error {
+	l.state = sNONHTML
+	if err := l.h.rawText(endTagSign, l.tagPos); err != nil {
+		return err
+	}
+	return l.h.rawText(l.tagName, l.tagPos)
+}
+
+func (l *lexer) sETAGNAME() error {
+	for ; l.off < len(l.data); l.off++ {
+		switch b := l.data[l.off]; {
 		case b == '>':
-			if w.nonHTMLTag != nil {
-				if w.isEndTagOfNonHTMLElement(w.tagName) {
-					w.nonHTMLTag = nil
-				} else {
-					if w.shouldKeepNonHTMLContent() {
-						w.safeAppend([]byte(`</`))
-						w.safeAppend(w.tagName)
-					}
-					w.state = sNONHTML
-					return nil
+			if l.rawTag != "" {
+				if !l.isEndTagOfNonHTMLElement(l.tagName) {
+					return l.otherEndTagInNonHTML()
 				}
+				l.rawTag = ""
 			}
 
-			w.tag = w.FindTag(w.tagName)
-			if w.tag == nil {
-				// no w.off++
-				w.state = sERRTAG
-				return nil
-			}
-
-			// no w.off++
-			w.state = sETAGEND
-			w.buf = append(w.buf, `</`...)
-			w.buf = append(w.buf, w.tag.Name...)
+			// no l.off++
+			l.state = sETAGEND
 			return nil
 
-		case legalKeywordByte(b):
-			w.tagName = append(w.tagName, b)
+		case legalNameByte(b):
+			l.tagName = append(l.tagName, b)
+			if err := l.checkTagName(); err != nil {
+				return err
+			}
 			continue
 
-		case w.nonHTMLTag != nil:
-			if !w.isEndTagOfNonHTMLElement(w.tagName) {
+		case l.rawTag != "":
+			if !l.isEndTagOfNonHTMLElement(l.tagName) {
 				// all other tags
-				if w.shouldKeepNonHTMLContent() {
-					w.safeAppend([]byte(`</`))
-					w.safeAppend(w.tagName)
-				}
-				w.state = sNONHTML
-				return nil
+				return l.otherEndTagInNonHTML()
 			}
 
 			// is end tag of non-html element
-			w.nonHTMLTag = nil
+			l.rawTag = ""
 			fallthrough
 
 		default:
-			w.tag = w.FindTag(w.tagName)
-			if w.tag == nil {
-				// no w.off++
-				w.state = sERRTAG
-				return nil
-			}
-
-			w.off++
-			w.state = sETAGATTR
-			w.buf = append(w.buf, `</`...)
-			w.buf = append(w.buf, w.tag.Name...)
+			l.off++
+			l.state = sETAGATTR
 			return nil
 		}
 	}
@@ -649,15 +721,11 @@ func (w *writer) sETAGNAME() error {
 	return nil
 }
 
-func (w *writer) sERRTAG() error {
-	for ; w.off < len(w.data); w.off++ {
-		if w.data[w.off] == '>' {
-			w.off++
-			w.state = sNORMAL
-			// reset
-			if len(w.buf) > 0 {
-				w.buf = w.buf[:0]
-			}
+func (l *lexer) sERRTAG() error {
+	for ; l.off < len(l.data); l.off++ {
+		if l.data[l.off] == '>' {
+			l.off++
+			l.state = sNORMAL
 			return nil
 		}
 	}
@@ -665,11 +733,11 @@ func (w *writer) sERRTAG() error {
 	return nil
 }
 
-func (w *writer) sETAGATTR() error {
-	for ; w.off < len(w.data); w.off++ {
-		if w.data[w.off] == '>' {
-			// no w.off++
-			w.state = sETAGEND
+func (l *lexer) sETAGATTR() error {
+	for ; l.off < len(l.data); l.off++ {
+		if l.data[l.off] == '>' {
+			// no l.off++
+			l.state = sETAGEND
 			return nil
 		}
 	}
@@ -677,12 +745,14 @@ func (w *writer) sETAGATTR() error {
 	return nil
 }
 
-func (w *writer) sETAGEND() error {
+func (l *lexer) sETAGEND() error {
+	if err := l.h.endTag(); err != nil {
+		return err
+	}
+
 	// eat '>'
-	w.off++
+	l.off++
+	l.state = sNORMAL
 
-	w.state = sNORMAL
-	w.buf = append(w.buf, '>')
-	_, err := w.flush()
-	return err
+	return l.h.flush()
 }
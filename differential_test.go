@@ -0,0 +1,94 @@
+package htmlsanitizer_test
+
+import (
+	"bufio"
+	"flag"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files")
+
+// differentialPieces are the pieces of the random HTML content, which are
+// likely to hit the edge cases of the state machine.
+var differentialPieces = []string{
+	"<", ">", "</", "/>", "/", "=", " ", "\t", "\n", `"`, "'", "!--", "-->", "<!doctype html>",
+	"a", "b", "p", "P", "img", "script", "STYLE", "object", "span", "x-y", "1",
+	"href", "src", "class", "id", "onerror", "rel", "colspan",
+	"http://example.com/", "javascript:alert(1)", "&lt;", "&#34;", "&amp;", "é", "中",
+}
+
+// randomHTML generates the random HTML content with a deterministic source.
+func randomHTML(r *rand.Rand) string {
+	var sb strings.Builder
+	for n := r.Intn(32); n >= 0; n-- {
+		if r.Intn(8) == 0 {
+			sb.WriteString(strings.Repeat("text ", r.Intn(8)))
+			continue
+		}
+		sb.WriteString(differentialPieces[r.Intn(len(differentialPieces))])
+	}
+	return sb.String()
+}
+
+// TestDifferential compares the sanitized output of random HTML content,
+// written in random chunks, with the golden file generated by the original
+// byte-by-byte implementation.
+func TestDifferential(t *testing.T) {
+	const golden = "testdata/differential.golden"
+	r := rand.New(rand.NewSource(1))
+
+	var outputs []string
+	for i := 0; i < 500; i++ {
+		data := randomHTML(r)
+
+		o := new(strings.Builder)
+		w := htmlsanitizer.NewWriter(o)
+		for rest := data; len(rest) > 0; {
+			n := 1 + r.Intn(len(rest))
+			if _, err := w.Write([]byte(rest[:n])); err != nil {
+				t.Fatalf("unable to Write err: %s", err)
+			}
+			rest = rest[n:]
+		}
+
+		ret, err := htmlsanitizer.SanitizeString(data)
+		if err != nil {
+			t.Fatalf("unable to SanitizeString err: %s", err)
+		}
+		if ret != o.String() {
+			t.Errorf("chunked output differs for %#v, expect %#v, got %#v", data, ret, o.String())
+		}
+		outputs = append(outputs, strconv.Quote(data)+" "+strconv.Quote(ret))
+	}
+
+	if *updateGolden {
+		if err := ioutil.WriteFile(golden, []byte(strings.Join(outputs, "\n")+"\n"), 0644); err != nil {
+			t.Fatalf("unable to update golden file err: %s", err)
+		}
+		return
+	}
+
+	f, err := os.Open(golden)
+	if err != nil {
+		t.Fatalf("unable to open golden file err: %s", err)
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for i := 0; s.Scan(); i++ {
+		if i >= len(outputs) {
+			t.Fatalf("too many lines in golden file")
+		}
+		if s.Text() != outputs[i] {
+			t.Errorf("output differs, expect %s, got %s", s.Text(), outputs[i])
+		}
+	}
+}
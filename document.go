@@ -0,0 +1,329 @@
+package htmlsanitizer
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Document specifies the full-document mode of the HTMLSanitizer, in which
+// the sanitized content is always a single HTML document.
+//
+// A normalized <!DOCTYPE html> is written, followed by exactly one html,
+// head and body element, which are written even if missing in the content,
+// or not allowed by the AllowList. Their attributes allowed by the AllowList
+// are kept, and the duplicated ones in the content are removed. The content
+// of the head ends at the first element or text not belonging to it.
+//
+// Regardless of the AllowList, the base elements are always removed, the
+// meta elements are kept only in the head with the charset attribute, or
+// the name attribute of viewport or description, and the ones with the
+// http-equiv attribute are always removed. The link elements are kept only
+// as the stylesheets of the StyleSheetHosts.
+//
+// The end of the document is written when the Writer is closed, so the
+// Writer must be closed, see HTMLSanitizer.NewWriter.
+type Document struct {
+	// StyleSheetHosts are the hosts of the allowed stylesheets, e.g.
+	// cdn.example.com, must be lowercase. A link element is kept only if its
+	// rel is stylesheet and its href is an https URL of any of the hosts on
+	// the default port. If empty, the link elements are always removed.
+	StyleSheetHosts []string
+}
+
+// docState is the part of the document structure written by the writer.
+type docState int
+
+const (
+	docInitial docState = iota
+	docBeforeHead
+	docInHead
+	docInBody
+	docClosed
+)
+
+// documentTags are the tags of the document structure, which are always
+// allowed in the document mode, without any attribute if not allowed by
+// the AllowList.
+var documentTags = map[string]*Tag{
+	"html": {Name: "html"},
+	"head": {Name: "head"},
+	"body": {Name: "body"},
+}
+
+// headElements are the elements kept in the head, see
+// https://html.spec.whatwg.org/multipage/parsing.html#parsing-main-inhead .
+var headElements = map[string]bool{
+	"base":     true,
+	"link":     true,
+	"meta":     true,
+	"noscript": true,
+	"script":   true,
+	"style":    true,
+	"template": true,
+	"title":    true,
+}
+
+// documentTag returns the tag of the document structure named by name, or
+// nil.
+func documentTag(name []byte) *Tag {
+	for _, tag := range documentTags {
+		if equalLower(name, tag.Name) {
+			return tag
+		}
+	}
+	return nil
+}
+
+// styleSheetAllowed checks whether href is an https URL of any of the
+// StyleSheetHosts.
+func (d *Document) styleSheetAllowed(href string) bool {
+	u, err := url.Parse(href)
+	if err != nil || u.Scheme != "https" || u.User != nil || u.Port() != "" {
+		return false
+	}
+
+	for _, host := range d.StyleSheetHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+// documentAttrs are the sanitized attributes of a meta or link element,
+// which decide whether it's kept in the document mode.
+type documentAttrs struct {
+	charset   bool
+	httpEquiv bool
+	name      string
+	rel       string
+	href      string
+}
+
+func (a *documentAttrs) add(name, val []byte) {
+	switch string(name) {
+	case "charset":
+		a.charset = true
+	case "http-equiv":
+		a.httpEquiv = true
+	case "name":
+		a.name = strings.TrimSpace(html.UnescapeString(string(val)))
+	case "rel":
+		a.rel = strings.TrimSpace(html.UnescapeString(string(val)))
+	case "href":
+		// the sanitized URL is unescaped already
+		a.href = string(val)
+	}
+}
+
+// allowed checks whether the element tag with the attributes is kept.
+func (a *documentAttrs) allowed(d *Document, tag *Tag) bool {
+	switch tag.Name {
+	case "meta":
+		if a.httpEquiv {
+			return false
+		}
+		return a.charset || strings.EqualFold(a.name, "viewport") || strings.EqualFold(a.name, "description")
+	case "link":
+		return strings.EqualFold(a.rel, "stylesheet") && d.styleSheetAllowed(a.href)
+	}
+	return true
+}
+
+// enterDocument writes the document structure up to state.
+func (w *writer) enterDocument(state docState) {
+	if w.docState < docBeforeHead && state >= docBeforeHead {
+		w.buf = append(w.buf, `<!DOCTYPE html><html>`...)
+	}
+	if w.docState < docInHead && state >= docInHead {
+		w.buf = append(w.buf, `<head>`...)
+	}
+	if w.docState < docInBody && state >= docInBody {
+		w.buf = append(w.buf, `</head><body>`...)
+	}
+	if state > w.docState {
+		w.docState = state
+	}
+}
+
+// closeDocument writes the end of the document.
+func (w *writer) closeDocument() {
+	if w.docState == docClosed {
+		return
+	}
+
+	w.enterDocument(docInBody)
+	w.buf = append(w.buf, `</body></html>`...)
+	w.docState = docClosed
+}
+
+// structureTag writes the html, head or body start tag of t if not written
+// yet, with the attributes allowed by the AllowList. The end tags are
+// written by closeDocument.
+func (w *writer) structureTag(tag *Tag, t Token) {
+	var state docState
+	switch tag.Name {
+	case "html":
+		state = docBeforeHead
+	case "head":
+		state = docInHead
+	default:
+		state = docInBody
+	}
+
+	if t.Type == EndTagToken {
+		return
+	}
+	if w.docState >= state {
+		w.report(ViolationTag, tag.Name, nil, t.Position)
+		return
+	}
+
+	w.enterDocument(state - 1)
+	switch state {
+	case docBeforeHead:
+		w.buf = append(w.buf, `<!DOCTYPE html>`...)
+	case docInBody:
+		w.buf = append(w.buf, `</head>`...)
+	}
+
+	w.buf = append(w.buf, '<')
+	w.buf = append(w.buf, tag.Name...)
+	if tag != documentTags[tag.Name] {
+		for _, a := range t.Attr {
+			name := []byte(strings.ToLower(a.Name))
+			bare := a.Bare && len(a.Value) == 0
+			if val, ok := w.sanitizeAttr(tag, name, []byte(a.Value), bare, false, t.Position); ok {
+				w.appendAttr(name, val, bare, nil)
+			}
+		}
+	}
+	w.buf = append(w.buf, '>')
+	w.docState = state
+}
+
+// documentToken places the allowed tag of t in the document structure, and
+// checks whether it's written.
+func (w *writer) documentToken(tag *Tag, t Token) bool {
+	if documentTags[tag.Name] != nil {
+		w.structureTag(tag, t)
+		return false
+	}
+
+	head := headElements[tag.Name] && w.docState < docInBody
+	if t.Type == EndTagToken {
+		return w.docState == docInBody || (head && w.docState == docInHead)
+	}
+
+	if tag.Name == "base" || (tag.Name == "meta" && !head) {
+		w.report(ViolationTag, tag.Name, nil, t.Position)
+		return false
+	}
+
+	if head {
+		w.enterDocument(docInHead)
+	} else {
+		w.enterDocument(docInBody)
+	}
+	return true
+}
+
+// documentText places the text in the document structure, and checks
+// whether it's written. The whitespaces before the head are removed.
+func (w *writer) documentText(p []byte) bool {
+	if w.docState >= docInBody {
+		return true
+	}
+
+	for _, b := range p {
+		switch b {
+		case ' ', '\t', '\n', '\f', '\r':
+			continue
+		}
+		w.enterDocument(docInBody)
+		return true
+	}
+	return w.docState >= docInHead
+}
+
+// validDocument checks the Document.
+func validDocument(d *Document) error {
+	for i, host := range d.StyleSheetHosts {
+		u, err := url.Parse("https://" + host + "/")
+		if host == "" || err != nil || u.Host != host || u.User != nil || u.Port() != "" || strings.ToLower(host) != host {
+			return &PolicyError{Field: fmt.Sprintf("StyleSheetHosts[%d]", i), Value: host, Reason: "invalid host"}
+		}
+	}
+	return nil
+}
+
+// documentAllowList returns the AllowList of the DocumentPolicy, which
+// allows the tags of the document, and the stylesheets of the hosts if any.
+func documentAllowList(hosts []string) *AllowList {
+	extra := &AllowList{
+		Tags: []*Tag{
+			{
+				Name:        "html",
+				Attr:        []string{"lang", "dir"},
+				AttrPattern: map[string]*regexp.Regexp{"dir": regexp.MustCompile(`^(?i:ltr|rtl|auto)$`)},
+			},
+			{Name: "head"},
+			{Name: "body"},
+			{Name: "title"},
+			{
+				Name: "meta",
+				Attr: []string{"charset", "name", "content"},
+				AttrPattern: map[string]*regexp.Regexp{
+					"charset": regexp.MustCompile(`^[A-Za-z0-9._:-]{1,40}$`),
+					"name":    regexp.MustCompile(`^(?i:viewport|description)$`),
+				},
+			},
+		},
+		NonHTMLTags: []*Tag{{Name: "title"}},
+	}
+
+	if len(hosts) > 0 {
+		quoted := make([]string, 0, len(hosts))
+		for _, host := range hosts {
+			quoted = append(quoted, regexp.QuoteMeta(host))
+		}
+		// stable for the Fingerprint
+		sort.Strings(quoted)
+		extra.Tags = append(extra.Tags, &Tag{
+			Name:    "link",
+			Attr:    []string{"rel", "media"},
+			URLAttr: []string{"href"},
+			AttrPattern: map[string]*regexp.Regexp{
+				"rel":  regexp.MustCompile(`^(?i:stylesheet)$`),
+				"href": regexp.MustCompile(`^https://(?i:` + strings.Join(quoted, "|") + `)/`),
+			},
+			Require: []string{"rel", "href"},
+		})
+	}
+
+	return DefaultAllowList.Merge(extra)
+}
+
+// DocumentPolicy returns a new HTMLSanitizer in the full-document mode,
+// e.g. to sanitize the uploaded HTML pages. It allows what the
+// DefaultAllowList allows, and the html, head, body, title and meta
+// elements, with the lang and dir attributes of the html. The link elements
+// are allowed only as the stylesheets of the StyleSheetHosts. See Document
+// for more details.
+//
+// A *PolicyError is returned if any of the hosts is invalid.
+func DocumentPolicy(doc Document) (*HTMLSanitizer, error) {
+	if err := validDocument(&doc); err != nil {
+		return nil, err
+	}
+
+	doc.StyleSheetHosts = append([]string(nil), doc.StyleSheetHosts...)
+	return &HTMLSanitizer{
+		AllowList: documentAllowList(doc.StyleSheetHosts),
+		Document:  &doc,
+	}, nil
+}
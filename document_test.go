@@ -0,0 +1,267 @@
+package htmlsanitizer_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleDocumentPolicy() {
+	sanitizer, err := htmlsanitizer.DocumentPolicy(htmlsanitizer.Document{
+		StyleSheetHosts: []string{"cdn.example.com"},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	data := `<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8"><meta http-equiv="refresh" content="0;url=https://evil.example.com/"><base href="https://evil.example.com/">
+<link rel="stylesheet" href="https://cdn.example.com/site.css"><link rel="import" href="https://cdn.example.com/widget.html">
+<title>Example Domain</title>
+</head>
+<body>
+<h1>Example Domain</h1>
+</body>
+</html>`
+	output, _ := sanitizer.SanitizeString(data)
+	fmt.Println(output)
+	// Output:
+	// <!DOCTYPE html><html lang="en"><head>
+	// <meta charset="utf-8">
+	// <link rel="stylesheet" href="https://cdn.example.com/site.css">
+	// <title>Example Domain</title>
+	//
+	// </head><body>
+	// <h1>Example Domain</h1>
+	//
+	// </body></html>
+}
+
+func TestDocumentPolicy(t *testing.T) {
+	sanitizer, err := htmlsanitizer.DocumentPolicy(htmlsanitizer.Document{
+		StyleSheetHosts: []string{"cdn.example.com", "fonts.example.org"},
+	})
+	if err != nil {
+		t.Fatalf("unable to create DocumentPolicy err: %s", err)
+	}
+
+	// doc wraps the content of the head and the body
+	doc := func(head, body string) string {
+		return `<!DOCTYPE html><html><head>` + head + `</head><body>` + body + `</body></html>`
+	}
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		// the structure is always written once
+		{``, doc(``, ``)},
+		{`hello`, doc(``, `hello`)},
+		{`<!DOCTYPE html PUBLIC "-//W3C//DTD HTML 4.01//EN"> <p>a</p>`, doc(``, `<p>a</p>`)},
+		{`<title>a</title><p>b</p>`, doc(`<title>a</title>`, `<p>b</p>`)},
+		{`<html lang="en" dir="rtl" onload="x"><body class="c" id="i">a</body></html>`,
+			`<!DOCTYPE html><html lang="en" dir="rtl"><head></head><body class="c" id="i">a</body></html>`},
+		{`<p>a</p><html lang="en"><head><title>b</title></head><body class="c">c</body></html><body>d`, doc(``, `<p>a</p><title>b</title>cd`)},
+		{`<head><title>a</title></head><title>b</title><head><p>c</p></body></html>d`, doc(`<title>a</title><title>b</title>`, `<p>c</p>d`)},
+		{"<html>\n<head>\n<title>a</title>\n</head>\n<body>\nb\n</body>\n</html>\n",
+			"<!DOCTYPE html><html><head>\n<title>a</title>\n\n</head><body>\nb\n\n\n</body></html>"},
+		{`<head></p></div><title>a</title></head>`, doc(`<title>a</title>`, ``)},
+		{`<title><p>a</p></title>`, doc(`<title>&lt;p&gt;a&lt;/p&gt;</title>`, ``)},
+
+		// the meta elements
+		{`<meta charset="UTF-8"><meta name="viewport" content="width=device-width"><meta name="Description" content="a">`,
+			doc(`<meta charset="UTF-8"><meta name="viewport" content="width=device-width"><meta name="Description" content="a">`, ``)},
+		{`<meta http-equiv="refresh" content="0;url=javascript:alert(1)"><meta name="referrer" content="unsafe-url"><meta content="a"><meta property="og:image" content="x">`,
+			doc(``, ``)},
+		{`<p>a</p><meta charset="utf-8">`, doc(``, `<p>a</p>`)},
+
+		// the base and the link elements
+		{`<base href="https://evil.example.com/"><base target="_blank">`, doc(``, ``)},
+		{`<link rel="stylesheet" href="https://cdn.example.com/a.css" media="print"><link rel="STYLESHEET" href="https://FONTS.example.org/b.css">`,
+			doc(`<link rel="stylesheet" href="https://cdn.example.com/a.css" media="print"><link rel="STYLESHEET" href="https://FONTS.example.org/b.css">`, ``)},
+		{`<link rel="import" href="https://cdn.example.com/a.html"><link rel="stylesheet import" href="https://cdn.example.com/a.css"><link rel="preload" href="https://cdn.example.com/a.css">`,
+			doc(``, ``)},
+		{`<link rel="stylesheet" href="http://cdn.example.com/a.css"><link rel="stylesheet" href="https://cdn.example.com:8443/a.css">` +
+			`<link rel="stylesheet" href="https://cdn.example.com@evil.com/a.css"><link rel="stylesheet" href="https://cdn.example.com.evil.com/a.css">` +
+			`<link rel="stylesheet" href="//cdn.example.com/a.css"><link rel="stylesheet" href="/a.css"><link rel="stylesheet">`,
+			doc(``, ``)},
+
+		// the others are the same as the DefaultAllowList
+		{`<p class="x">a<script>alert(1)</script><style>p{}</style></p>`, doc(``, `<p class="x">a</p>`)},
+		{`<a href="/?q=a&amp;amp;b">a</a><a href="/?x=1&amp;lt=2">b</a>`, doc(``, `<a href="/?q=a&amp;b">a</a><a href="/?x=1&lt=2">b</a>`)},
+	}
+
+	for _, compiled := range []bool{false, true} {
+		if compiled {
+			sanitizer.SetPolicy(sanitizer.Compile())
+		}
+
+		for _, item := range testCases {
+			ret, err := sanitizer.SanitizeString(item.in)
+			if err != nil {
+				t.Errorf("unable to SanitizeString err: %s", err)
+			}
+			if ret != item.expected {
+				t.Errorf("compiled %v: expect %#v, got %#v", compiled, item.expected, ret)
+			}
+		}
+	}
+
+	if err := sanitizer.AllowList.Validate(); err != nil {
+		t.Errorf("expect a valid AllowList, got %s", err)
+	}
+}
+
+func TestDocumentMode(t *testing.T) {
+	// the base, meta and link elements are checked regardless of the
+	// AllowList, and the structure is written without any attribute if not
+	// allowed
+	list := htmlsanitizer.DefaultAllowList.Clone()
+	list.Tags = append(list.Tags,
+		&htmlsanitizer.Tag{Name: "base", URLAttr: []string{"href"}},
+		&htmlsanitizer.Tag{Name: "meta", Attr: []string{"http-equiv", "content", "name"}},
+		&htmlsanitizer.Tag{Name: "link", Attr: []string{"rel"}, URLAttr: []string{"href"}},
+	)
+	sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: list, Document: &htmlsanitizer.Document{}}
+
+	data := `<html lang="en"><base href="https://example.com/"><meta http-equiv="refresh" content="0"><meta name="description" http-equiv="x">` +
+		`<meta name="description" content="a"><link rel="stylesheet" href="https://example.com/a.css"><body class="c">b`
+	expected := `<!DOCTYPE html><html><head><meta name="description" content="a"></head><body>b</body></html>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+
+	// inside the foreign namespaces
+	sanitizer, _ = htmlsanitizer.DocumentPolicy(htmlsanitizer.Document{})
+	sanitizer.AllowList.SVG = htmlsanitizer.DefaultSVGAllowList
+	data = `<head><svg><rect/><body>a</body></svg><html lang="en"><head><body>`
+	expected = `<!DOCTYPE html><html><head></head><body><svg><rect />a</svg></body></html>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+
+	// violations
+	var violations []string
+	sanitizer.OnViolation = func(v htmlsanitizer.Violation) {
+		violations = append(violations, v.Kind.String()+":"+v.Tag)
+	}
+	data = `<base href="x"><meta charset="utf-8"><p>a</p><meta charset="utf-8"><body>`
+	if _, err := sanitizer.SanitizeString(data); err != nil {
+		t.Errorf("unable to SanitizeString err: %s", err)
+	}
+	if got := strings.Join(violations, ","); got != "tag not allowed:base,tag not allowed:meta,tag not allowed:body" {
+		t.Errorf("unexpected violations %s", got)
+	}
+}
+
+func TestDocumentHook(t *testing.T) {
+	sanitizer, _ := htmlsanitizer.DocumentPolicy(htmlsanitizer.Document{})
+	sanitizer.Hook = htmlsanitizer.HookFunc(func(dst []htmlsanitizer.Token, t htmlsanitizer.Token) []htmlsanitizer.Token {
+		if t.Type == htmlsanitizer.StartTagToken && t.Data == "p" {
+			// the output of the Hook is placed in the structure as well
+			return append(dst,
+				htmlsanitizer.Token{Type: htmlsanitizer.StartTagToken, Data: "base", Attr: []htmlsanitizer.Attribute{{Name: "href", Value: "https://evil.example.com/"}}},
+				htmlsanitizer.Token{Type: htmlsanitizer.StartTagToken, Data: "meta", Attr: []htmlsanitizer.Attribute{{Name: "http-equiv", Value: "refresh"}}},
+				htmlsanitizer.Token{Type: htmlsanitizer.StartTagToken, Data: "body", Attr: []htmlsanitizer.Attribute{{Name: "class", Value: "x"}}},
+				t,
+			)
+		}
+		return append(dst, t)
+	})
+
+	data := `<html><title>a</title><p>b</p><body class="y">c`
+	expected := `<!DOCTYPE html><html><head><title>a</title></head><body class="x"><p>b</p>c</body></html>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+}
+
+func TestDocumentWriter(t *testing.T) {
+	sanitizer, _ := htmlsanitizer.DocumentPolicy(htmlsanitizer.Document{})
+	data := `<html lang="en"><head><title>a</title></head><body><p>b</p></body></html>`
+	expected := `<!DOCTYPE html><html lang="en"><head><title>a</title></head><body><p>b</p></body></html>`
+
+	// the end of the document is written when closed
+	var buf bytes.Buffer
+	w := sanitizer.NewWriter(&buf)
+	for i := 0; i < len(data); i += 7 {
+		end := i + 7
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, err := w.Write([]byte(data[i:end])); err != nil {
+			t.Fatalf("unable to Write err: %s", err)
+		}
+	}
+	if got := buf.String(); got != strings.TrimSuffix(expected, `</body></html>`) {
+		t.Errorf("expect the end of the document not written before Close, got %#v", got)
+	}
+	if err := w.(io.Closer).Close(); err != nil {
+		t.Fatalf("unable to Close err: %s", err)
+	}
+	if got := buf.String(); got != expected {
+		t.Errorf("expect %#v, got %#v", expected, got)
+	}
+
+	ret, err := ioutil.ReadAll(sanitizer.NewReader(strings.NewReader(data)))
+	if err != nil || string(ret) != expected {
+		t.Errorf("expect %#v from the Reader, got %#v, err: %v", expected, string(ret), err)
+	}
+
+	if ret := sanitizer.AppendSanitized([]byte("x"), []byte(data)); string(ret) != "x"+expected {
+		t.Errorf("expect %#v from AppendSanitized, got %#v", "x"+expected, string(ret))
+	}
+
+	// the limits apply to the end of the document as well
+	sanitizer.Limits.MaxOutputBytes = int64(len(expected) - 1)
+	var limitErr *htmlsanitizer.LimitError
+	if _, err := sanitizer.SanitizeString(data); !errors.As(err, &limitErr) || limitErr.Kind != htmlsanitizer.LimitOutputBytes {
+		t.Errorf("expect a LimitError of the output bytes, got %v", err)
+	}
+}
+
+func TestDocumentPolicyFingerprint(t *testing.T) {
+	a, _ := htmlsanitizer.DocumentPolicy(htmlsanitizer.Document{StyleSheetHosts: []string{"a.example.com", "b.example.com"}})
+	b, _ := htmlsanitizer.DocumentPolicy(htmlsanitizer.Document{StyleSheetHosts: []string{"b.example.com", "a.example.com"}})
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Errorf("expect the order of the hosts not to change the fingerprint")
+	}
+
+	b.Document.StyleSheetHosts = b.Document.StyleSheetHosts[:1]
+	if a.Fingerprint() == b.Fingerprint() {
+		t.Errorf("expect the hosts to change the fingerprint")
+	}
+
+	c := &htmlsanitizer.HTMLSanitizer{AllowList: a.AllowList}
+	if a.Fingerprint() == c.Fingerprint() {
+		t.Errorf("expect the Document to change the fingerprint")
+	}
+}
+
+func TestDocumentPolicyErrors(t *testing.T) {
+	testCases := []struct {
+		hosts []string
+		field string
+	}{
+		{[]string{""}, "StyleSheetHosts[0]"},
+		{[]string{"a.com", "B.com"}, "StyleSheetHosts[1]"},
+		{[]string{"a.com/x"}, "StyleSheetHosts[0]"},
+		{[]string{"a.com:8080"}, "StyleSheetHosts[0]"},
+		{[]string{"x@a.com"}, "StyleSheetHosts[0]"},
+	}
+
+	for _, item := range testCases {
+		_, err := htmlsanitizer.DocumentPolicy(htmlsanitizer.Document{StyleSheetHosts: item.hosts})
+		var policyErr *htmlsanitizer.PolicyError
+		if !errors.As(err, &policyErr) || policyErr.Field != item.field {
+			t.Errorf("expect a PolicyError at %s, got %v", item.field, err)
+		}
+	}
+}
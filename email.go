@@ -0,0 +1,232 @@
+package htmlsanitizer
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// presentationalPatterns are the patterns of the legacy presentational
+// attributes used in HTML email.
+var presentationalPatterns = map[string]*regexp.Regexp{
+	"align":       regexp.MustCompile(`^(?i:left|center|right|justify)$`),
+	"bgcolor":     regexp.MustCompile(`^(?:#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|[a-zA-Z]+)$`),
+	"border":      regexp.MustCompile(`^[0-9]{1,4}$`),
+	"cellpadding": regexp.MustCompile(`^[0-9]{1,4}$`),
+	"cellspacing": regexp.MustCompile(`^[0-9]{1,4}$`),
+	"color":       regexp.MustCompile(`^(?:#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|[a-zA-Z]+)$`),
+	"face":        regexp.MustCompile(`^[a-zA-Z0-9 ,'"-]{1,256}$`),
+	"height":      regexp.MustCompile(`^[0-9]{1,5}(?:px|%)?$`),
+	"nowrap":      regexp.MustCompile(`^(?i:nowrap)?$`),
+	"size":        regexp.MustCompile(`^[+-]?[1-7]$`),
+	"valign":      regexp.MustCompile(`^(?i:top|middle|bottom|baseline)$`),
+	"width":       regexp.MustCompile(`^[0-9]{1,5}(?:px|%)?$`),
+}
+
+// presentational returns the Tag allowing the presentational attributes.
+func presentational(name string, attrs ...string) *Tag {
+	tag := &Tag{Name: name, Attr: attrs, AttrPattern: make(map[string]*regexp.Regexp, len(attrs))}
+	for _, attr := range attrs {
+		tag.AttrPattern[attr] = presentationalPatterns[attr]
+	}
+	return tag
+}
+
+// CSS values allowed in the inline styles of HTML email.
+const (
+	cssColor   = `#[0-9a-f]{3,8}|[a-z]+|rgba?\(\s*[0-9.]+%?\s*(?:,\s*[0-9.]+%?\s*){2,3}\)`
+	cssLength  = `-?[0-9]*\.?[0-9]+(?:px|em|rem|ex|pt|%)?|auto`
+	cssBorder  = cssLength + `|` + cssColor
+	cssFamily  = `[a-z0-9 ,'"-]+`
+	cssKeyword = `[a-z-]+`
+)
+
+// cssValues returns the pattern of at most n values separated by spaces.
+func cssValues(value string, n int) string {
+	return `(?:` + value + `)(?:\s+(?:` + value + `)){0,` + strconv.Itoa(n-1) + `}`
+}
+
+// emailStyleProperties are the CSS properties allowed in the inline styles
+// of HTML email, with the patterns of their values. None of the values can
+// contain URLs, comments or escapes.
+var emailStyleProperties = map[string]string{
+	"background-color": cssColor,
+	"border":           cssValues(cssBorder, 3),
+	"border-bottom":    cssValues(cssBorder, 3),
+	"border-collapse":  `collapse|separate`,
+	"border-color":     cssValues(cssColor, 4),
+	"border-left":      cssValues(cssBorder, 3),
+	"border-radius":    cssValues(cssLength, 4),
+	"border-right":     cssValues(cssBorder, 3),
+	"border-spacing":   cssValues(cssLength, 2),
+	"border-style":     cssValues(cssKeyword, 4),
+	"border-top":       cssValues(cssBorder, 3),
+	"border-width":     cssValues(cssLength, 4),
+	"color":            cssColor,
+	"display":          `block|inline|inline-block|none|table|table-cell|table-row`,
+	"font-family":      cssFamily,
+	"font-size":        cssLength + `|` + cssKeyword,
+	"font-style":       `normal|italic|oblique`,
+	"font-weight":      `normal|bold|bolder|lighter|[1-9]00`,
+	"height":           cssLength,
+	"letter-spacing":   cssLength + `|normal`,
+	"line-height":      cssLength + `|normal`,
+	"margin":           cssValues(cssLength, 4),
+	"margin-bottom":    cssLength,
+	"margin-left":      cssLength,
+	"margin-right":     cssLength,
+	"margin-top":       cssLength,
+	"max-width":        cssLength + `|none`,
+	"min-width":        cssLength,
+	"padding":          cssValues(cssLength, 4),
+	"padding-bottom":   cssLength,
+	"padding-left":     cssLength,
+	"padding-right":    cssLength,
+	"padding-top":      cssLength,
+	"text-align":       `left|right|center|justify`,
+	"text-decoration":  cssValues(cssKeyword, 3),
+	"text-transform":   `none|capitalize|uppercase|lowercase`,
+	"vertical-align":   `top|middle|bottom|baseline|text-top|text-bottom|sub|super`,
+	"white-space":      `normal|nowrap|pre|pre-line|pre-wrap`,
+	"width":            cssLength,
+}
+
+// emailStyleDeclarations are the compiled patterns of the declarations of
+// emailStyleProperties.
+var emailStyleDeclarations = func() map[string]*regexp.Regexp {
+	ret := make(map[string]*regexp.Regexp, len(emailStyleProperties))
+	for property, value := range emailStyleProperties {
+		ret[property] = regexp.MustCompile(`^(?i:` + value + `)(?:\s*!important)?$`)
+	}
+	return ret
+}()
+
+// emailStylePattern matches the inline styles consisting of the allowed
+// declarations only.
+var emailStylePattern = func() *regexp.Regexp {
+	var declarations []string
+	for property, value := range emailStyleProperties {
+		declarations = append(declarations, property+`\s*:\s*(?:`+value+`)(?:\s*!important)?`)
+	}
+	// stable for the Fingerprint
+	sort.Strings(declarations)
+	return regexp.MustCompile(`^(?i)\s*(?:(?:` + strings.Join(declarations, `|`) + `)\s*(?:;\s*|$))*$`)
+}()
+
+// EmailStyleSanitizer is a StyleSanitizer for HTML email, which keeps only
+// the declarations of a restricted set of CSS properties, such as color,
+// font-size and padding. The values are validated, and can not contain any
+// URLs, comments or escapes. The style is not acceptable if no declaration
+// is kept.
+func EmailStyleSanitizer(style string) (sanitized string, ok bool) {
+	var ret []string
+	for _, declaration := range strings.Split(style, ";") {
+		i := strings.IndexByte(declaration, ':')
+		if i < 0 {
+			continue
+		}
+
+		property := strings.ToLower(strings.TrimSpace(declaration[:i]))
+		value := strings.TrimSpace(declaration[i+1:])
+		if pattern := emailStyleDeclarations[property]; pattern != nil && pattern.MatchString(value) {
+			ret = append(ret, property+": "+value)
+		}
+	}
+
+	return strings.Join(ret, "; "), len(ret) > 0
+}
+
+// emailAllowList allows what the DefaultAllowList allows, with the legacy
+// presentational tags and attributes, and the inline styles. It's derived
+// from the DefaultAllowList before any change to it.
+var emailAllowList = func() *AllowList {
+	extra := &AllowList{
+		Tags: []*Tag{
+			presentational("center"),
+			presentational("font", "color", "face", "size"),
+			presentational("table", "align", "bgcolor", "border", "cellpadding", "cellspacing", "height", "width"),
+			presentational("tbody", "align", "valign"),
+			presentational("thead", "align", "valign"),
+			presentational("tfoot", "align", "valign"),
+			presentational("tr", "align", "bgcolor", "height", "valign"),
+			presentational("td", "align", "bgcolor", "height", "nowrap", "valign", "width"),
+			presentational("th", "align", "bgcolor", "height", "nowrap", "valign", "width"),
+			presentational("img", "align", "border"),
+			presentational("hr", "align", "size", "width"),
+			presentational("div", "align"),
+			presentational("p", "align"),
+			presentational("h1", "align"),
+			presentational("h2", "align"),
+			presentational("h3", "align"),
+			presentational("h4", "align"),
+			presentational("h5", "align"),
+			presentational("h6", "align"),
+		},
+		GlobalAttr:        []string{"style"},
+		GlobalAttrPattern: map[string]*regexp.Regexp{"style": emailStylePattern},
+	}
+
+	// the media players are not supported by most of the mail clients
+	list := DefaultAllowList.Merge(extra)
+	list.RemoveTag("audio")
+	list.RemoveTag("video")
+	list.RemoveTag("track")
+	list.RemoveTag("source")
+	list.RemoveTag("picture")
+	return list
+}()
+
+// EmailURLSanitizer returns a URLSanitizer for HTML email. The cid: URLs,
+// which refer to the attachments by their Content-ID, are mapped to the
+// attachment URLs by resolveCID, e.g. cid:logo@example.com is resolved with
+// the contentID logo@example.com. The cid: URLs are not allowed if resolveCID
+// is nil or returns false.
+//
+// The mailto: URLs are allowed, and the others, including the resolved
+// ones, are sanitized by the DefaultURLSanitizer.
+func EmailURLSanitizer(resolveCID func(contentID string) (url string, ok bool)) func(rawURL string) (sanitzed string, ok bool) {
+	return func(rawURL string) (sanitzed string, ok bool) {
+		if len(rawURL) >= 4 && strings.EqualFold(rawURL[:4], "cid:") {
+			contentID, err := url.PathUnescape(rawURL[4:])
+			if err != nil || contentID == "" || resolveCID == nil {
+				return
+			}
+			if rawURL, ok = resolveCID(contentID); !ok {
+				return
+			}
+			return DefaultURLSanitizer(rawURL)
+		}
+
+		if len(rawURL) >= 7 && strings.EqualFold(rawURL[:7], "mailto:") {
+			u, err := url.Parse(rawURL)
+			if err != nil || u.Opaque == "" {
+				return
+			}
+			return u.String(), true
+		}
+
+		return DefaultURLSanitizer(rawURL)
+	}
+}
+
+// EmailPolicy returns a new HTMLSanitizer for the HTML email, e.g. to show
+// the inbound email in a webmail view. It allows what the DefaultAllowList
+// allows except the audio and video, with the legacy presentational tags and
+// attributes, such as <font color>, <center> and <table bgcolor>, of which
+// the values are validated.
+//
+// The inline styles are allowed, but only the declarations kept by the
+// EmailStyleSanitizer, which are matched against a pattern again.
+//
+// The URLs are sanitized by the EmailURLSanitizer with resolveCID, set the
+// URLSanitizerName accordingly if the Fingerprint or a Cache is used.
+func EmailPolicy(resolveCID func(contentID string) (url string, ok bool)) *HTMLSanitizer {
+	return &HTMLSanitizer{
+		AllowList:          emailAllowList.Clone(),
+		URLSanitizer:       EmailURLSanitizer(resolveCID),
+		StyleSanitizer:     EmailStyleSanitizer,
+		StyleSanitizerName: "email",
+	}
+}
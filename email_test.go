@@ -0,0 +1,173 @@
+package htmlsanitizer_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleEmailPolicy() {
+	attachments := map[string]string{
+		"logo@example.com": "/mail/42/attachments/1",
+	}
+	sanitizer := htmlsanitizer.EmailPolicy(func(contentID string) (string, bool) {
+		u, ok := attachments[contentID]
+		return u, ok
+	})
+
+	data := `<table bgcolor="#ffffff" width="600" onclick="x"><tr><td align="center" style="color: red; background: url(https://t.example/p.gif)">` +
+		`<img src="cid:logo@example.com"><font color="blue" size="2">Hi</font></td></tr></table>`
+	output, _ := sanitizer.SanitizeString(data)
+	fmt.Print(output)
+	// Output:
+	// <table bgcolor="#ffffff" width="600"><tr><td align="center" style="color: red"><img src="/mail/42/attachments/1"><font color="blue" size="2">Hi</font></td></tr></table>
+}
+
+func TestEmailPolicyAttributes(t *testing.T) {
+	sanitizer := htmlsanitizer.EmailPolicy(nil)
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{`<center>x</center>`, `<center>x</center>`},
+		{`<font face="Arial, 'Helvetica Neue'" color="#F00">x</font>`, `<font face="Arial, &#39;Helvetica Neue&#39;" color="#F00">x</font>`},
+		{`<font color="red;x" size="9" face="a<b">x</font>`, `<font>x</font>`},
+		{`<table border="1" cellpadding="4" cellspacing="0" width="100%" align="center">`, `<table border="1" cellpadding="4" cellspacing="0" width="100%" align="center">`},
+		{`<table border="-1" width="expression(1)" align="middle">`, `<table>`},
+		{`<td valign="TOP" nowrap bgcolor="rgb(0,0,0)">x</td>`, `<td valign="TOP" nowrap>x</td>`},
+		{`<p align="justify" bgcolor="red">x</p>`, `<p align="justify">x</p>`},
+		{`<td colspan="2" background="https://t.example/p.gif">x</td>`, `<td colspan="2">x</td>`},
+		{`<video src="x.mp4"></video><img src="https://example.com/x.png" width="10">`, `<img src="https://example.com/x.png" width="10">`},
+		{`<a href="mailto:someone@example.com">m</a><a href="cid:x">c</a>`, `<a href="mailto:someone@example.com">m</a><a>c</a>`},
+	}
+
+	for _, item := range testCases {
+		ret, err := sanitizer.SanitizeString(item.in)
+		if err != nil {
+			t.Errorf("unable to SanitizeString err: %s", err)
+		}
+		if ret != item.expected {
+			t.Errorf("expect %#v, got %#v", item.expected, ret)
+		}
+	}
+
+	if err := sanitizer.AllowList.Validate(); err != nil {
+		t.Errorf("expect a valid AllowList, got %s", err)
+	}
+}
+
+func TestEmailPolicyStyle(t *testing.T) {
+	sanitizer := htmlsanitizer.EmailPolicy(nil)
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{`color:#333;FONT-SIZE:14px`, `color: #333; font-size: 14px`},
+		{`padding: 0 4px 0 4px; margin:auto !important`, `padding: 0 4px 0 4px; margin: auto !important`},
+		{`border: 1px solid rgb(204, 204, 204)`, `border: 1px solid rgb(204, 204, 204)`},
+		{`font-family: &quot;Segoe UI&quot;, Arial, sans-serif`, `font-family: &#34;Segoe UI&#34;, Arial, sans-serif`},
+		{`background-image: url(https://t.example/p.gif); color: red`, `color: red`},
+		{`background-color: red; background: url(x)`, `background-color: red`},
+		{`color: expression(alert(1)); width: 10px`, `width: 10px`},
+		{`color: re\64; width: 1px/**/`, ``},
+		{`position: fixed; top: 0; mso-line-height-rule: exactly`, ``},
+		{`font-family: x&#59; background: url(x)`, `font-family: x`},
+	}
+
+	for _, item := range testCases {
+		expected := `<p>x</p>`
+		if item.expected != "" {
+			expected = `<p style="` + item.expected + `">x</p>`
+		}
+
+		ret, _ := sanitizer.SanitizeString(`<p style="` + item.in + `">x</p>`)
+		if ret != expected {
+			t.Errorf("expect %#v, got %#v", expected, ret)
+		}
+	}
+
+	// the inline styles are matched against the pattern without the
+	// StyleSanitizer
+	sanitizer.StyleSanitizer = nil
+	for _, in := range []string{`color: red`, `color: red; width: 1px;`} {
+		data := `<p style="` + in + `">x</p>`
+		if ret, _ := sanitizer.SanitizeString(data); ret != data {
+			t.Errorf("expect %#v, got %#v", data, ret)
+		}
+	}
+	for _, in := range []string{`color: red; background: url(x)`, `color: red;;x`, `color: red width: 1px`} {
+		if ret, _ := sanitizer.SanitizeString(`<p style="` + in + `">x</p>`); strings.Contains(ret, "style") {
+			t.Errorf("expect the style dropped, got %#v", ret)
+		}
+	}
+}
+
+func TestStyleSanitizerHook(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.GlobalAttr = append(sanitizer.GlobalAttr, "style")
+	sanitizer.StyleSanitizer = func(style string) (string, bool) {
+		return strings.TrimSpace(style), !strings.ContainsAny(style, `\()`)
+	}
+	identity := htmlsanitizer.HookFunc(func(dst []htmlsanitizer.Token, t htmlsanitizer.Token) []htmlsanitizer.Token {
+		return append(dst, t)
+	})
+
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{` font-family: &quot;a&amp;amp;b&quot; `, `<p style="font-family: &#34;a&amp;b&#34;">x</p>`},
+		{`color: red&amp;lt;`, `<p style="color: red&lt;">x</p>`},
+		// the sanitized style is unescaped again by the browsers
+		{`content: &quot;&amp;#40;&quot;`, `<p>x</p>`},
+	}
+
+	for _, hook := range []htmlsanitizer.Hook{nil, identity} {
+		sanitizer.Hook = hook
+		for _, item := range testCases {
+			ret, _ := sanitizer.SanitizeString(`<p style="` + item.in + `">x</p>`)
+			if ret != item.expected {
+				t.Errorf("hook %v: expect %#v, got %#v", hook != nil, item.expected, ret)
+			}
+		}
+	}
+}
+
+func TestEmailURLSanitizer(t *testing.T) {
+	sanitize := htmlsanitizer.EmailURLSanitizer(func(contentID string) (string, bool) {
+		switch contentID {
+		case "part1.abc@example.com":
+			return "https://mail.example.com/att/1", true
+		case "evil":
+			return "javascript:alert(1)", true
+		}
+		return "", false
+	})
+
+	testCases := []struct {
+		in       string
+		expected string
+		ok       bool
+	}{
+		{"cid:part1.abc@example.com", "https://mail.example.com/att/1", true},
+		{"CID:part1.abc%40example.com", "https://mail.example.com/att/1", true},
+		{"cid:unknown", "", false},
+		{"cid:", "", false},
+		{"cid:%zz", "", false},
+		{"cid:evil", "", false},
+		{"mailto:a@example.com?subject=hi", "mailto:a@example.com?subject=hi", true},
+		{"MAILTO:a@example.com", "mailto:a@example.com", true},
+		{"mailto://a@example.com", "", false},
+		{"javascript:alert(1)", "", false},
+		{"https://example.com/", "https://example.com/", true},
+	}
+
+	for _, item := range testCases {
+		ret, ok := sanitize(item.in)
+		if ret != item.expected || ok != item.ok {
+			t.Errorf("%s: expect %#v %v, got %#v %v", item.in, item.expected, item.ok, ret, ok)
+		}
+	}
+}
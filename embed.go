@@ -0,0 +1,229 @@
+package htmlsanitizer
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultEmbedSandbox is the default sandbox tokens of the embedded iframes,
+// which is enough for most of the video players. It does not include
+// allow-same-origin, which lets the pages of the same origin as the
+// embedding page escape the sandbox together with allow-scripts, nor
+// allow-popups, add them to the Embeds.Sandbox if the providers require.
+var DefaultEmbedSandbox = []string{"allow-scripts", "allow-presentation"}
+
+// DefaultEmbedReferrerPolicy is the default referrerpolicy of the embedded
+// iframes.
+const DefaultEmbedReferrerPolicy = "strict-origin-when-cross-origin"
+
+// sandboxTokens are the known tokens of the sandbox attribute.
+var sandboxTokens = map[string]bool{
+	"allow-downloads":                          true,
+	"allow-forms":                              true,
+	"allow-modals":                             true,
+	"allow-orientation-lock":                   true,
+	"allow-pointer-lock":                       true,
+	"allow-popups":                             true,
+	"allow-popups-to-escape-sandbox":           true,
+	"allow-presentation":                       true,
+	"allow-same-origin":                        true,
+	"allow-scripts":                            true,
+	"allow-storage-access-by-user-activation":  true,
+	"allow-top-navigation":                     true,
+	"allow-top-navigation-by-user-activation":  true,
+	"allow-top-navigation-to-custom-protocols": true,
+}
+
+// referrerPolicies are the valid values of the referrerpolicy attribute.
+var referrerPolicies = map[string]bool{
+	"no-referrer":                     true,
+	"no-referrer-when-downgrade":      true,
+	"origin":                          true,
+	"origin-when-cross-origin":        true,
+	"same-origin":                     true,
+	"strict-origin":                   true,
+	"strict-origin-when-cross-origin": true,
+	"unsafe-url":                      true,
+}
+
+// EmbedProvider allows the iframes embedding the pages of a provider, such
+// as a video player.
+type EmbedProvider struct {
+	// Host of the embedded pages, e.g. www.youtube-nocookie.com, must be
+	// lowercase. Only the https pages on the default port are allowed.
+	Host string
+
+	// Path matches the escaped path of the embedded pages, which must be
+	// anchored by ^ and $, such as ^/embed/[A-Za-z0-9_-]+$ . If nil, any
+	// path of the Host is allowed.
+	Path *regexp.Regexp
+}
+
+// Embeds specifies the iframes allowed by the EmbedPolicy.
+type Embeds struct {
+	// Providers of the embedded pages. The iframes without a src of any of
+	// them are removed, as well as their end tags.
+	Providers []EmbedProvider
+
+	// Sandbox specifies the tokens of the sandbox attribute forced on all
+	// the iframes, e.g. allow-scripts. If nil, DefaultEmbedSandbox is used.
+	// If empty, all the restrictions of the sandbox apply.
+	Sandbox []string
+
+	// ReferrerPolicy specifies the referrerpolicy attribute forced on all the
+	// iframes. If empty, DefaultEmbedReferrerPolicy is used.
+	ReferrerPolicy string
+}
+
+// embedHook forces the attributes of the iframes.
+type embedHook struct {
+	providers      []EmbedProvider
+	sandbox        string
+	referrerPolicy string
+}
+
+// Transform replaces the sandbox, referrerpolicy and loading attributes of
+// the iframes with the forced ones. The src is checked by the AllowList.
+func (h *embedHook) Transform(dst []Token, t Token) []Token {
+	if t.Type != StartTagToken || t.Data != "iframe" {
+		return append(dst, t)
+	}
+
+	attrs := make([]Attribute, 0, len(t.Attr)+3)
+	for _, attr := range t.Attr {
+		switch attr.Name {
+		case "sandbox", "referrerpolicy", "loading":
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+	t.Attr = append(attrs,
+		Attribute{Name: "sandbox", Value: h.sandbox, Bare: h.sandbox == ""},
+		Attribute{Name: "referrerpolicy", Value: h.referrerPolicy},
+		Attribute{Name: "loading", Value: "lazy"},
+	)
+	return append(dst, t)
+}
+
+// validEmbeds checks the Embeds, and returns the hook forcing them.
+func validEmbeds(e *Embeds) (*embedHook, error) {
+	h := &embedHook{
+		providers:      append([]EmbedProvider(nil), e.Providers...),
+		sandbox:        strings.Join(DefaultEmbedSandbox, " "),
+		referrerPolicy: DefaultEmbedReferrerPolicy,
+	}
+
+	if len(e.Providers) == 0 {
+		return nil, &PolicyError{Field: "Providers", Value: "", Reason: "no provider allowed"}
+	}
+	for i, provider := range e.Providers {
+		u, err := url.Parse("https://" + provider.Host + "/")
+		if provider.Host == "" || err != nil || u.Host != provider.Host || u.User != nil || u.Port() != "" || strings.ToLower(provider.Host) != provider.Host {
+			return nil, &PolicyError{Field: fmt.Sprintf("Providers[%d].Host", i), Value: provider.Host, Reason: "invalid host"}
+		}
+		if path := provider.Path; path != nil && !anchoredPattern(path.String()) {
+			return nil, &PolicyError{Field: fmt.Sprintf("Providers[%d].Path", i), Value: path.String(), Reason: "path pattern must be anchored by ^ and $"}
+		}
+	}
+
+	if e.Sandbox != nil {
+		for i, token := range e.Sandbox {
+			if !sandboxTokens[token] {
+				return nil, &PolicyError{Field: fmt.Sprintf("Sandbox[%d]", i), Value: token, Reason: "unknown sandbox token"}
+			}
+		}
+		h.sandbox = strings.Join(e.Sandbox, " ")
+	}
+
+	if e.ReferrerPolicy != "" {
+		if !referrerPolicies[e.ReferrerPolicy] {
+			return nil, &PolicyError{Field: "ReferrerPolicy", Value: e.ReferrerPolicy, Reason: "unknown referrer policy"}
+		}
+		h.referrerPolicy = e.ReferrerPolicy
+	}
+
+	return h, nil
+}
+
+// anchoredPattern checks whether the pattern starts with ^ and ends with an
+// unescaped $.
+func anchoredPattern(pattern string) bool {
+	if len(pattern) < 2 || pattern[0] != '^' || pattern[len(pattern)-1] != '$' {
+		return false
+	}
+
+	escapes := 0
+	for i := len(pattern) - 2; i >= 0 && pattern[i] == '\\'; i-- {
+		escapes++
+	}
+	return escapes%2 == 0
+}
+
+// srcPattern returns the pattern of the sanitized src of the providers. The
+// anchors of the paths are replaced by the ones of the URL, and the URL may
+// only continue with the query or the fragment after the path.
+func (h *embedHook) srcPattern() *regexp.Regexp {
+	providers := make([]string, 0, len(h.providers))
+	for _, provider := range h.providers {
+		path := `[/?#]|$`
+		if provider.Path != nil {
+			pattern := provider.Path.String()
+			path = `(?:` + pattern[1:len(pattern)-1] + `)(?:[?#]|$)`
+		}
+		providers = append(providers, `(?i:`+regexp.QuoteMeta(provider.Host)+`)(?:`+path+`)`)
+	}
+	return regexp.MustCompile(`^https://(?:` + strings.Join(providers, "|") + `)`)
+}
+
+// iframeTag returns the iframe Tag allowing the embeds forced by h. The
+// patterns allow only the pages of the providers and the forced attributes,
+// even if the Hook is removed or replaced.
+func (h *embedHook) iframeTag() *Tag {
+	return &Tag{
+		Name:    "iframe",
+		Attr:    []string{"width", "height", "title", "allowfullscreen", "sandbox", "referrerpolicy", "loading"},
+		URLAttr: []string{"src"},
+		AttrPattern: map[string]*regexp.Regexp{
+			"width":           regexp.MustCompile(`^[0-9]{1,4}$`),
+			"height":          regexp.MustCompile(`^[0-9]{1,4}$`),
+			"allowfullscreen": regexp.MustCompile(`^(?i:allowfullscreen)?$`),
+			"sandbox":         regexp.MustCompile(`^` + regexp.QuoteMeta(h.sandbox) + `$`),
+			"referrerpolicy":  regexp.MustCompile(`^` + regexp.QuoteMeta(h.referrerPolicy) + `$`),
+			"loading":         regexp.MustCompile(`^lazy$`),
+			"src":             h.srcPattern(),
+		},
+		Require: []string{"src"},
+	}
+}
+
+// EmbedPolicy returns a new HTMLSanitizer which allows what the
+// DefaultAllowList allows, and the iframes embedding the pages of the
+// providers, such as the video players.
+//
+// An iframe is allowed only if its src is an https URL of any of the
+// providers, otherwise it's removed with its end tag. The sandbox, referrerpolicy
+// and loading=lazy attributes are forced by the Hook, and the srcdoc, allow
+// and name attributes are always removed. Only the width, height, title and
+// allowfullscreen attributes are kept.
+//
+// The providers and the values of the forced attributes are checked by the
+// AllowList as well, so a Hook wrapping the returned one can not loosen
+// them. The forced attributes are added only by the returned Hook, which
+// must be called if it's wrapped. As it has a Hook, the results are not
+// cached by a Cache.
+//
+// A *PolicyError is returned if there is no provider, or any of the hosts,
+// the sandbox tokens or the referrer policy is invalid.
+func EmbedPolicy(embeds Embeds) (*HTMLSanitizer, error) {
+	h, err := validEmbeds(&embeds)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HTMLSanitizer{
+		AllowList: DefaultAllowList.Merge(&AllowList{Tags: []*Tag{h.iframeTag()}}),
+		Hook:      h,
+	}, nil
+}
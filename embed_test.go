@@ -0,0 +1,158 @@
+package htmlsanitizer_test
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleEmbedPolicy() {
+	sanitizer, err := htmlsanitizer.EmbedPolicy(htmlsanitizer.Embeds{
+		Providers: []htmlsanitizer.EmbedProvider{
+			{Host: "www.youtube-nocookie.com", Path: regexp.MustCompile(`^/embed/[A-Za-z0-9_-]+$`)},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	data := `<iframe src="https://www.youtube-nocookie.com/embed/abc123" width="560" height="315" allow="autoplay" srcdoc="x">video</iframe>` +
+		`<iframe src="https://evil.example.com/">x</iframe>`
+	output, _ := sanitizer.SanitizeString(data)
+	fmt.Println(output)
+	// Output:
+	// <iframe src="https://www.youtube-nocookie.com/embed/abc123" width="560" height="315" sandbox="allow-scripts allow-presentation" referrerpolicy="strict-origin-when-cross-origin" loading="lazy">video</iframe>x
+}
+
+func TestEmbedPolicy(t *testing.T) {
+	sanitizer, err := htmlsanitizer.EmbedPolicy(htmlsanitizer.Embeds{
+		Providers: []htmlsanitizer.EmbedProvider{
+			{Host: "player.example.com", Path: regexp.MustCompile(`^/video/[0-9]+$`)},
+			{Host: "maps.example.org"},
+		},
+		Sandbox:        []string{"allow-scripts"},
+		ReferrerPolicy: "no-referrer",
+	})
+	if err != nil {
+		t.Fatalf("unable to create EmbedPolicy err: %s", err)
+	}
+
+	forced := ` sandbox="allow-scripts" referrerpolicy="no-referrer" loading="lazy"`
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{`<iframe src="https://player.example.com/video/1"></iframe>`, `<iframe src="https://player.example.com/video/1"` + forced + `></iframe>`},
+		{`<iframe src="https://PLAYER.example.com/video/1?t=30#x" title="v" allowfullscreen></iframe>`,
+			`<iframe src="https://PLAYER.example.com/video/1?t=30#x" title="v" allowfullscreen` + forced + `></iframe>`},
+		{`<iframe src="https://maps.example.org/any/where"></iframe>`, `<iframe src="https://maps.example.org/any/where"` + forced + `></iframe>`},
+		{`<iframe src="https://player.example.com/video/1?a=1&amp;amp;b=2&amp;lt=3"></iframe>`,
+			`<iframe src="https://player.example.com/video/1?a=1&amp;b=2&lt=3"` + forced + `></iframe>`},
+
+		// the forced attributes can not be escalated
+		{`<iframe src="https://maps.example.org/" sandbox="allow-scripts allow-same-origin allow-top-navigation" loading="eager" referrerpolicy="unsafe-url"></iframe>`,
+			`<iframe src="https://maps.example.org/"` + forced + `></iframe>`},
+		{`<iframe src="https://maps.example.org/" sandbox="allow-scripts"></iframe>`, `<iframe src="https://maps.example.org/"` + forced + `></iframe>`},
+		{`<iframe src="https://maps.example.org/" srcdoc="<script>alert(1)</script>" allow="camera; microphone" name="top" onload="x"></iframe>`,
+			`<iframe src="https://maps.example.org/"` + forced + `></iframe>`},
+
+		// the iframes not of the providers are removed with their end tags
+		{`<iframe src="https://player.example.com/video/x">a</iframe>b`, `ab`},
+		{`<iframe src="https://player.example.com/video/1/../../admin">a</iframe>`, `a`},
+		{`<iframe src="http://player.example.com/video/1">a</iframe>`, `a`},
+		{`<iframe src="https://player.example.com:8443/video/1">a</iframe>`, `a`},
+		{`<iframe src="https://player.example.com@evil.com/video/1">a</iframe>`, `a`},
+		{`<iframe src="https://player.example.com.evil.com/video/1">a</iframe>`, `a`},
+		{`<iframe src="//player.example.com/video/1">a</iframe>`, `a`},
+		{`<iframe src="javascript:alert(1)">a</iframe>`, `a`},
+		{`<iframe srcdoc="<script>alert(1)</script>">a</iframe>`, `a`},
+		{`<iframe>a</iframe>`, `a`},
+
+		// the others are the same as the DefaultAllowList
+		{`<p class="x">a<script>alert(1)</script></p>`, `<p class="x">a</p>`},
+	}
+
+	for _, item := range testCases {
+		ret, err := sanitizer.SanitizeString(item.in)
+		if err != nil {
+			t.Errorf("unable to SanitizeString err: %s", err)
+		}
+		if ret != item.expected {
+			t.Errorf("expect %#v, got %#v", item.expected, ret)
+		}
+	}
+
+	if err := sanitizer.AllowList.Validate(); err != nil {
+		t.Errorf("expect a valid AllowList, got %s", err)
+	}
+
+	// the AllowList alone allows only the providers and the forced
+	// attributes
+	hook := sanitizer.Hook
+	sanitizer.Hook = nil
+	data := `<iframe src="https://evil.example.com/">a</iframe><iframe src="https://maps.example.org/" sandbox="allow-same-origin">b</iframe>` +
+		`<iframe src="https://player.example.com/admin">c</iframe><iframe src="https://player.example.com/video/1x">d</iframe>` +
+		`<iframe src="https://player.example.com/video/1?x#y" sandbox="allow-scripts">e</iframe>`
+	expected := `a<iframe src="https://maps.example.org/">b</iframe>cd<iframe src="https://player.example.com/video/1?x#y" sandbox="allow-scripts">e</iframe>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v without the Hook, got %#v", expected, ret)
+	}
+
+	// a Hook wrapping it can not loosen the providers
+	sanitizer.Hook = htmlsanitizer.HookFunc(func(dst []htmlsanitizer.Token, t htmlsanitizer.Token) []htmlsanitizer.Token {
+		for i, attr := range t.Attr {
+			if attr.Name == "src" {
+				t.Attr[i].Value = "https://player.example.com/admin"
+			}
+		}
+		return hook.Transform(dst, t)
+	})
+	if ret, _ := sanitizer.SanitizeString(`<iframe src="https://player.example.com/video/1">a</iframe>`); ret != "a" {
+		t.Errorf("expect the iframe removed, got %#v", ret)
+	}
+}
+
+func TestEmbedPolicySandbox(t *testing.T) {
+	sanitizer, err := htmlsanitizer.EmbedPolicy(htmlsanitizer.Embeds{
+		Providers: []htmlsanitizer.EmbedProvider{{Host: "example.com"}},
+		Sandbox:   []string{},
+	})
+	if err != nil {
+		t.Fatalf("unable to create EmbedPolicy err: %s", err)
+	}
+
+	data := `<iframe src="https://example.com/" sandbox="allow-scripts"></iframe>`
+	expected := `<iframe src="https://example.com/" sandbox referrerpolicy="strict-origin-when-cross-origin" loading="lazy"></iframe>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+}
+
+func TestEmbedPolicyErrors(t *testing.T) {
+	testCases := []struct {
+		embeds htmlsanitizer.Embeds
+		field  string
+	}{
+		{htmlsanitizer.Embeds{}, "Providers"},
+		{htmlsanitizer.Embeds{Providers: []htmlsanitizer.EmbedProvider{{Host: ""}}}, "Providers[0].Host"},
+		{htmlsanitizer.Embeds{Providers: []htmlsanitizer.EmbedProvider{{Host: "a.com"}, {Host: "B.com"}}}, "Providers[1].Host"},
+		{htmlsanitizer.Embeds{Providers: []htmlsanitizer.EmbedProvider{{Host: "a.com/x"}}}, "Providers[0].Host"},
+		{htmlsanitizer.Embeds{Providers: []htmlsanitizer.EmbedProvider{{Host: "a.com:8080"}}}, "Providers[0].Host"},
+		{htmlsanitizer.Embeds{Providers: []htmlsanitizer.EmbedProvider{{Host: "x@a.com"}}}, "Providers[0].Host"},
+		{htmlsanitizer.Embeds{Providers: []htmlsanitizer.EmbedProvider{{Host: "a.com"}, {Host: "b.com", Path: regexp.MustCompile(`^/embed/`)}}}, "Providers[1].Path"},
+		{htmlsanitizer.Embeds{Providers: []htmlsanitizer.EmbedProvider{{Host: "a.com", Path: regexp.MustCompile(`^/embed/[0-9]+\$`)}}}, "Providers[0].Path"},
+		{htmlsanitizer.Embeds{Providers: []htmlsanitizer.EmbedProvider{{Host: "a.com"}}, Sandbox: []string{"allow-scripts", "allow-everything"}}, "Sandbox[1]"},
+		{htmlsanitizer.Embeds{Providers: []htmlsanitizer.EmbedProvider{{Host: "a.com"}}, ReferrerPolicy: "always"}, "ReferrerPolicy"},
+	}
+
+	for _, item := range testCases {
+		_, err := htmlsanitizer.EmbedPolicy(item.embeds)
+		var policyErr *htmlsanitizer.PolicyError
+		if !errors.As(err, &policyErr) || policyErr.Field != item.field {
+			t.Errorf("expect a PolicyError at %s, got %v", item.field, err)
+		}
+	}
+}
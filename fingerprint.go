@@ -0,0 +1,249 @@
+package htmlsanitizer
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"regexp"
+	"sort"
+)
+
+// fingerprintVersion is hashed into all the fingerprints, which must be
+// bumped once the canonical form, or how the same configuration sanitizes
+// the HTML content, changes, so the stored fingerprints become stale.
+//
+// 2: the grammar of the attribute names is aligned with the browsers.
+const fingerprintVersion = 2
+
+// fingerprinter writes the configuration into a hash unambiguously.
+type fingerprinter struct {
+	h   hash.Hash
+	buf [binary.MaxVarintLen64]byte
+}
+
+func newFingerprinter() fingerprinter {
+	p := fingerprinter{h: sha256.New()}
+	p.writeInt(fingerprintVersion)
+	return p
+}
+
+func (p *fingerprinter) writeInt(i int64) {
+	n := binary.PutVarint(p.buf[:], i)
+	_, _ = p.h.Write(p.buf[:n])
+}
+
+func (p *fingerprinter) writeString(s string) {
+	p.writeInt(int64(len(s)))
+	_, _ = p.h.Write([]byte(s))
+}
+
+// writeSet writes the strings as a set, regardless of the order and the
+// duplicates.
+func (p *fingerprinter) writeSet(s []string) {
+	s = append([]string(nil), s...)
+	sort.Strings(s)
+
+	n := 0
+	for i, item := range s {
+		if i == 0 || item != s[i-1] {
+			s[n] = item
+			n++
+		}
+	}
+
+	p.writeInt(int64(n))
+	for _, item := range s[:n] {
+		p.writeString(item)
+	}
+}
+
+// withPatterns appends the patterns to the attribute names if any.
+func withPatterns(attrs []string, patterns map[string]*regexp.Regexp) []string {
+	if len(patterns) == 0 {
+		return attrs
+	}
+
+	ret := make([]string, 0, len(attrs))
+	for _, attr := range attrs {
+		if pattern := patterns[attr]; pattern != nil {
+			attr += "\x00" + pattern.String()
+		}
+		ret = append(ret, attr)
+	}
+	return ret
+}
+
+// writeAttrs writes the attributes of tag.
+func (p *fingerprinter) writeAttrs(tag *Tag) {
+	// URLAttr takes precedence over Attr
+	urlAttrs := make(map[string]bool, len(tag.URLAttr))
+	for _, attr := range tag.URLAttr {
+		urlAttrs[attr] = true
+	}
+	attrs := make([]string, 0, len(tag.Attr))
+	for _, attr := range tag.Attr {
+		if !urlAttrs[attr] {
+			attrs = append(attrs, attr)
+		}
+	}
+
+	// the required attributes are marked in the set of Attr
+	attrs = withPatterns(attrs, tag.AttrPattern)
+	for _, attr := range tag.Require {
+		attrs = append(attrs, "\x01"+attr)
+	}
+	p.writeSet(attrs)
+	p.writeSet(withPatterns(tag.URLAttr, tag.AttrPattern))
+}
+
+// effectiveTags returns the tags which take effect, sorted by name. Only the
+// first one of the tags with the same name takes effect.
+func effectiveTags(tags []*Tag) []*Tag {
+	seen := make(map[string]bool, len(tags))
+	ret := make([]*Tag, 0, len(tags))
+	for _, tag := range tags {
+		if tag == nil || seen[tag.Name] {
+			continue
+		}
+		seen[tag.Name] = true
+		ret = append(ret, tag)
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// fingerprint returns the hash of the canonical form of l, which only
+// depends on how l sanitizes the HTML content.
+func (l *AllowList) fingerprint() (ret [sha256.Size]byte) {
+	p := newFingerprinter()
+	if l == nil {
+		p.writeInt(-1)
+		p.h.Sum(ret[:0])
+		return
+	}
+
+	tags := effectiveTags(l.Tags)
+	p.writeInt(int64(len(tags)))
+	for _, tag := range tags {
+		p.writeString(tag.Name)
+		p.writeAttrs(tag)
+	}
+
+	p.writeSet(withPatterns(l.GlobalAttr, l.GlobalAttrPattern))
+
+	// only the names of NonHTMLTags matter
+	nonHTMLTags := effectiveTags(l.NonHTMLTags)
+	p.writeInt(int64(len(nonHTMLTags)))
+	for _, tag := range nonHTMLTags {
+		p.writeString(tag.Name)
+	}
+
+	// the SVG and the MathML are written only if set
+	if l.SVG != nil {
+		p.writeString("svg")
+		svg := l.SVG.fingerprint()
+		_, _ = p.h.Write(svg[:])
+	}
+	if l.MathML != nil {
+		p.writeString("math")
+		math := l.MathML.fingerprint()
+		_, _ = p.h.Write(math[:])
+	}
+
+	// the CustomElements are written in order, since the first matched one
+	// wins, and only if any
+	if custom := customTags(l.CustomElements); len(custom) > 0 {
+		p.writeString("customElements")
+		p.writeInt(int64(len(custom)))
+		for _, tag := range custom {
+			p.writeString(tag.Name)
+			p.writeAttrs(tag)
+		}
+	}
+
+	p.h.Sum(ret[:0])
+	return
+}
+
+// Fingerprint returns a hash of l in hex, which is deterministic across
+// processes. It is not stable across versions of this package: an internal
+// version is hashed into it, which is bumped whenever how the same AllowList
+// sanitizes the HTML content changes, e.g. a parsing fix, so the fingerprints
+// stored by an older version are treated as stale.
+//
+// The fingerprint is order-independent, and only changes when how l
+// sanitizes the HTML content changes, e.g. a tag or an attribute is added
+// or removed. Reordering the tags and attributes, or adding a duplicated one
+// which never takes effect, does not change it. The options added later,
+// such as the patterns, the required attributes and the foreign namespaces,
+// are hashed only if set, which keeps the fingerprints of the AllowLists
+// without them unchanged.
+func (l *AllowList) Fingerprint() string {
+	ret := l.fingerprint()
+	return hex.EncodeToString(ret[:])
+}
+
+// Fingerprint returns the fingerprint of the AllowList compiled into p,
+// which is computed only once.
+func (p *Policy) Fingerprint() string {
+	if p == nil {
+		return (*AllowList)(nil).Fingerprint()
+	}
+	return hex.EncodeToString(p.fingerprint[:])
+}
+
+// fingerprint returns the hash of the configuration of f, which affects
+// the sanitized content. The fingerprint of the Policy is computed only
+// once, and the AllowList is hashed each time if there is no Policy.
+func (f *HTMLSanitizer) fingerprint() (ret [sha256.Size]byte) {
+	p := newFingerprinter()
+
+	var fingerprint [sha256.Size]byte
+	if policy := f.Policy(); policy != nil {
+		fingerprint = policy.fingerprint
+	} else {
+		fingerprint = f.AllowList.fingerprint()
+	}
+	_, _ = p.h.Write(fingerprint[:])
+
+	p.writeInt(f.Limits.MaxInputBytes)
+	p.writeInt(int64(f.Limits.MaxTagNameLen))
+	p.writeInt(int64(f.Limits.MaxAttrValueLen))
+	p.writeInt(int64(f.Limits.MaxAttrsPerTag))
+	p.writeInt(int64(f.Limits.MaxDepth))
+	p.writeInt(f.Limits.MaxOutputBytes)
+
+	if f.Document != nil {
+		p.writeString("document")
+		p.writeSet(f.Document.StyleSheetHosts)
+	}
+
+	switch {
+	case f.URLSanitizer == nil:
+		p.writeString("default")
+	default:
+		p.writeString("custom:" + f.URLSanitizerName)
+	}
+	if f.StyleSanitizer != nil {
+		p.writeString("style:" + f.StyleSanitizerName)
+	}
+
+	p.h.Sum(ret[:0])
+	return
+}
+
+// Fingerprint returns a hash of the configuration of f in hex, which
+// covers the AllowList or the Policy currently used, the URLSanitizerName,
+// the Limits and the Document. See AllowList.Fingerprint for more details.
+//
+// Funcs can not be hashed, so a custom URLSanitizer is identified by the
+// URLSanitizerName only, the StyleSanitizer by the StyleSanitizerName only,
+// and the Hook is not covered.
+func (f *HTMLSanitizer) Fingerprint() string {
+	ret := f.fingerprint()
+	return hex.EncodeToString(ret[:])
+}
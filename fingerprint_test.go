@@ -0,0 +1,187 @@
+package htmlsanitizer_test
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleAllowList_Fingerprint() {
+	a := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "a", Attr: []string{"rel", "target"}, URLAttr: []string{"href"}},
+			{Name: "b"},
+		},
+		GlobalAttr: []string{"class", "id"},
+	}
+
+	// the same allowlist in another order
+	b := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "b"},
+			{Name: "a", Attr: []string{"target", "rel"}, URLAttr: []string{"href"}},
+		},
+		GlobalAttr: []string{"id", "class"},
+	}
+
+	fmt.Println(a.Fingerprint() == b.Fingerprint())
+	b.RemoveTag("b")
+	fmt.Println(a.Fingerprint() == b.Fingerprint())
+	// Output:
+	// true
+	// false
+}
+
+func TestFingerprintStable(t *testing.T) {
+	list := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "a", Attr: []string{"rel"}, URLAttr: []string{"href"}},
+		},
+		GlobalAttr:  []string{"class"},
+		NonHTMLTags: []*htmlsanitizer.Tag{{Name: "script"}},
+	}
+
+	// changes only with the fingerprintVersion, or all the stored fingerprints
+	// become stale
+	expected := "aa490f0d3c5b03cabb188224c1cd0465eeadbd962bf2b005e055b4e7a62cad36"
+	if fingerprint := list.Fingerprint(); fingerprint != expected {
+		t.Errorf("expect %s, got %s", expected, fingerprint)
+	}
+	if fingerprint := list.Compile().Fingerprint(); fingerprint != expected {
+		t.Errorf("expect the Policy fingerprint %s, got %s", expected, fingerprint)
+	}
+}
+
+func TestFingerprintEquivalent(t *testing.T) {
+	base := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "a", Attr: []string{"rel"}, URLAttr: []string{"href"}},
+			{Name: "b"},
+		},
+		GlobalAttr:  []string{"class", "id"},
+		NonHTMLTags: []*htmlsanitizer.Tag{{Name: "script"}, {Name: "style"}},
+	}
+
+	equivalents := []*htmlsanitizer.AllowList{
+		// reordered
+		{
+			Tags: []*htmlsanitizer.Tag{
+				{Name: "b"},
+				{Name: "a", Attr: []string{"rel"}, URLAttr: []string{"href"}},
+			},
+			GlobalAttr:  []string{"id", "class"},
+			NonHTMLTags: []*htmlsanitizer.Tag{{Name: "style"}, {Name: "script"}},
+		},
+		// duplicated
+		{
+			Tags: []*htmlsanitizer.Tag{
+				{Name: "a", Attr: []string{"rel", "rel", "href"}, URLAttr: []string{"href"}},
+				{Name: "b", Attr: []string{}},
+				{Name: "a", Attr: []string{"onclick"}},
+			},
+			GlobalAttr:  []string{"class", "id", "class"},
+			NonHTMLTags: []*htmlsanitizer.Tag{{Name: "script"}, {Name: "style", Attr: []string{"x"}}},
+		},
+	}
+
+	for i, list := range equivalents {
+		if list.Fingerprint() != base.Fingerprint() {
+			t.Errorf("expect the equivalent AllowList %d to have the same fingerprint", i)
+		}
+	}
+}
+
+func TestFingerprintChanges(t *testing.T) {
+	newList := func() *htmlsanitizer.AllowList {
+		return &htmlsanitizer.AllowList{
+			Tags: []*htmlsanitizer.Tag{
+				{Name: "a", Attr: []string{"rel"}, URLAttr: []string{"href"}},
+				{Name: "b"},
+			},
+			GlobalAttr:  []string{"class"},
+			NonHTMLTags: []*htmlsanitizer.Tag{{Name: "script"}},
+		}
+	}
+
+	pattern := regexp.MustCompile(`^[a-z]+$`)
+	relPattern := map[string]*regexp.Regexp{"rel": pattern}
+	classPattern := map[string]*regexp.Regexp{"class": pattern}
+	changes := map[string]func(l *htmlsanitizer.AllowList){
+		"add tag":    func(l *htmlsanitizer.AllowList) { l.Tags = append(l.Tags, &htmlsanitizer.Tag{Name: "i"}) },
+		"remove tag": func(l *htmlsanitizer.AllowList) { l.RemoveTag("b") },
+		"add attr":   func(l *htmlsanitizer.AllowList) { l.Tags[1].Attr = []string{"title"} },
+		"to URL attr": func(l *htmlsanitizer.AllowList) {
+			l.Tags[0].Attr, l.Tags[0].URLAttr = []string{"href"}, []string{"rel"}
+		},
+		"add URL attr":   func(l *htmlsanitizer.AllowList) { l.Tags[1].URLAttr = []string{"cite"} },
+		"move attr":      func(l *htmlsanitizer.AllowList) { l.Tags[0].Attr, l.Tags[1].Attr = nil, []string{"rel"} },
+		"global attr":    func(l *htmlsanitizer.AllowList) { l.GlobalAttr = append(l.GlobalAttr, "id") },
+		"NonHTMLTags":    func(l *htmlsanitizer.AllowList) { l.NonHTMLTags = nil },
+		"empty name":     func(l *htmlsanitizer.AllowList) { l.GlobalAttr = append(l.GlobalAttr, "") },
+		"joined strings": func(l *htmlsanitizer.AllowList) { l.Tags[0].Attr = []string{"r", "el"} },
+		"attr pattern":   func(l *htmlsanitizer.AllowList) { l.Tags[0].AttrPattern = relPattern },
+		"global pattern": func(l *htmlsanitizer.AllowList) { l.GlobalAttrPattern = classPattern },
+		"require attr":   func(l *htmlsanitizer.AllowList) { l.Tags[0].Require = []string{"rel"} },
+		"require global": func(l *htmlsanitizer.AllowList) { l.Tags[0].Require = []string{"class"} },
+		"empty SVG":      func(l *htmlsanitizer.AllowList) { l.SVG = &htmlsanitizer.AllowList{} },
+		"SVG": func(l *htmlsanitizer.AllowList) {
+			l.SVG = &htmlsanitizer.AllowList{Tags: []*htmlsanitizer.Tag{{Name: "svg"}}}
+		},
+	}
+
+	seen := map[string]string{newList().Fingerprint(): "original"}
+	for name, change := range changes {
+		list := newList()
+		change(list)
+		fingerprint := list.Fingerprint()
+		if other, ok := seen[fingerprint]; ok {
+			t.Errorf("expect %s to change the fingerprint, the same as %s", name, other)
+		}
+		seen[fingerprint] = name
+	}
+
+	if (*htmlsanitizer.AllowList)(nil).Fingerprint() == (&htmlsanitizer.AllowList{}).Fingerprint() {
+		t.Errorf("expect a nil AllowList to have a different fingerprint")
+	}
+}
+
+func TestHTMLSanitizerFingerprint(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	fingerprints := map[string]bool{}
+	add := func(name string) {
+		fingerprint := sanitizer.Fingerprint()
+		if fingerprints[fingerprint] {
+			t.Errorf("expect %s to change the fingerprint", name)
+		}
+		fingerprints[fingerprint] = true
+	}
+
+	add("original")
+
+	// the same policy compiled
+	original := sanitizer.Fingerprint()
+	sanitizer.SetPolicy(sanitizer.Compile())
+	if sanitizer.Fingerprint() != original {
+		t.Errorf("expect the compiled Policy to have the same fingerprint")
+	}
+
+	sanitizer.UpdatePolicy(func(l *htmlsanitizer.AllowList) { l.RemoveTag("a") })
+	add("policy")
+
+	sanitizer.URLSanitizer = htmlsanitizer.DefaultURLSanitizer
+	add("URLSanitizer")
+
+	sanitizer.URLSanitizerName = "v2"
+	add("URLSanitizerName")
+
+	sanitizer.StyleSanitizer = htmlsanitizer.EmailStyleSanitizer
+	add("StyleSanitizer")
+
+	sanitizer.StyleSanitizerName = "email"
+	add("StyleSanitizerName")
+
+	sanitizer.Limits.MaxDepth = 10
+	add("Limits")
+}
@@ -0,0 +1,114 @@
+package htmlsanitizer
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// foreignNamespace describes a foreign namespace of the inline elements,
+// such as the SVG, which is entered by its root element.
+type foreignNamespace struct {
+	// root element, e.g. svg
+	root string
+
+	// the names in their case, adjusted by the browsers when parsing, see
+	// https://html.spec.whatwg.org/multipage/parsing.html#creating-and-inserting-nodes
+	tagNames  map[string]string
+	attrNames map[string]string
+
+	// the HTML integration point always removed, in which the content is
+	// parsed as HTML by the browsers
+	integration string
+}
+
+// caseNames maps the lowercase names to their case in a foreign namespace.
+func caseNames(names ...string) map[string]string {
+	ret := make(map[string]string, len(names))
+	for _, name := range names {
+		ret[strings.ToLower(name)] = name
+	}
+	return ret
+}
+
+var (
+	svgNamespace  = &foreignNamespace{root: "svg", tagNames: svgTagNames, attrNames: svgAttrNames, integration: "foreignobject"}
+	mathNamespace = &foreignNamespace{root: "math", attrNames: mathAttrNames, integration: "annotation-xml"}
+)
+
+// foreignBreakoutTags are the HTML elements which end the foreign namespaces
+// when parsed by the browsers, which are not allowed in them, see
+// https://html.spec.whatwg.org/multipage/parsing.html#parsing-main-inforeign .
+var foreignBreakoutTags = map[string]bool{
+	"b": true, "big": true, "blockquote": true, "body": true, "br": true,
+	"center": true, "code": true, "dd": true, "div": true, "dl": true,
+	"dt": true, "em": true, "embed": true, "font": true, "h1": true,
+	"h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "head": true,
+	"hr": true, "i": true, "img": true, "li": true, "listing": true,
+	"menu": true, "meta": true, "nobr": true, "ol": true, "p": true,
+	"pre": true, "ruby": true, "s": true, "small": true, "span": true,
+	"strike": true, "strong": true, "sub": true, "sup": true, "table": true,
+	"tt": true, "u": true, "ul": true, "var": true,
+}
+
+// foreignAttrAllowed checks the attributes always removed in the foreign
+// namespaces, the event handlers and the animations of the href attributes.
+func foreignAttrAllowed(name, val []byte) bool {
+	if len(name) >= 2 && name[0] == 'o' && name[1] == 'n' {
+		return false
+	}
+	return string(name) != "attributename" || !svgHrefAttr(html.UnescapeString(string(val)))
+}
+
+// validateForeign validates l as the AllowList of the foreign namespace ns,
+// of which the fields are prefixed by prefix.
+func (l *AllowList) validateForeign(prefix string, ns *foreignNamespace) error {
+	if err := l.validate(prefix); err != nil {
+		return err
+	}
+	if len(l.NonHTMLTags) > 0 {
+		return &PolicyError{Field: prefix + "nonHTMLTags", Value: l.NonHTMLTags[0].Name, Reason: "nonHTMLTags are not allowed in a foreign namespace"}
+	}
+	if l.SVG != nil {
+		return &PolicyError{Field: prefix + "svg", Value: "", Reason: "nested SVG is not allowed"}
+	}
+	if l.MathML != nil {
+		return &PolicyError{Field: prefix + "math", Value: "", Reason: "nested MathML is not allowed"}
+	}
+	if len(l.CustomElements) > 0 {
+		return &PolicyError{Field: prefix + "customElements", Value: fmt.Sprint(l.CustomElements[0].Pattern), Reason: "customElements are not allowed in a foreign namespace"}
+	}
+	if len(l.NamespacePrefixes) > 0 {
+		return &PolicyError{Field: prefix + "namespacePrefixes", Value: l.NamespacePrefixes[0], Reason: "namespacePrefixes are not allowed in a foreign namespace"}
+	}
+
+	// checkAttrName checks the attributeName attribute
+	checkAttrName := func(field string, attrs []string, patterns map[string]*regexp.Regexp) error {
+		if !hasString(attrs, "attributename") {
+			return nil
+		}
+		pattern := patterns["attributename"]
+		if pattern == nil || pattern.MatchString("href") || pattern.MatchString("xlink:href") {
+			return &PolicyError{Field: field, Value: fmt.Sprint(pattern), Reason: "attributeName must match a pattern which does not match href"}
+		}
+		return nil
+	}
+
+	for i, tag := range l.Tags {
+		field := fmt.Sprintf("%stags[%d]", prefix, i)
+		switch {
+		case tag.Name == ns.integration:
+			return &PolicyError{Field: field + ".name", Value: tag.Name, Reason: "HTML integration point is not allowed"}
+		case foreignBreakoutTags[tag.Name]:
+			return &PolicyError{Field: field + ".name", Value: tag.Name, Reason: "HTML tag ending the foreign namespace is not allowed"}
+		case tag.Name != ns.root && (tag.Name == svgNamespace.root || tag.Name == mathNamespace.root):
+			return &PolicyError{Field: field + ".name", Value: tag.Name, Reason: "root of another namespace is not allowed"}
+		}
+		if err := checkAttrName(field+".attrPattern.attributename", tag.Attr, tag.AttrPattern); err != nil {
+			return err
+		}
+	}
+
+	return checkAttrName(prefix+"globalAttrPattern.attributename", l.GlobalAttr, l.GlobalAttrPattern)
+}
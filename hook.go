@@ -0,0 +1,90 @@
+package htmlsanitizer
+
+// TokenType is the type of a Token.
+type TokenType int
+
+// All the types of tokens.
+const (
+	// StartTagToken looks like <a href="x">.
+	StartTagToken TokenType = iota + 1
+
+	// EndTagToken looks like </a>.
+	EndTagToken
+
+	// TextToken is the text content between tags.
+	TextToken
+
+	// RawTextToken is the content of the NonHTMLTags, such as <style>.
+	RawTextToken
+)
+
+func (t TokenType) String() string {
+	switch t {
+	case StartTagToken:
+		return "StartTag"
+	case EndTagToken:
+		return "EndTag"
+	case TextToken:
+		return "Text"
+	case RawTextToken:
+		return "RawText"
+	}
+
+	return "Invalid"
+}
+
+// Attribute of a start tag.
+type Attribute struct {
+	// Name of the attribute, in lowercase.
+	Name string
+
+	// Value of the attribute. HTML entities in it are not unescaped, except
+	// for the sanitized URL attributes, and the style attributes sanitized
+	// by the StyleSanitizer, which are kept unescaped when returned by the
+	// Hook.
+	Value string
+
+	// Bare reports whether it's a name only attribute, such as `controls`
+	// in <video controls>. A bare attribute is written without any value.
+	Bare bool
+}
+
+// Token is a start tag, an end tag, or a piece of text of the HTML content.
+type Token struct {
+	Type TokenType
+
+	// Data is the lowercase tag name for StartTagToken and EndTagToken, or
+	// the text for TextToken and RawTextToken. Text is not unescaped, and it
+	// may be split into multiple tokens.
+	Data string
+
+	// Attr of the StartTagToken.
+	Attr []Attribute
+
+	// SelfClosing reports whether the StartTagToken ends with `/>`.
+	SelfClosing bool
+
+	// Position of the token. Only the Offset is available if positions are
+	// not tracked.
+	Position
+}
+
+// Hook transforms the tokens before they are written.
+//
+// The tokens returned by the Hook are validated against the AllowList
+// again: the tags and attributes not allowed are removed, URL attributes
+// are sanitized by the URLSanitizer, and text is escaped. So a Hook can not
+// introduce any unsafe content.
+type Hook interface {
+	// Transform is called with every allowed token, and appends the tokens
+	// to be written instead to dst. Returning dst as is drops the token.
+	Transform(dst []Token, t Token) []Token
+}
+
+// HookFunc is an adapter to allow the use of ordinary functions as Hook.
+type HookFunc func(dst []Token, t Token) []Token
+
+// Transform calls f(dst, t).
+func (f HookFunc) Transform(dst []Token, t Token) []Token {
+	return f(dst, t)
+}
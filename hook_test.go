@@ -0,0 +1,158 @@
+package htmlsanitizer_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleHookFunc() {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.Hook = htmlsanitizer.HookFunc(func(dst []htmlsanitizer.Token, t htmlsanitizer.Token) []htmlsanitizer.Token {
+		switch t.Data {
+		case "b":
+			t.Data = "strong"
+		case "h1":
+			t.Data = "h3"
+		case "table":
+			// wrap the table with a div
+			wrapper := htmlsanitizer.Token{Type: t.Type, Data: "div"}
+			if t.Type == htmlsanitizer.StartTagToken {
+				wrapper.Attr = []htmlsanitizer.Attribute{{Name: "class", Value: "table-wrapper"}}
+				return append(dst, wrapper, t)
+			}
+			return append(dst, t, wrapper)
+		}
+		return append(dst, t)
+	})
+
+	data := `<h1>Title</h1><b class="x">bold</b><table><tr><td>1</td></tr></table>`
+	output, _ := sanitizer.SanitizeString(data)
+	fmt.Print(output)
+	// Output:
+	// <h3>Title</h3><strong class="x">bold</strong><div class="table-wrapper"><table><tr><td>1</td></tr></table></div>
+}
+
+func TestHookRevalidate(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.AllowList.Tags = append(sanitizer.AllowList.Tags, &htmlsanitizer.Tag{Name: "style"})
+	sanitizer.Hook = htmlsanitizer.HookFunc(func(dst []htmlsanitizer.Token, t htmlsanitizer.Token) []htmlsanitizer.Token {
+		switch t.Type {
+		case htmlsanitizer.StartTagToken:
+			switch t.Data {
+			case "a":
+				t.Attr = append(t.Attr,
+					htmlsanitizer.Attribute{Name: "onclick", Value: "alert(1)"},
+					htmlsanitizer.Attribute{Name: "HREF", Value: "javascript&colon;alert(1)"},
+					htmlsanitizer.Attribute{Name: "rel", Value: `"><script>`},
+					htmlsanitizer.Attribute{Name: "target", Bare: true},
+				)
+			case "i":
+				// not allowed to open a NonHTMLTags element
+				t.Data = "style"
+			case "p":
+				dst = append(dst, htmlsanitizer.Token{Type: htmlsanitizer.StartTagToken, Data: "script"})
+			}
+		case htmlsanitizer.EndTagToken:
+			if t.Data == "i" {
+				t.Data = "style"
+			}
+		case htmlsanitizer.TextToken:
+			if t.Data == "style" {
+				// not allowed to turn any other token into a NonHTMLTags
+				// element
+				t.Type = htmlsanitizer.StartTagToken
+				break
+			}
+			t.Data += "<img src=x onerror=alert(1)>"
+		case htmlsanitizer.RawTextToken:
+			t.Data = "</style>"
+		}
+		return append(dst, t)
+	})
+
+	testCases := []struct {
+		in  string
+		out string
+	}{
+		{
+			in:  `<a href="/x">`,
+			out: `<a href="/x" rel="&#34;&gt;&lt;script&gt;" target>`,
+		},
+		{
+			in:  `<i>x</i>`,
+			out: `x&lt;img src=x onerror=alert(1)&gt;`,
+		},
+		{
+			in:  `<p>`,
+			out: `<p>`,
+		},
+		{
+			in:  `<b>style</b><b>x</b>`,
+			out: `<b></b><b>x&lt;img src=x onerror=alert(1)&gt;</b>`,
+		},
+		{
+			in:  `<style>body{}</style>`,
+			out: `<style>&lt;/style&gt;</style>`,
+		},
+	}
+
+	for _, item := range testCases {
+		ret, err := sanitizer.SanitizeString(item.in)
+		if err != nil {
+			t.Errorf("unable to SanitizeString(%#v) err: %s", item.in, err)
+			continue
+		}
+
+		if ret != item.out {
+			t.Errorf("test failed for %#v, expect %#v, got %#v", item.in, item.out, ret)
+		}
+	}
+}
+
+func TestHookDrop(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.Hook = htmlsanitizer.HookFunc(func(dst []htmlsanitizer.Token, t htmlsanitizer.Token) []htmlsanitizer.Token {
+		if t.Data == "span" {
+			return dst
+		}
+		return append(dst, t)
+	})
+
+	data := `<div><span class="x">text</span></div>`
+	expected := `<div>text</div>`
+	ret, err := sanitizer.SanitizeString(data)
+	if err != nil {
+		t.Errorf("unable to SanitizeString err: %s", err)
+		return
+	}
+	if ret != expected {
+		t.Errorf("test failed for %#v, expect %#v, got %#v", data, expected, ret)
+	}
+}
+
+func TestHookIdentity(t *testing.T) {
+	data := `<a href="/?x=1&amp;lt=2">a</a><a href="/?q=a&amp;amp;b" style="font-family:&quot;a&amp;b&quot;">b</a>` +
+		`<a href="javascript&amp;colon;alert(1)" style="content:&amp;amp;">c</a>`
+
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.GlobalAttr = append(sanitizer.GlobalAttr, "style")
+	sanitizer.StyleSanitizer = func(style string) (string, bool) {
+		return style, !strings.Contains(style, "javascript")
+	}
+	expected := `<a href="/?x=1&lt=2">a</a><a href="/?q=a&amp;b" style="font-family:&#34;a&b&#34;">b</a>` +
+		`<a style="content:&amp;">c</a>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+
+	// the sanitized values are not unescaped again
+	sanitizer.Hook = htmlsanitizer.HookFunc(func(dst []htmlsanitizer.Token, t htmlsanitizer.Token) []htmlsanitizer.Token {
+		return append(dst, t)
+	})
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+}
@@ -0,0 +1,661 @@
+package htmlsanitizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PolicyVersion is the version of the JSON schema of AllowList.
+const PolicyVersion = 1
+
+// policyJSON is the JSON schema of AllowList.
+type policyJSON struct {
+	Version int `json:"version"`
+	namespaceJSON
+	NonHTMLTags    []string             `json:"nonHTMLTags"`
+	CustomElements []*customElementJSON `json:"customElements,omitempty"`
+	Prefixes       []string             `json:"namespacePrefixes,omitempty"`
+	SVG            *namespaceJSON       `json:"svg,omitempty"`
+	MathML         *namespaceJSON       `json:"math,omitempty"`
+}
+
+// namespaceJSON is the JSON schema of the tags and the global attributes of
+// a namespace, such as the AllowList.SVG and the AllowList.MathML.
+type namespaceJSON struct {
+	Tags              []*tagJSON        `json:"tags"`
+	GlobalAttr        []string          `json:"globalAttr"`
+	GlobalAttrPattern map[string]string `json:"globalAttrPattern,omitempty"`
+}
+
+// tagJSON is the JSON schema of Tag, with the patterns in their source text.
+type tagJSON struct {
+	Name        string            `json:"name"`
+	Attr        []string          `json:"attr,omitempty"`
+	URLAttr     []string          `json:"urlAttr,omitempty"`
+	AttrPattern map[string]string `json:"attrPattern,omitempty"`
+	Require     []string          `json:"require,omitempty"`
+}
+
+// customElementJSON is the JSON schema of CustomElement, with the patterns
+// in their source text.
+type customElementJSON struct {
+	Pattern     string            `json:"pattern"`
+	Attr        []string          `json:"attr,omitempty"`
+	URLAttr     []string          `json:"urlAttr,omitempty"`
+	AttrPattern map[string]string `json:"attrPattern,omitempty"`
+	Require     []string          `json:"require,omitempty"`
+}
+
+// marshalPatterns returns the source text of the patterns.
+func marshalPatterns(patterns map[string]*regexp.Regexp) map[string]string {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	ret := make(map[string]string, len(patterns))
+	for attr, pattern := range patterns {
+		ret[attr] = pattern.String()
+	}
+	return ret
+}
+
+// unmarshalPatterns compiles the patterns, field is the path of them.
+func unmarshalPatterns(field string, patterns map[string]string) (map[string]*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	attrs := make([]string, 0, len(patterns))
+	for attr := range patterns {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+
+	ret := make(map[string]*regexp.Regexp, len(patterns))
+	for _, attr := range attrs {
+		pattern, err := regexp.Compile(patterns[attr])
+		if err != nil {
+			return nil, &PolicyError{Field: field + "." + attr, Value: patterns[attr], Reason: err.Error()}
+		}
+		ret[attr] = pattern
+	}
+	return ret, nil
+}
+
+// validPatterns checks whether each of the patterns is for an allowed
+// attribute.
+func validPatterns(field string, patterns map[string]*regexp.Regexp, allowed ...[]string) error {
+	attrs := make([]string, 0, len(patterns))
+	for attr := range patterns {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+
+	for _, attr := range attrs {
+		reason := "pattern of an attribute not allowed"
+		for _, s := range allowed {
+			if hasString(s, attr) {
+				reason = ""
+			}
+		}
+		if reason == "" && patterns[attr] == nil {
+			reason = "pattern must not be nil"
+		}
+		if reason != "" {
+			return &PolicyError{Field: field + "." + attr, Value: fmt.Sprint(patterns[attr]), Reason: reason}
+		}
+	}
+
+	return nil
+}
+
+// maxRequire is the max number of the required attributes of a tag.
+const maxRequire = 64
+
+// validRequire checks whether each of the required attributes is allowed.
+func validRequire(field string, require []string, allowed ...[]string) error {
+	if len(require) > maxRequire {
+		return &PolicyError{Field: field, Value: fmt.Sprint(len(require)), Reason: fmt.Sprintf("too many required attributes, expect at most %d", maxRequire)}
+	}
+
+	for i, attr := range require {
+		reason := "required attribute not allowed"
+		for _, s := range allowed {
+			if hasString(s, attr) {
+				reason = ""
+			}
+		}
+		if reason == "" && hasString(require[:i], attr) {
+			reason = "duplicate attribute"
+		}
+		if reason != "" {
+			return &PolicyError{Field: fmt.Sprintf("%s[%d]", field, i), Value: attr, Reason: reason}
+		}
+	}
+
+	return nil
+}
+
+// urlAttrNames are the well-known attributes containing URLs or documents,
+// which are allowed only in URLAttr.
+var urlAttrNames = map[string]bool{
+	"action":     true,
+	"archive":    true,
+	"background": true,
+	"cite":       true,
+	"classid":    true,
+	"codebase":   true,
+	"data":       true,
+	"dynsrc":     true,
+	"formaction": true,
+	"href":       true,
+	"icon":       true,
+	"longdesc":   true,
+	"lowsrc":     true,
+	"manifest":   true,
+	"ping":       true,
+	"poster":     true,
+	"profile":    true,
+	"src":        true,
+	"srcdoc":     true,
+	"srcset":     true,
+	"usemap":     true,
+	"xlink:href": true,
+}
+
+// PolicyError describes an invalid AllowList.
+type PolicyError struct {
+	// Field is the path of the invalid field, e.g. tags[1].attr[0].
+	Field string
+
+	// Value is the invalid value.
+	Value string
+
+	// Reason describes why the value is invalid.
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("htmlsanitizer: invalid policy %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+// validName checks whether name is a lowercase tag or attribute name, which
+// may be namespaced with a prefix, such as xlink:href.
+func validName(name string) string {
+	if name == "" {
+		return "empty name"
+	}
+
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if !legalNameByte(b) {
+			return "illegal character in name"
+		}
+		if 'A' <= b && b <= 'Z' {
+			return "name must be lowercase"
+		}
+	}
+
+	if i := strings.IndexByte(name, ':'); i == 0 || i == len(name)-1 || strings.Count(name, ":") > 1 {
+		return "invalid namespaced name"
+	}
+	return ""
+}
+
+// knownPrefixes are the namespace prefixes always allowed in the names.
+var knownPrefixes = map[string]bool{"xml": true, "xlink": true, "xmlns": true}
+
+// namespacePrefix returns the namespace prefix of name, or "" if it's not
+// namespaced.
+func namespacePrefix(name string) string {
+	if i := strings.IndexByte(name, ':'); i > 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// validPrefixes checks whether all the namespaced names of l have the known
+// prefixes or the allowed ones, the fields are prefixed by prefix.
+func (l *AllowList) validPrefixes(prefix string, allowed map[string]bool) error {
+	check := func(field, name string) error {
+		if p := namespacePrefix(name); p != "" && !knownPrefixes[p] && !allowed[p] {
+			return &PolicyError{Field: field, Value: name, Reason: "namespace prefix not allowed"}
+		}
+		return nil
+	}
+	checkAll := func(field string, names []string) error {
+		for i, name := range names {
+			if err := check(fmt.Sprintf("%s[%d]", field, i), name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i, tag := range l.Tags {
+		field := fmt.Sprintf("%stags[%d]", prefix, i)
+		if err := check(field+".name", tag.Name); err != nil {
+			return err
+		}
+		if err := checkAll(field+".attr", tag.Attr); err != nil {
+			return err
+		}
+		if err := checkAll(field+".urlAttr", tag.URLAttr); err != nil {
+			return err
+		}
+	}
+	for i, c := range l.CustomElements {
+		field := fmt.Sprintf("%scustomElements[%d]", prefix, i)
+		if err := checkAll(field+".attr", c.Attr); err != nil {
+			return err
+		}
+		if err := checkAll(field+".urlAttr", c.URLAttr); err != nil {
+			return err
+		}
+	}
+	return checkAll(prefix+"globalAttr", l.GlobalAttr)
+}
+
+// validAttrs validates the attribute names of a tag, seen contains the names
+// validated before.
+func validAttrs(field string, attrs []string, seen map[string]bool) error {
+	for i, attr := range attrs {
+		reason := validName(attr)
+		switch {
+		case reason != "":
+		case strings.HasPrefix(attr, "on"):
+			reason = "event handler attributes are not allowed"
+		case seen[attr]:
+			reason = "duplicate attribute"
+		}
+		if reason != "" {
+			return &PolicyError{Field: fmt.Sprintf("%s[%d]", field, i), Value: attr, Reason: reason}
+		}
+		seen[attr] = true
+	}
+
+	return nil
+}
+
+// Validate checks whether l is a valid policy, the first problem found is
+// returned as a *PolicyError.
+//
+// The names must be lowercase. The tags and the attributes of each tag must
+// not be duplicated. The event handler attributes such as onclick are not
+// allowed, and the URL-related attributes such as href and srcdoc are
+// allowed only in the URLAttr. The patterns and the required attributes
+// must be of the allowed attributes.
+//
+// The CustomElements are validated the same as the tags, and their patterns
+// must not be nil or duplicated.
+//
+// The names may contain ':', '_' and '.', and a namespaced name such as
+// epub:type must have a prefix of xml, xlink, xmlns or the
+// NamespacePrefixes.
+//
+// The SVG and the MathML are validated the same, besides, they must not
+// have any NonHTMLTags, SVG or MathML, nor allow the HTML integration points
+// such as foreignObject and annotation-xml, the root of the other namespace,
+// or the HTML elements ending them such as <p>. The attributeName attributes
+// must match a pattern which does not match href.
+func (l *AllowList) Validate() error {
+	if err := l.validate(""); err != nil {
+		return err
+	}
+	if l == nil {
+		return nil
+	}
+
+	prefixes := make(map[string]bool, len(l.NamespacePrefixes))
+	for i, p := range l.NamespacePrefixes {
+		reason := validName(p)
+		switch {
+		case reason != "":
+		case strings.IndexByte(p, ':') >= 0:
+			reason = "illegal character in name"
+		case prefixes[p] || knownPrefixes[p]:
+			reason = "duplicate prefix"
+		}
+		if reason != "" {
+			return &PolicyError{Field: fmt.Sprintf("namespacePrefixes[%d]", i), Value: p, Reason: reason}
+		}
+		prefixes[p] = true
+	}
+	if err := l.validPrefixes("", prefixes); err != nil {
+		return err
+	}
+
+	if l.SVG != nil {
+		if err := l.SVG.validateForeign("svg.", svgNamespace); err != nil {
+			return err
+		}
+		if err := l.SVG.validPrefixes("svg.", prefixes); err != nil {
+			return err
+		}
+	}
+	if l.MathML != nil {
+		if err := l.MathML.validateForeign("math.", mathNamespace); err != nil {
+			return err
+		}
+		return l.MathML.validPrefixes("math.", prefixes)
+	}
+	return nil
+}
+
+// validNonURLAttrs checks that none of the attrs is URL-related, which must
+// be sanitized as a URLAttr.
+func validNonURLAttrs(field string, attrs []string) error {
+	for i, attr := range attrs {
+		if urlAttrNames[attr] {
+			return &PolicyError{Field: fmt.Sprintf("%s[%d]", field, i), Value: attr, Reason: "URL-related attribute must be a URLAttr"}
+		}
+	}
+	return nil
+}
+
+// validate validates l, of which the fields are prefixed by prefix.
+func (l *AllowList) validate(prefix string) error {
+	if l == nil {
+		return nil
+	}
+
+	urlAttrs := make(map[string]bool)
+	for name := range urlAttrNames {
+		urlAttrs[name] = true
+	}
+
+	tags := make(map[string]bool, len(l.Tags))
+	for i, tag := range l.Tags {
+		field := fmt.Sprintf("%stags[%d]", prefix, i)
+		if tag == nil {
+			return &PolicyError{Field: field, Value: "null", Reason: "tag must not be null"}
+		}
+
+		reason := validName(tag.Name)
+		if reason == "" && tags[tag.Name] {
+			reason = "duplicate tag"
+		}
+		if reason != "" {
+			return &PolicyError{Field: field + ".name", Value: tag.Name, Reason: reason}
+		}
+		tags[tag.Name] = true
+
+		seen := make(map[string]bool)
+		if err := validAttrs(field+".attr", tag.Attr, seen); err != nil {
+			return err
+		}
+		if err := validAttrs(field+".urlAttr", tag.URLAttr, seen); err != nil {
+			return err
+		}
+		if err := validNonURLAttrs(field+".attr", tag.Attr); err != nil {
+			return err
+		}
+		if err := validPatterns(field+".attrPattern", tag.AttrPattern, tag.Attr, tag.URLAttr); err != nil {
+			return err
+		}
+		for _, attr := range tag.URLAttr {
+			urlAttrs[attr] = true
+		}
+	}
+
+	patterns := make(map[string]bool, len(l.CustomElements))
+	for i, c := range l.CustomElements {
+		field := fmt.Sprintf("%scustomElements[%d]", prefix, i)
+		if c == nil {
+			return &PolicyError{Field: field, Value: "null", Reason: "custom element must not be null"}
+		}
+		if c.Pattern == nil {
+			return &PolicyError{Field: field + ".pattern", Value: "", Reason: "pattern must not be nil"}
+		}
+		if patterns[c.Pattern.String()] {
+			return &PolicyError{Field: field + ".pattern", Value: c.Pattern.String(), Reason: "duplicate pattern"}
+		}
+		patterns[c.Pattern.String()] = true
+
+		seen := make(map[string]bool)
+		if err := validAttrs(field+".attr", c.Attr, seen); err != nil {
+			return err
+		}
+		if err := validAttrs(field+".urlAttr", c.URLAttr, seen); err != nil {
+			return err
+		}
+		if err := validNonURLAttrs(field+".attr", c.Attr); err != nil {
+			return err
+		}
+		if err := validPatterns(field+".attrPattern", c.AttrPattern, c.Attr, c.URLAttr); err != nil {
+			return err
+		}
+		if err := validRequire(field+".require", c.Require, c.Attr, c.URLAttr, l.GlobalAttr); err != nil {
+			return err
+		}
+		for _, attr := range c.URLAttr {
+			urlAttrs[attr] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	if err := validAttrs(prefix+"globalAttr", l.GlobalAttr, seen); err != nil {
+		return err
+	}
+	if err := validPatterns(prefix+"globalAttrPattern", l.GlobalAttrPattern, l.GlobalAttr); err != nil {
+		return err
+	}
+	for i, tag := range l.Tags {
+		if err := validRequire(fmt.Sprintf("%stags[%d].require", prefix, i), tag.Require, tag.Attr, tag.URLAttr, l.GlobalAttr); err != nil {
+			return err
+		}
+	}
+	for i, attr := range l.GlobalAttr {
+		if urlAttrs[attr] {
+			return &PolicyError{
+				Field:  fmt.Sprintf("%sglobalAttr[%d]", prefix, i),
+				Value:  attr,
+				Reason: "URL-related attributes are not allowed globally",
+			}
+		}
+	}
+
+	nonHTMLTags := make(map[string]bool, len(l.NonHTMLTags))
+	for i, tag := range l.NonHTMLTags {
+		field := fmt.Sprintf("%snonHTMLTags[%d]", prefix, i)
+		if tag == nil {
+			return &PolicyError{Field: field, Value: "null", Reason: "tag must not be null"}
+		}
+
+		reason := validName(tag.Name)
+		if reason == "" && nonHTMLTags[tag.Name] {
+			reason = "duplicate tag"
+		}
+		if reason != "" {
+			return &PolicyError{Field: field, Value: tag.Name, Reason: reason}
+		}
+		nonHTMLTags[tag.Name] = true
+	}
+
+	return nil
+}
+
+// marshalNamespace returns the JSON schema of the tags and the global
+// attributes of l.
+func marshalNamespace(l *AllowList) namespaceJSON {
+	ns := namespaceJSON{
+		Tags:              make([]*tagJSON, 0, len(l.Tags)),
+		GlobalAttr:        l.GlobalAttr,
+		GlobalAttrPattern: marshalPatterns(l.GlobalAttrPattern),
+	}
+	for _, tag := range l.Tags {
+		ns.Tags = append(ns.Tags, &tagJSON{
+			Name:        tag.Name,
+			Attr:        tag.Attr,
+			URLAttr:     tag.URLAttr,
+			AttrPattern: marshalPatterns(tag.AttrPattern),
+			Require:     tag.Require,
+		})
+	}
+	if ns.GlobalAttr == nil {
+		ns.GlobalAttr = []string{}
+	}
+	return ns
+}
+
+// unmarshalNamespace decodes the tags and the global attributes into l,
+// prefix is the path of them.
+func unmarshalNamespace(l *AllowList, prefix string, ns *namespaceJSON) error {
+	l.GlobalAttr = ns.GlobalAttr
+	for i, tag := range ns.Tags {
+		if tag == nil {
+			l.Tags = append(l.Tags, nil)
+			continue
+		}
+
+		patterns, err := unmarshalPatterns(fmt.Sprintf("%stags[%d].attrPattern", prefix, i), tag.AttrPattern)
+		if err != nil {
+			return err
+		}
+		l.Tags = append(l.Tags, &Tag{
+			Name:        tag.Name,
+			Attr:        tag.Attr,
+			URLAttr:     tag.URLAttr,
+			AttrPattern: patterns,
+			Require:     tag.Require,
+		})
+	}
+
+	patterns, err := unmarshalPatterns(prefix+"globalAttrPattern", ns.GlobalAttrPattern)
+	if err != nil {
+		return err
+	}
+	l.GlobalAttrPattern = patterns
+	return nil
+}
+
+// MarshalJSON encodes the valid AllowList into JSON with the versioned
+// schema, e.g.
+//
+//	{
+//	  "version": 1,
+//	  "tags": [
+//	    {"name": "a", "attr": ["rel"], "urlAttr": ["href"]},
+//	    {"name": "time", "attr": ["datetime"], "attrPattern": {"datetime": "^[0-9:TZ-]+$"}},
+//	    {"name": "button", "attr": ["type"], "attrPattern": {"type": "^button$"}, "require": ["type"]}
+//	  ],
+//	  "globalAttr": ["class", "id"],
+//	  "globalAttrPattern": {"id": "^[a-z][a-z0-9-]*$"},
+//	  "nonHTMLTags": ["script", "style"],
+//	  "customElements": [{"pattern": "^ds-[a-z0-9-]+$", "attr": ["variant"]}],
+//	  "svg": {
+//	    "tags": [{"name": "svg", "attr": ["viewbox"]}, {"name": "path", "attr": ["d"]}],
+//	    "globalAttr": ["fill"]
+//	  },
+//	  "math": {
+//	    "tags": [{"name": "math"}, {"name": "mi"}, {"name": "mfrac"}],
+//	    "globalAttr": ["mathvariant"]
+//	  }
+//	}
+func (l *AllowList) MarshalJSON() ([]byte, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := policyJSON{
+		Version:       PolicyVersion,
+		namespaceJSON: marshalNamespace(l),
+		NonHTMLTags:   make([]string, 0, len(l.NonHTMLTags)),
+		Prefixes:      l.NamespacePrefixes,
+	}
+	for _, c := range l.CustomElements {
+		p.CustomElements = append(p.CustomElements, &customElementJSON{
+			Pattern:     c.Pattern.String(),
+			Attr:        c.Attr,
+			URLAttr:     c.URLAttr,
+			AttrPattern: marshalPatterns(c.AttrPattern),
+			Require:     c.Require,
+		})
+	}
+	for _, tag := range l.NonHTMLTags {
+		p.NonHTMLTags = append(p.NonHTMLTags, tag.Name)
+	}
+	if l.SVG != nil {
+		svg := marshalNamespace(l.SVG)
+		p.SVG = &svg
+	}
+	if l.MathML != nil {
+		math := marshalNamespace(l.MathML)
+		p.MathML = &math
+	}
+
+	return json.Marshal(p)
+}
+
+// UnmarshalJSON decodes and validates the AllowList from JSON. Unknown
+// fields and unsupported versions are rejected.
+func (l *AllowList) UnmarshalJSON(data []byte) error {
+	var p policyJSON
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&p); err != nil {
+		return fmt.Errorf("htmlsanitizer: invalid policy JSON: %s", err)
+	}
+
+	if p.Version != PolicyVersion {
+		return &PolicyError{
+			Field:  "version",
+			Value:  fmt.Sprint(p.Version),
+			Reason: fmt.Sprintf("unsupported version, expect %d", PolicyVersion),
+		}
+	}
+
+	var ret AllowList
+	if err := unmarshalNamespace(&ret, "", &p.namespaceJSON); err != nil {
+		return err
+	}
+	for _, name := range p.NonHTMLTags {
+		ret.NonHTMLTags = append(ret.NonHTMLTags, &Tag{Name: name})
+	}
+	for i, c := range p.CustomElements {
+		field := fmt.Sprintf("customElements[%d]", i)
+		if c == nil {
+			ret.CustomElements = append(ret.CustomElements, nil)
+			continue
+		}
+
+		pattern, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return &PolicyError{Field: field + ".pattern", Value: c.Pattern, Reason: err.Error()}
+		}
+		patterns, err := unmarshalPatterns(field+".attrPattern", c.AttrPattern)
+		if err != nil {
+			return err
+		}
+		ret.CustomElements = append(ret.CustomElements, &CustomElement{
+			Pattern:     pattern,
+			Attr:        c.Attr,
+			URLAttr:     c.URLAttr,
+			AttrPattern: patterns,
+			Require:     c.Require,
+		})
+	}
+	ret.NamespacePrefixes = p.Prefixes
+	if p.SVG != nil {
+		ret.SVG = new(AllowList)
+		if err := unmarshalNamespace(ret.SVG, "svg.", p.SVG); err != nil {
+			return err
+		}
+	}
+	if p.MathML != nil {
+		ret.MathML = new(AllowList)
+		if err := unmarshalNamespace(ret.MathML, "math.", p.MathML); err != nil {
+			return err
+		}
+	}
+	if err := ret.Validate(); err != nil {
+		return err
+	}
+
+	*l = ret
+	return nil
+}
@@ -0,0 +1,237 @@
+package htmlsanitizer_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleAllowList_MarshalJSON() {
+	list := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "a", Attr: []string{"rel"}, URLAttr: []string{"href"}},
+			{Name: "b"},
+		},
+		GlobalAttr:  []string{"class"},
+		NonHTMLTags: []*htmlsanitizer.Tag{{Name: "script"}},
+	}
+
+	// e.g. export the DefaultAllowList into a file
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(list); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// {
+	//   "version": 1,
+	//   "tags": [
+	//     {
+	//       "name": "a",
+	//       "attr": [
+	//         "rel"
+	//       ],
+	//       "urlAttr": [
+	//         "href"
+	//       ]
+	//     },
+	//     {
+	//       "name": "b"
+	//     }
+	//   ],
+	//   "globalAttr": [
+	//     "class"
+	//   ],
+	//   "nonHTMLTags": [
+	//     "script"
+	//   ]
+	// }
+}
+
+func ExampleAllowList_UnmarshalJSON() {
+	data := `{"version": 1, "tags": [{"name": "a", "attr": ["onclick"]}]}`
+
+	var list htmlsanitizer.AllowList
+	err := json.Unmarshal([]byte(data), &list)
+	fmt.Println(err)
+	// Output:
+	// htmlsanitizer: invalid policy tags[0].attr[0] "onclick": event handler attributes are not allowed
+}
+
+func TestAllowListJSON(t *testing.T) {
+	data, err := json.Marshal(htmlsanitizer.DefaultAllowList)
+	if err != nil {
+		t.Fatalf("unable to marshal DefaultAllowList err: %s", err)
+	}
+
+	list := new(htmlsanitizer.AllowList)
+	if err := json.Unmarshal(data, list); err != nil {
+		t.Fatalf("unable to unmarshal DefaultAllowList err: %s", err)
+	}
+	if list.Fingerprint() != htmlsanitizer.DefaultAllowList.Fingerprint() {
+		t.Errorf("expect the same AllowList after unmarshal, got %s", data)
+	}
+
+	data, err = json.Marshal(&htmlsanitizer.AllowList{})
+	if err != nil || string(data) != `{"version":1,"tags":[],"globalAttr":[],"nonHTMLTags":[]}` {
+		t.Errorf("unexpected empty AllowList %s, err: %v", data, err)
+	}
+}
+
+func TestAllowListJSONInvalid(t *testing.T) {
+	testCases := []struct {
+		in    string
+		field string
+	}{
+		{`{"version": 2, "tags": []}`, "version"},
+		{`{"tags": []}`, "version"},
+		{`{"version": 1, "tags": [{"name": "A"}]}`, "tags[0].name"},
+		{`{"version": 1, "tags": [{"name": ""}]}`, "tags[0].name"},
+		{`{"version": 1, "tags": [{"name": "a b"}]}`, "tags[0].name"},
+		{`{"version": 1, "tags": [null]}`, "tags[0]"},
+		{`{"version": 1, "tags": [{"name": "a"}, {"name": "b"}, {"name": "a"}]}`, "tags[2].name"},
+		{`{"version": 1, "tags": [{"name": "a", "attr": ["rel", "Title"]}]}`, "tags[0].attr[1]"},
+		{`{"version": 1, "tags": [{"name": "a", "attr": ["href"], "urlAttr": ["href"]}]}`, "tags[0].urlAttr[0]"},
+		{`{"version": 1, "tags": [{"name": "img", "urlAttr": ["onerror"]}]}`, "tags[0].urlAttr[0]"},
+		{`{"version": 1, "globalAttr": ["class", "ONCLICK"]}`, "globalAttr[1]"},
+		{`{"version": 1, "globalAttr": ["onclick"]}`, "globalAttr[0]"},
+		{`{"version": 1, "globalAttr": ["href"]}`, "globalAttr[0]"},
+		{`{"version": 1, "tags": [{"name": "a", "attr": ["title", "href"]}]}`, "tags[0].attr[1]"},
+		{`{"version": 1, "tags": [{"name": "iframe", "attr": ["srcdoc"]}]}`, "tags[0].attr[0]"},
+		{`{"version": 1, "customElements": [{"pattern": "^ds-", "attr": ["src"]}]}`, "customElements[0].attr[0]"},
+		{`{"version": 1, "tags": [{"name": "x", "urlAttr": ["foo"]}], "globalAttr": ["id", "foo"]}`, "globalAttr[1]"},
+		{`{"version": 1, "nonHTMLTags": ["script", "script"]}`, "nonHTMLTags[1]"},
+		{`{"version": 1, "tags": [{"name": "a", "attr": ["rel"], "attrPattern": {"rel": "("}}]}`, "tags[0].attrPattern.rel"},
+		{`{"version": 1, "tags": [{"name": "a", "attrPattern": {"rel": "^x$"}}]}`, "tags[0].attrPattern.rel"},
+		{`{"version": 1, "globalAttr": ["id"], "globalAttrPattern": {"class": "^x$"}}`, "globalAttrPattern.class"},
+		{`{"version": 1, "tags": [{"name": "button", "attr": ["type"], "require": ["type", "name"]}]}`, "tags[0].require[1]"},
+		{`{"version": 1, "tags": [{"name": "button", "attr": ["type"], "require": ["type", "type"]}]}`, "tags[0].require[1]"},
+		{`{"version": 1, "tags": [{"name": "p"}, {"name": "button", "require": ["class"]}], "globalAttr": ["id"]}`, "tags[1].require[0]"},
+		{`{"version": 1, "tags": [{"name": ":p"}]}`, "tags[0].name"},
+		{`{"version": 1, "tags": [{"name": "p", "attr": ["a:b:c"]}]}`, "tags[0].attr[0]"},
+		{`{"version": 1, "tags": [{"name": "epub:switch"}]}`, "tags[0].name"},
+		{`{"version": 1, "tags": [{"name": "a", "urlAttr": ["x:href"]}], "namespacePrefixes": ["epub"]}`, "tags[0].urlAttr[0]"},
+		{`{"version": 1, "globalAttr": ["epub:type"]}`, "globalAttr[0]"},
+		{`{"version": 1, "namespacePrefixes": ["epub", "Ops"]}`, "namespacePrefixes[1]"},
+		{`{"version": 1, "namespacePrefixes": ["epub", "epub"]}`, "namespacePrefixes[1]"},
+		{`{"version": 1, "namespacePrefixes": ["xlink"]}`, "namespacePrefixes[0]"},
+		{`{"version": 1, "namespacePrefixes": ["a:b"]}`, "namespacePrefixes[0]"},
+		{`{"version": 1, "svg": {"tags": [{"name": "svg", "attr": ["epub:type"]}]}}`, "svg.tags[0].attr[0]"},
+	}
+
+	for _, item := range testCases {
+		var list htmlsanitizer.AllowList
+		err := json.Unmarshal([]byte(item.in), &list)
+
+		var policyErr *htmlsanitizer.PolicyError
+		if !errors.As(err, &policyErr) || policyErr.Field != item.field {
+			t.Errorf("expect a PolicyError at %s for %s, got %v", item.field, item.in, err)
+		}
+	}
+
+	for _, in := range []string{`{"version": 1, "unknown": true}`, `{"version": 1, "tags": [{"name": "a", "attrs": []}]}`, `[]`} {
+		var list htmlsanitizer.AllowList
+		if err := json.Unmarshal([]byte(in), &list); err == nil {
+			t.Errorf("expect an error for %s", in)
+		}
+	}
+
+	// invalid AllowList can not be marshaled
+	list := &htmlsanitizer.AllowList{GlobalAttr: []string{"src"}}
+	if _, err := json.Marshal(list); err == nil {
+		t.Errorf("expect an error to marshal an invalid AllowList")
+	}
+}
+
+func TestAllowListJSONPattern(t *testing.T) {
+	list := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{{
+			Name:        "a",
+			Attr:        []string{"rel"},
+			URLAttr:     []string{"href"},
+			AttrPattern: map[string]*regexp.Regexp{"href": regexp.MustCompile(`^https://`)},
+		}},
+		GlobalAttr:        []string{"id"},
+		GlobalAttrPattern: map[string]*regexp.Regexp{"id": regexp.MustCompile(`^[a-z]+$`)},
+	}
+
+	data, err := json.Marshal(list)
+	expected := `{"version":1,"tags":[{"name":"a","attr":["rel"],"urlAttr":["href"],"attrPattern":{"href":"^https://"}}],` +
+		`"globalAttr":["id"],"globalAttrPattern":{"id":"^[a-z]+$"},"nonHTMLTags":[]}`
+	if err != nil || string(data) != expected {
+		t.Errorf("expect %s, got %s, err: %v", expected, data, err)
+	}
+
+	decoded := new(htmlsanitizer.AllowList)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("unable to unmarshal err: %s", err)
+	}
+	if decoded.Fingerprint() != list.Fingerprint() {
+		t.Errorf("expect the same AllowList after unmarshal, got %+v", decoded)
+	}
+}
+
+func TestAllowListJSONNamespacePrefixes(t *testing.T) {
+	list := &htmlsanitizer.AllowList{
+		Tags:              []*htmlsanitizer.Tag{{Name: "epub:switch", Attr: []string{"xml:lang"}}},
+		GlobalAttr:        []string{"epub:type", "v_on"},
+		NamespacePrefixes: []string{"epub"},
+	}
+
+	data, err := json.Marshal(list)
+	expected := `{"version":1,"tags":[{"name":"epub:switch","attr":["xml:lang"]}],"globalAttr":["epub:type","v_on"],` +
+		`"nonHTMLTags":[],"namespacePrefixes":["epub"]}`
+	if err != nil || string(data) != expected {
+		t.Errorf("expect %s, got %s, err: %v", expected, data, err)
+	}
+
+	decoded := new(htmlsanitizer.AllowList)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("unable to unmarshal err: %s", err)
+	}
+	if !reflect.DeepEqual(decoded, list) {
+		t.Errorf("expect the same AllowList after unmarshal, got %+v", decoded)
+	}
+}
+
+func TestAllowListJSONRequire(t *testing.T) {
+	list := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{{
+			Name:        "button",
+			Attr:        []string{"type"},
+			AttrPattern: map[string]*regexp.Regexp{"type": regexp.MustCompile(`^button$`)},
+			Require:     []string{"type", "class"},
+		}},
+		GlobalAttr: []string{"class"},
+	}
+
+	data, err := json.Marshal(list)
+	expected := `{"version":1,"tags":[{"name":"button","attr":["type"],"attrPattern":{"type":"^button$"},"require":["type","class"]}],` +
+		`"globalAttr":["class"],"nonHTMLTags":[]}`
+	if err != nil || string(data) != expected {
+		t.Errorf("expect %s, got %s, err: %v", expected, data, err)
+	}
+
+	decoded := new(htmlsanitizer.AllowList)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("unable to unmarshal err: %s", err)
+	}
+	if decoded.Fingerprint() != list.Fingerprint() {
+		t.Errorf("expect the same AllowList after unmarshal, got %+v", decoded)
+	}
+
+	list.Tags[0].Require = make([]string, 65)
+	for i := range list.Tags[0].Require {
+		list.Tags[0].Require[i] = "type"
+	}
+	var policyErr *htmlsanitizer.PolicyError
+	if err := list.Validate(); !errors.As(err, &policyErr) || policyErr.Field != "tags[0].require" {
+		t.Errorf("expect a PolicyError for too many required attributes, got %v", err)
+	}
+}
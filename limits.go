@@ -0,0 +1,80 @@
+package htmlsanitizer
+
+import "fmt"
+
+// Limits specifies the resource limits for a HTMLSanitizer.
+// A zero value of any field means no limit.
+type Limits struct {
+	// MaxInputBytes limits the total size of the HTML content written to
+	// a single Writer.
+	MaxInputBytes int64
+
+	// MaxTagNameLen limits the length of a tag name.
+	MaxTagNameLen int
+
+	// MaxAttrValueLen limits the length of an attribute value.
+	MaxAttrValueLen int
+
+	// MaxAttrsPerTag limits the number of attributes of a single tag,
+	// including the ones that are not allowed.
+	MaxAttrsPerTag int
+
+	// MaxDepth limits the nesting depth of the allowed elements. An end tag
+	// closes the innermost open element of its name and the ones in it, and
+	// the end tags of no open elements are ignored.
+	MaxDepth int
+
+	// MaxOutputBytes limits the total size of the sanitized HTML content
+	// of a single Writer.
+	MaxOutputBytes int64
+}
+
+// LimitKind indicates which limit was exceeded.
+type LimitKind int
+
+// All the kinds of limits.
+const (
+	LimitInputBytes LimitKind = iota + 1
+	LimitTagNameLen
+	LimitAttrValueLen
+	LimitAttrsPerTag
+	LimitDepth
+	LimitOutputBytes
+)
+
+func (k LimitKind) String() string {
+	switch k {
+	case LimitInputBytes:
+		return "max input bytes"
+	case LimitTagNameLen:
+		return "max tag name length"
+	case LimitAttrValueLen:
+		return "max attribute value length"
+	case LimitAttrsPerTag:
+		return "max attributes per tag"
+	case LimitDepth:
+		return "max nesting depth"
+	case LimitOutputBytes:
+		return "max output bytes"
+	}
+
+	return fmt.Sprintf("LimitKind(%d)", int(k))
+}
+
+// LimitError is returned by the Writer once a limit is exceeded.
+// After that, all the subsequent writes will fail with the same error.
+type LimitError struct {
+	// Kind of the exceeded limit.
+	Kind LimitKind
+
+	// Limit is the configured value of the exceeded limit.
+	Limit int64
+
+	// Position of the input byte at which the limit was exceeded.
+	Position
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("htmlsanitizer: %s (%d) exceeded at line %d, column %d",
+		e.Kind, e.Limit, e.Line, e.Column)
+}
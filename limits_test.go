@@ -0,0 +1,186 @@
+package htmlsanitizer_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleLimits() {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.Limits.MaxDepth = 2
+
+	_, err := sanitizer.SanitizeString("<div>\n<p><b>deep</b></p></div>")
+
+	var limitErr *htmlsanitizer.LimitError
+	if errors.As(err, &limitErr) {
+		fmt.Println(limitErr.Kind, limitErr.Offset, limitErr.Line, limitErr.Column)
+	}
+	fmt.Println(err)
+	// Output:
+	// max nesting depth 11 2 6
+	// htmlsanitizer: max nesting depth (2) exceeded at line 2, column 6
+}
+
+func TestLimits(t *testing.T) {
+	testCases := []struct {
+		limits htmlsanitizer.Limits
+		in     string
+		kind   htmlsanitizer.LimitKind
+		offset int64
+		line   int
+		column int
+	}{
+		{
+			limits: htmlsanitizer.Limits{MaxInputBytes: 8},
+			in:     "<b>1234</b>",
+			kind:   htmlsanitizer.LimitInputBytes,
+			offset: 8,
+			line:   1,
+			column: 9,
+		},
+		{
+			limits: htmlsanitizer.Limits{MaxTagNameLen: 4},
+			in:     "<abbr></abcdef>",
+			kind:   htmlsanitizer.LimitTagNameLen,
+			offset: 12,
+			line:   1,
+			column: 13,
+		},
+		{
+			limits: htmlsanitizer.Limits{MaxAttrValueLen: 3},
+			in:     "<a\nclass=\"abcd\">",
+			kind:   htmlsanitizer.LimitAttrValueLen,
+			offset: 13,
+			line:   2,
+			column: 11,
+		},
+		{
+			limits: htmlsanitizer.Limits{MaxAttrValueLen: 3},
+			in:     "<a class=abcd>",
+			kind:   htmlsanitizer.LimitAttrValueLen,
+			offset: 12,
+			line:   1,
+			column: 13,
+		},
+		{
+			limits: htmlsanitizer.Limits{MaxAttrsPerTag: 2},
+			in:     "<a x y\n z>",
+			kind:   htmlsanitizer.LimitAttrsPerTag,
+			offset: 8,
+			line:   2,
+			column: 2,
+		},
+		{
+			limits: htmlsanitizer.Limits{MaxDepth: 1},
+			in:     "<p><br><img></p><p><b>",
+			kind:   htmlsanitizer.LimitDepth,
+			offset: 21,
+			line:   1,
+			column: 22,
+		},
+		{
+			// the end tags closing nothing do not reduce the depth
+			limits: htmlsanitizer.Limits{MaxDepth: 3},
+			in:     strings.Repeat("<div></i>", 4),
+			kind:   htmlsanitizer.LimitDepth,
+			offset: 31,
+			line:   1,
+			column: 32,
+		},
+		{
+			limits: htmlsanitizer.Limits{MaxDepth: 3},
+			in:     "<div></br><div></br><div></br><div></br>",
+			kind:   htmlsanitizer.LimitDepth,
+			offset: 34,
+			line:   1,
+			column: 35,
+		},
+		{
+			limits: htmlsanitizer.Limits{MaxOutputBytes: 10},
+			in:     "<p>abc</p><p>abc</p>",
+			kind:   htmlsanitizer.LimitOutputBytes,
+			offset: 13,
+			line:   1,
+			column: 14,
+		},
+	}
+
+	for _, item := range testCases {
+		sanitizer := htmlsanitizer.NewHTMLSanitizer()
+		sanitizer.Limits = item.limits
+
+		_, err := sanitizer.SanitizeString(item.in)
+		var limitErr *htmlsanitizer.LimitError
+		if !errors.As(err, &limitErr) {
+			t.Errorf("expect a LimitError for %#v, got %v", item.in, err)
+			continue
+		}
+
+		if limitErr.Kind != item.kind || limitErr.Offset != item.offset ||
+			limitErr.Line != item.line || limitErr.Column != item.column {
+			t.Errorf("test failed for %#v, expect %s at %d (%d:%d), got %s at %d (%d:%d)",
+				item.in, item.kind, item.offset, item.line, item.column,
+				limitErr.Kind, limitErr.Offset, limitErr.Line, limitErr.Column)
+		}
+	}
+}
+
+func TestLimitsNotExceeded(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.Limits = htmlsanitizer.Limits{
+		MaxInputBytes:   int64(len(testCases[len(testCases)-1].in)),
+		MaxTagNameLen:   10,
+		MaxAttrValueLen: 512,
+		MaxAttrsPerTag:  16,
+		MaxDepth:        8,
+		MaxOutputBytes:  1024,
+	}
+
+	item := testCases[len(testCases)-1]
+	ret, err := sanitizer.SanitizeString(item.in)
+	if err != nil {
+		t.Errorf("unable to SanitizeString err: %s", err)
+		return
+	}
+	if ret != item.out {
+		t.Errorf("test failed for %#v, expect %#v, got %#v", item.in, item.out, ret)
+	}
+}
+
+func TestLimitsAcrossWrites(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.Limits.MaxAttrValueLen = 8
+
+	o := new(bytes.Buffer)
+	w := sanitizer.NewWriter(o)
+	chunks := []string{"<p>a\nb</p>\n<a cla", "ss=\"1234", "56789\">"}
+
+	var err error
+	for _, chunk := range chunks {
+		if _, err = w.Write([]byte(chunk)); err != nil {
+			break
+		}
+	}
+
+	var limitErr *htmlsanitizer.LimitError
+	if !errors.As(err, &limitErr) {
+		t.Errorf("expect a LimitError, got %v", err)
+		return
+	}
+	if limitErr.Offset != 29 || limitErr.Line != 3 || limitErr.Column != 19 {
+		t.Errorf("unexpected position %d (%d:%d)", limitErr.Offset, limitErr.Line, limitErr.Column)
+	}
+
+	// all the subsequent writes should fail
+	if _, err := w.Write([]byte("<p>")); err != limitErr {
+		t.Errorf("expect the same LimitError, got %v", err)
+	}
+	if strings.Contains(o.String(), "<a") {
+		t.Errorf("unexpected output %#v", o.String())
+	}
+}
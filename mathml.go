@@ -0,0 +1,90 @@
+package htmlsanitizer
+
+import (
+	"regexp"
+)
+
+// mathAttrNames are the MathML attribute names in the MathML case.
+var mathAttrNames = caseNames("definitionURL")
+
+// patterns of the DefaultMathMLAllowList.
+var (
+	mathColor  = regexp.MustCompile(`^(?:transparent|#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+	mathLength = regexp.MustCompile(`^[+-]?[0-9.]{1,16}(?:[a-z]{1,8}|%)?$`)
+)
+
+// mathTag returns the tag of a MathML element, with the attributes.
+func mathTag(name string, attrs ...string) *Tag {
+	return &Tag{Name: name, Attr: attrs}
+}
+
+// DefaultMathMLAllowList for the inline MathML, such as the formulas
+// converted from LaTeX, which can be used as the AllowList.MathML. The
+// links, the images and the actions are not allowed, and the annotation-xml
+// elements are always removed.
+//
+// It is not recommended to modify the default list directly, use .Clone()
+// and then modify the new one instead.
+var DefaultMathMLAllowList = &AllowList{
+	Tags: []*Tag{
+		{
+			Name: "math",
+			Attr: []string{"display", "alttext", "xmlns"},
+			AttrPattern: map[string]*regexp.Regexp{
+				"display": regexp.MustCompile(`^(?:block|inline)$`),
+				"xmlns":   regexp.MustCompile(`^http://www\.w3\.org/1998/Math/MathML$`),
+			},
+		},
+		// tokens
+		mathTag("mi"),
+		mathTag("mn"),
+		mathTag("mo", "form", "fence", "separator", "lspace", "rspace", "stretchy", "symmetric",
+			"maxsize", "minsize", "largeop", "movablelimits", "accent"),
+		mathTag("ms", "lquote", "rquote"),
+		mathTag("mtext"),
+		mathTag("mspace", "width", "height", "depth"),
+		// layouts
+		mathTag("mrow"),
+		mathTag("mfrac", "linethickness", "numalign", "denomalign", "bevelled"),
+		mathTag("msqrt"),
+		mathTag("mroot"),
+		mathTag("mstyle"),
+		mathTag("merror"),
+		mathTag("mpadded", "width", "height", "depth", "lspace", "voffset"),
+		mathTag("mphantom"),
+		mathTag("mfenced", "open", "close", "separators"),
+		mathTag("menclose", "notation"),
+		// scripts and limits
+		mathTag("msub"),
+		mathTag("msup"),
+		mathTag("msubsup"),
+		mathTag("munder", "accentunder"),
+		mathTag("mover", "accent"),
+		mathTag("munderover", "accent", "accentunder"),
+		mathTag("mmultiscripts"),
+		mathTag("mprescripts"),
+		mathTag("none"),
+		// tables
+		mathTag("mtable", "align", "columnalign", "rowalign", "columnspacing", "rowspacing",
+			"columnlines", "rowlines", "frame", "framespacing", "equalrows", "equalcolumns", "width"),
+		mathTag("mtr", "columnalign", "rowalign"),
+		mathTag("mlabeledtr", "columnalign", "rowalign"),
+		mathTag("mtd", "columnalign", "rowalign", "columnspan", "rowspan"),
+		// semantics
+		mathTag("semantics"),
+		mathTag("annotation", "encoding"),
+		// no annotation-xml
+		// no maction
+		// no mglyph
+	},
+	GlobalAttr: []string{
+		"id", "class", "dir", "displaystyle", "scriptlevel", "mathvariant",
+		"mathcolor", "mathbackground", "mathsize",
+	},
+	GlobalAttrPattern: map[string]*regexp.Regexp{
+		"dir":            regexp.MustCompile(`^(?:ltr|rtl)$`),
+		"mathcolor":      mathColor,
+		"mathbackground": mathColor,
+		"mathsize":       mathLength,
+	},
+}
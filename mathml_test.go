@@ -0,0 +1,154 @@
+package htmlsanitizer_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func mathAllowList() *htmlsanitizer.AllowList {
+	list := htmlsanitizer.DefaultAllowList.Clone()
+	list.SVG = htmlsanitizer.DefaultSVGAllowList.Clone()
+	list.MathML = htmlsanitizer.DefaultMathMLAllowList.Clone()
+	return list
+}
+
+func ExampleAllowList_mathML() {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.AllowList.MathML = htmlsanitizer.DefaultMathMLAllowList
+
+	data := `<math display="block"><semantics><mfrac><msup><mi>x</mi><mn>2</mn></msup><mi>y</mi></mfrac>` +
+		`<annotation encoding="application/x-tex">\frac{x^2}{y}</annotation>` +
+		`<annotation-xml encoding="text/html"><img src=x onerror=alert(1)></annotation-xml></semantics></math>`
+	output, _ := sanitizer.SanitizeString(data)
+	fmt.Println(output)
+	// Output:
+	// <math display="block"><semantics><mfrac><msup><mi>x</mi><mn>2</mn></msup><mi>y</mi></mfrac><annotation encoding="application/x-tex">\frac{x^2}{y}</annotation></semantics></math>
+}
+
+func TestMathML(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{`<MATH xmlns="http://www.w3.org/1998/Math/MathML"><MROW><MI MATHVARIANT="bold">x</MI><mo stretchy="false">=</mo></MROW></MATH>`,
+			`<math xmlns="http://www.w3.org/1998/Math/MathML"><mrow><mi mathvariant="bold">x</mi><mo stretchy="false">=</mo></mrow></math>`},
+		{`<math display="inline" xmlns="https://evil.example.com/"><mi mathcolor="red" mathbackground="url(x)">x</mi></math>`,
+			`<math display="inline"><mi mathcolor="red">x</mi></math>`},
+		{`<math><mtable><mtr><mtd columnalign="left"><mn>1</mn></mtd></mtr></mtable></math>`,
+			`<math><mtable><mtr><mtd columnalign="left"><mn>1</mn></mtd></mtr></mtable></math>`},
+
+		// always removed
+		{`<math onclick="alert(1)"><mi onmouseover="alert(1)" href="javascript:alert(1)">x</mi></math>`, `<math><mi>x</mi></math>`},
+		{`<math><maction actiontype="statusline"><mi>x</mi><mtext>y</mtext></maction><mglyph src="x"></mglyph></math>`,
+			`<math><mi>x</mi><mtext>y</mtext></math>`},
+
+		// the annotation-xml elements, which may be HTML integration points,
+		// are removed with the content in the MathML namespace
+		{`<math><semantics><mi>x</mi><annotation-xml encoding="text/html"><style><img src=x onerror=alert(1)></style></annotation-xml></semantics></math>`,
+			`<math><semantics><mi>x</mi></semantics></math>`},
+		{`<math><annotation-xml encoding="application/xhtml+xml"><p onclick="x">a</p><svg><rect/></svg></annotation-xml></math>`,
+			`<math>a</math>`},
+
+		// known mXSS vectors
+		{`<math><mtext><table><mglyph><style><!--</style><img title="--&gt;&lt;/mglyph&gt;&lt;img&Tab;src=1&Tab;onerror=alert(1)&gt;">`,
+			`<math><mtext>`},
+		{`<form><math><mtext></form><form><mglyph><style></math><img src onerror=alert(1)>`, `<math><mtext>`},
+		{`<math><mi><style><img src=x onerror=alert(1)></style></mi></math>`, `<math><mi></mi></math>`},
+		{`<math><![CDATA[</math><img src=x onerror=alert(1)>]]></math>`, `<math>]]&gt;</math>`},
+
+		// the HTML elements and the other namespace are not allowed inside
+		// math
+		{`<math><p>x</p><b>y</b><a href="https://example.com/">z</a></math><p>ok</p>`, `<math>xyz</math><p>ok</p>`},
+		{`<math><svg><rect/></svg></math>`, `<math></math>`},
+		{`<svg><math><mi>x</mi></math></svg>`, `<svg>x</svg>`},
+		{`<math><math><mi>x</mi></math><mn>1</mn></math><mi>y</mi>`, `<math><math><mi>x</mi></math><mn>1</mn></math>y`},
+		{`</math><p>x</p>`, `</math><p>x</p>`},
+	}
+
+	for _, compiled := range []bool{false, true} {
+		sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: mathAllowList()}
+		if compiled {
+			sanitizer.SetPolicy(sanitizer.Compile())
+		}
+
+		for _, item := range testCases {
+			ret, err := sanitizer.SanitizeString(item.in)
+			if err != nil {
+				t.Errorf("unable to SanitizeString err: %s", err)
+			}
+			if ret != item.expected {
+				t.Errorf("compiled %v: expect %#v, got %#v", compiled, item.expected, ret)
+			}
+		}
+	}
+
+	// the names are written in the MathML case
+	list := htmlsanitizer.DefaultMathMLAllowList.Clone()
+	list.Tags = append(list.Tags, &htmlsanitizer.Tag{Name: "csymbol", URLAttr: []string{"definitionurl"}})
+	sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: &htmlsanitizer.AllowList{MathML: list}}
+	data := `<math><csymbol definitionURL="https://example.com/x">x</csymbol></math><csymbol definitionurl="https://example.com/y">`
+	expected := `<math><csymbol definitionURL="https://example.com/x">x</csymbol></math>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+}
+
+func TestMathMLJSON(t *testing.T) {
+	list := mathAllowList()
+	data, err := json.Marshal(list)
+	if err != nil {
+		t.Fatalf("unable to marshal err: %s", err)
+	}
+
+	decoded := new(htmlsanitizer.AllowList)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("unable to unmarshal err: %s", err)
+	}
+	if decoded.Fingerprint() != list.Fingerprint() {
+		t.Errorf("expect the same AllowList after unmarshal, got %+v", decoded)
+	}
+
+	withoutMath := list.Clone()
+	withoutMath.MathML = nil
+	if list.Fingerprint() == withoutMath.Fingerprint() {
+		t.Errorf("expect the MathML to change the fingerprint")
+	}
+	if err := htmlsanitizer.DefaultMathMLAllowList.Validate(); err != nil {
+		t.Errorf("expect a valid DefaultMathMLAllowList, got %s", err)
+	}
+}
+
+func TestMathMLInvalid(t *testing.T) {
+	testCases := []struct {
+		in    string
+		field string
+	}{
+		{`{"version": 1, "math": {"tags": [{"name": "MI"}]}}`, "math.tags[0].name"},
+		{`{"version": 1, "math": {"tags": [{"name": "semantics"}, {"name": "annotation-xml"}]}}`, "math.tags[1].name"},
+		{`{"version": 1, "math": {"tags": [{"name": "math"}, {"name": "svg"}]}}`, "math.tags[1].name"},
+		{`{"version": 1, "svg": {"tags": [{"name": "svg"}, {"name": "math"}]}}`, "svg.tags[1].name"},
+		{`{"version": 1, "math": {"tags": [{"name": "table"}]}}`, "math.tags[0].name"},
+		{`{"version": 1, "math": {"tags": [{"name": "mi", "attr": ["href"]}]}}`, "math.tags[0].attr[0]"},
+		{`{"version": 1, "math": {"globalAttr": ["onclick"]}}`, "math.globalAttr[0]"},
+	}
+
+	for _, item := range testCases {
+		var list htmlsanitizer.AllowList
+		err := json.Unmarshal([]byte(item.in), &list)
+
+		var policyErr *htmlsanitizer.PolicyError
+		if !errors.As(err, &policyErr) || policyErr.Field != item.field {
+			t.Errorf("expect a PolicyError at %s for %s, got %v", item.field, item.in, err)
+		}
+	}
+
+	list := &htmlsanitizer.AllowList{MathML: &htmlsanitizer.AllowList{SVG: &htmlsanitizer.AllowList{}}}
+	var policyErr *htmlsanitizer.PolicyError
+	if err := list.Validate(); !errors.As(err, &policyErr) || policyErr.Field != "math.svg" {
+		t.Errorf("expect a PolicyError at math.svg, got %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+package htmlsanitizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxMessageDepth is the max nesting depth of the multipart entities.
+const maxMessageDepth = 32
+
+// MessageSanitizer sanitizes the text/html parts of MIME email messages.
+type MessageSanitizer struct {
+	// Sanitizer sanitizes the text/html parts, e.g. the one returned by
+	// EmailPolicy, of which the URLSanitizer rewrites the cid: URLs.
+	Sanitizer *HTMLSanitizer
+
+	// CharsetReader, if not nil, is used to convert the text/html parts in
+	// the charsets other than UTF-8, US-ASCII, ISO-8859-1 and Windows-1252
+	// into UTF-8, the same as mime.WordDecoder. The charsets are always
+	// lowercase. If it's nil or returns an error, the message is rejected.
+	CharsetReader func(charset string, input io.Reader) (io.Reader, error)
+}
+
+// SanitizeMessage reads a raw RFC 5322 message from r, sanitizes all the
+// text/html parts, and writes the message to dst. See MessageSanitizer for
+// more details.
+func (f *HTMLSanitizer) SanitizeMessage(dst io.Writer, r io.Reader) error {
+	m := &MessageSanitizer{Sanitizer: f}
+	return m.SanitizeMessage(dst, r)
+}
+
+// SanitizeMessage reads a raw RFC 5322 message from r, sanitizes all the
+// text/html parts, and writes the message to dst.
+//
+// The multipart entities, such as multipart/alternative and
+// multipart/related, and the attached messages of message/rfc822 are walked
+// recursively. The text/html and application/xhtml+xml parts are decoded
+// from their Content-Transfer-Encoding and charset, sanitized, and written
+// as text/html in UTF-8 and quoted-printable. The other parts are written as
+// is. The headers are written in the canonical form, sorted by their keys.
+//
+// Malformed messages, such as the ones with an invalid Content-Type or an
+// unknown Content-Transfer-Encoding, are rejected.
+func (m *MessageSanitizer) SanitizeMessage(dst io.Writer, r io.Reader) error {
+	msg, err := mail.ReadMessage(bufio.NewReader(r))
+	if err != nil {
+		return fmt.Errorf("htmlsanitizer: invalid message: %s", err)
+	}
+
+	return m.sanitizeEntity(entityWriter(dst), textproto.MIMEHeader(msg.Header), msg.Body, 0)
+}
+
+// entityWriter returns the func writing the header of an entity to dst,
+// followed by its body.
+func entityWriter(dst io.Writer) func(textproto.MIMEHeader) (io.Writer, error) {
+	return func(header textproto.MIMEHeader) (io.Writer, error) {
+		bw := bufio.NewWriter(dst)
+		writeHeader(bw, header)
+		_, _ = bw.WriteString("\r\n")
+		return dst, bw.Flush()
+	}
+}
+
+// writeHeader writes the header sorted by the keys.
+func writeHeader(w *bufio.Writer, header textproto.MIMEHeader) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range header[key] {
+			_, _ = w.WriteString(key + ": " + value + "\r\n")
+		}
+	}
+}
+
+// sanitizeEntity sanitizes the entity with the header and the raw body, and
+// writes it to the writer returned by create.
+func (m *MessageSanitizer) sanitizeEntity(create func(textproto.MIMEHeader) (io.Writer, error), header textproto.MIMEHeader, body io.Reader, depth int) error {
+	mediaType := "text/plain"
+	var params map[string]string
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		var err error
+		mediaType, params, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return fmt.Errorf("htmlsanitizer: invalid Content-Type %q: %s", contentType, err)
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		return m.sanitizeMultipart(create, header, params["boundary"], body, depth)
+	case mediaType == "message/rfc822":
+		return m.sanitizeAttached(create, header, body, depth)
+	case mediaType == "text/html", mediaType == "application/xhtml+xml":
+		return m.sanitizeHTML(create, header, params, body)
+	}
+
+	w, err := create(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, body)
+	return err
+}
+
+func (m *MessageSanitizer) sanitizeMultipart(create func(textproto.MIMEHeader) (io.Writer, error), header textproto.MIMEHeader, boundary string, body io.Reader, depth int) error {
+	if depth >= maxMessageDepth {
+		return fmt.Errorf("htmlsanitizer: entities nested too deep")
+	}
+
+	w, err := create(header)
+	if err != nil {
+		return err
+	}
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return fmt.Errorf("htmlsanitizer: invalid multipart boundary %q: %s", boundary, err)
+	}
+
+	mr := multipart.NewReader(body, boundary)
+	for {
+		// the raw parts are not decoded from quoted-printable implicitly
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("htmlsanitizer: invalid multipart entity: %s", err)
+		}
+
+		if err := m.sanitizeEntity(mw.CreatePart, part.Header, part, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+// transferDecoder returns the reader decoding the body from the
+// Content-Transfer-Encoding of the header, and whether it's encoded.
+func transferDecoder(header textproto.MIMEHeader, body io.Reader) (io.Reader, bool, error) {
+	switch encoding := strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))); encoding {
+	case "", "7bit", "8bit", "binary":
+		return body, false, nil
+	case "quoted-printable":
+		return quotedprintable.NewReader(body), true, nil
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body), true, nil
+	default:
+		return nil, false, fmt.Errorf("htmlsanitizer: unknown Content-Transfer-Encoding %q", encoding)
+	}
+}
+
+// cloneHeader returns a copy of the header without the Content-Length.
+func cloneHeader(header textproto.MIMEHeader) textproto.MIMEHeader {
+	ret := make(textproto.MIMEHeader, len(header))
+	for key, values := range header {
+		ret[key] = append([]string(nil), values...)
+	}
+	ret.Del("Content-Length")
+	return ret
+}
+
+// sanitizeAttached sanitizes the attached message of message/rfc822, which
+// is written in 8bit if it was encoded.
+func (m *MessageSanitizer) sanitizeAttached(create func(textproto.MIMEHeader) (io.Writer, error), header textproto.MIMEHeader, body io.Reader, depth int) error {
+	if depth >= maxMessageDepth {
+		return fmt.Errorf("htmlsanitizer: entities nested too deep")
+	}
+
+	body, encoded, err := transferDecoder(header, body)
+	if err != nil {
+		return err
+	}
+	msg, err := mail.ReadMessage(bufio.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("htmlsanitizer: invalid attached message: %s", err)
+	}
+
+	newHeader := cloneHeader(header)
+	if encoded {
+		newHeader.Set("Content-Transfer-Encoding", "8bit")
+	}
+	w, err := create(newHeader)
+	if err != nil {
+		return err
+	}
+	return m.sanitizeEntity(entityWriter(w), textproto.MIMEHeader(msg.Header), msg.Body, depth+1)
+}
+
+func (m *MessageSanitizer) sanitizeHTML(create func(textproto.MIMEHeader) (io.Writer, error), header textproto.MIMEHeader, params map[string]string, body io.Reader) error {
+	body, _, err := transferDecoder(header, body)
+	if err != nil {
+		return err
+	}
+	if body, err = m.charsetReader(params["charset"], body); err != nil {
+		return err
+	}
+
+	newParams := map[string]string{"charset": "utf-8"}
+	for key, value := range params {
+		if key != "charset" {
+			newParams[key] = value
+		}
+	}
+	newHeader := cloneHeader(header)
+	newHeader.Set("Content-Type", mime.FormatMediaType("text/html", newParams))
+	newHeader.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	w, err := create(newHeader)
+	if err != nil {
+		return err
+	}
+	qw := quotedprintable.NewWriter(w)
+	hw := m.Sanitizer.newWriter(qw)
+	if _, err := io.Copy(hw, body); err != nil {
+		return err
+	}
+	if err := hw.Close(); err != nil {
+		return err
+	}
+	return qw.Close()
+}
+
+// charsetReader returns the reader converting the content in charset into
+// UTF-8.
+func (m *MessageSanitizer) charsetReader(charset string, r io.Reader) (io.Reader, error) {
+	charset = strings.ToLower(charset)
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return r, nil
+	case "iso-8859-1", "iso8859-1", "latin1":
+		return &singleByteReader{r: r, table: &latin1Table}, nil
+	case "windows-1252", "cp1252":
+		return &singleByteReader{r: r, table: &windows1252Table}, nil
+	}
+
+	if m.CharsetReader == nil {
+		return nil, fmt.Errorf("htmlsanitizer: unsupported charset %q", charset)
+	}
+	return m.CharsetReader(charset, r)
+}
+
+// latin1Table maps the bytes 0x80-0xFF of ISO-8859-1 to runes.
+var latin1Table = func() (t [128]rune) {
+	for i := range t {
+		t[i] = rune(0x80 + i)
+	}
+	return
+}()
+
+// windows1252Table maps the bytes 0x80-0xFF of Windows-1252 to runes, the
+// undefined ones are mapped to the C1 controls, the same as the browsers.
+var windows1252Table = func() (t [128]rune) {
+	t = latin1Table
+	copy(t[:], []rune{
+		0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+		0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+		0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+		0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+	})
+	return
+}()
+
+// singleByteReader converts the content in a single-byte charset into UTF-8.
+type singleByteReader struct {
+	r     io.Reader
+	table *[128]rune
+
+	// converted but not read yet
+	pending []byte
+	buf     [readBufferSize]byte
+}
+
+func (s *singleByteReader) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		n, err := s.r.Read(s.buf[:])
+		s.pending = s.pending[:0]
+		for _, b := range s.buf[:n] {
+			if b < utf8.RuneSelf {
+				s.pending = append(s.pending, b)
+				continue
+			}
+
+			var rb [utf8.UTFMax]byte
+			s.pending = append(s.pending, rb[:utf8.EncodeRune(rb[:], s.table[b-0x80])]...)
+		}
+
+		if err != nil && len(s.pending) == 0 {
+			return 0, err
+		}
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
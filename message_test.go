@@ -0,0 +1,246 @@
+package htmlsanitizer_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleHTMLSanitizer_SanitizeMessage() {
+	message := "From: alice@example.com\r\n" +
+		"Subject: Hello\r\n" +
+		"Content-Type: text/html; charset=iso-8859-1\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"<p onclick=3D\"x\">Caf=E9 <img src=3D\"cid:logo@example.com\"></p><script>x</script>\r\n"
+
+	sanitizer := htmlsanitizer.EmailPolicy(func(contentID string) (string, bool) {
+		return "/attachments/" + contentID, true
+	})
+
+	var buf bytes.Buffer
+	if err := sanitizer.SanitizeMessage(&buf, strings.NewReader(message)); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(strings.Replace(buf.String(), "\r\n", "\n", -1))
+	// Output:
+	// Content-Transfer-Encoding: quoted-printable
+	// Content-Type: text/html; charset=utf-8
+	// From: alice@example.com
+	// Subject: Hello
+	//
+	// <p>Caf=C3=A9 <img src=3D"/attachments/logo@example.com"></p>
+}
+
+var testMessage = strings.Replace(`From: alice@example.com
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="mixed"
+
+preamble
+--mixed
+Content-Type: multipart/alternative; boundary="alt"
+
+--alt
+Content-Type: text/plain; charset=us-ascii
+
+Hello <script>
+--alt
+Content-Type: multipart/related; boundary="rel"
+
+--rel
+Content-Type: text/html; charset=windows-1252
+Content-Transfer-Encoding: quoted-printable
+
+<p onclick=3D"x">=93Caf=E9=94 <img src=3D"cid:logo@example.com"></p><script>alert(1)=
+</script>
+--rel
+Content-Type: image/png
+Content-Transfer-Encoding: base64
+Content-ID: <logo@example.com>
+
+iVBORw0KGgo=
+--rel--
+
+--alt--
+--mixed
+Content-Type: TEXT/HTML
+Content-Transfer-Encoding: base64
+Content-Disposition: attachment; filename="x.html"
+
+PGEgaHJlZj0iamF2YXNjcmlwdDphbGVydCgxKSI+eDwvYT4=
+--mixed
+Content-Type: application/xhtml+xml
+
+<html xmlns="http://www.w3.org/1999/xhtml"><body><b onclick="x">xhtml</b></body></html>
+--mixed
+Content-Type: message/rfc822
+Content-Disposition: attachment
+
+From: bob@example.com
+Content-Type: multipart/alternative; boundary="fwd"
+
+--fwd
+Content-Type: text/html
+
+<i onmouseover="x">forwarded</i><script>alert(1)</script>
+--fwd--
+--mixed--
+`, "\n", "\r\n", -1)
+
+// walkMessage returns the decoded text/html parts and the raw other parts
+// of the message.
+func walkMessage(t *testing.T, header mail.Header, body io.Reader, ret *[]string) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("invalid Content-Type err: %s", err)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				t.Fatalf("invalid multipart err: %s", err)
+			}
+			walkMessage(t, mail.Header(part.Header), part, ret)
+		}
+	}
+
+	if mediaType == "message/rfc822" {
+		msg, err := mail.ReadMessage(body)
+		if err != nil {
+			t.Fatalf("invalid attached message err: %s", err)
+		}
+		walkMessage(t, msg.Header, msg.Body, ret)
+		return
+	}
+
+	if mediaType == "text/html" {
+		if header.Get("Content-Transfer-Encoding") != "quoted-printable" || params["charset"] != "utf-8" {
+			t.Errorf("unexpected header %v", header)
+		}
+		body = quotedprintable.NewReader(body)
+	}
+	data, _ := ioutil.ReadAll(body)
+	*ret = append(*ret, mediaType+": "+string(data))
+}
+
+func TestSanitizeMessage(t *testing.T) {
+	sanitizer := htmlsanitizer.EmailPolicy(func(contentID string) (string, bool) {
+		return "/attachments/" + contentID, true
+	})
+
+	var buf bytes.Buffer
+	if err := sanitizer.SanitizeMessage(&buf, strings.NewReader(testMessage)); err != nil {
+		t.Fatalf("unable to SanitizeMessage err: %s", err)
+	}
+
+	msg, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatalf("unable to read the sanitized message err: %s", err)
+	}
+	if msg.Header.Get("From") != "alice@example.com" {
+		t.Errorf("unexpected header %v", msg.Header)
+	}
+
+	var parts []string
+	walkMessage(t, msg.Header, msg.Body, &parts)
+	expected := []string{
+		"text/plain: Hello <script>",
+		"text/html: <p>“Café” <img src=\"/attachments/logo@example.com\"></p>",
+		"image/png: iVBORw0KGgo=",
+		"text/html: <a>x</a>",
+		"text/html: <b>xhtml</b>",
+		"text/html: <i>forwarded</i>",
+	}
+	if strings.Join(parts, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expect %#v, got %#v", expected, parts)
+	}
+}
+
+func TestSanitizeMessageAttached(t *testing.T) {
+	attached := "Content-Type: text/html\r\n\r\n<b onclick=x>x</b>"
+	message := "Content-Type: message/rfc822\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+		base64.StdEncoding.EncodeToString([]byte(attached))
+
+	var buf bytes.Buffer
+	if err := htmlsanitizer.NewHTMLSanitizer().SanitizeMessage(&buf, strings.NewReader(message)); err != nil {
+		t.Fatalf("unable to SanitizeMessage err: %s", err)
+	}
+	expected := "Content-Transfer-Encoding: 8bit\r\nContent-Type: message/rfc822\r\n\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\nContent-Type: text/html; charset=utf-8\r\n\r\n<b>x</b>"
+	if buf.String() != expected {
+		t.Errorf("expect %#v, got %#v", expected, buf.String())
+	}
+}
+
+func TestSanitizeMessageCharset(t *testing.T) {
+	message := "Content-Type: text/html; charset=\"ISO-2022-JP\"\r\n\r\n<b onclick=x>x</b>"
+
+	sanitizer := &htmlsanitizer.MessageSanitizer{Sanitizer: htmlsanitizer.NewHTMLSanitizer()}
+	if err := sanitizer.SanitizeMessage(ioutil.Discard, strings.NewReader(message)); err == nil {
+		t.Errorf("expect an error for the unsupported charset")
+	}
+
+	var charset string
+	sanitizer.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		charset = label
+		return input, nil
+	}
+	var buf bytes.Buffer
+	if err := sanitizer.SanitizeMessage(&buf, strings.NewReader(message)); err != nil {
+		t.Errorf("unable to SanitizeMessage err: %s", err)
+	}
+	if charset != "iso-2022-jp" || !strings.HasSuffix(buf.String(), "\r\n\r\n<b>x</b>") {
+		t.Errorf("unexpected charset %s and message %#v", charset, buf.String())
+	}
+}
+
+func TestSanitizeMessageErrors(t *testing.T) {
+	nested := "Content-Type: multipart/mixed; boundary=b\r\n\r\n" +
+		strings.Repeat("--b\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n", 40)
+
+	testCases := map[string]string{
+		"not a message":            "x",
+		"invalid Content-Type":     "Content-Type: text/html; charset\r\n\r\nx",
+		"unknown encoding":         "Content-Type: text/html\r\nContent-Transfer-Encoding: x-uuencode\r\n\r\nx",
+		"invalid base64":           "Content-Type: text/html\r\nContent-Transfer-Encoding: base64\r\n\r\n!!!!",
+		"missing boundary":         "Content-Type: multipart/mixed\r\n\r\nx",
+		"truncated multipart":      "Content-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\nContent-Type: text/html\r\n\r\nx",
+		"nested too deep":          nested,
+		"attached too deep":        strings.Repeat("Content-Type: message/rfc822\r\n\r\n", 40),
+		"invalid attached message": "Content-Type: message/rfc822\r\nContent-Transfer-Encoding: base64\r\n\r\n!!!!",
+		"invalid nested multipart": "Content-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\nContent-Type: x/\r\n\r\nx\r\n--b--\r\n",
+	}
+
+	for name, message := range testCases {
+		err := htmlsanitizer.NewHTMLSanitizer().SanitizeMessage(ioutil.Discard, strings.NewReader(message))
+		if err == nil {
+			t.Errorf("%s: expect an error", name)
+		}
+	}
+
+	// the limits of the sanitizer apply to each part
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.Limits.MaxInputBytes = 4
+	err := sanitizer.SanitizeMessage(ioutil.Discard, strings.NewReader("Content-Type: text/html\r\n\r\n<b>xxx</b>"))
+	var limitErr *htmlsanitizer.LimitError
+	if !errors.As(err, &limitErr) {
+		t.Errorf("expect a LimitError, got %v", err)
+	}
+}
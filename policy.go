@@ -0,0 +1,338 @@
+package htmlsanitizer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"regexp"
+)
+
+// lookup finds the allowed tags and attributes, which is implemented by both
+// *AllowList and *Policy.
+type lookup interface {
+	FindTag(p []byte) *Tag
+	checkNonHTMLTag(p []byte) *Tag
+
+	// attrAllowed checks whether the lowercase attribute name is allowed for
+	// tag, either by the tag itself or globally, and returns the pattern of
+	// its value if any.
+	attrAllowed(tag *Tag, name []byte) (ok, urlAttr bool, pattern *regexp.Regexp)
+
+	// svg returns the lookup of the SVG namespace, or nil if not allowed.
+	svg() lookup
+
+	// math returns the lookup of the MathML namespace, or nil if not allowed.
+	math() lookup
+}
+
+func (l *AllowList) attrAllowed(tag *Tag, name []byte) (ok, urlAttr bool, pattern *regexp.Regexp) {
+	if ok, urlAttr = tag.attrExists(name); ok {
+		pattern = tag.AttrPattern[string(name)]
+		return
+	}
+
+	if ok = l.attrExists(name); ok {
+		pattern = l.GlobalAttrPattern[string(name)]
+	}
+	return
+}
+
+// lowerTable maps each byte to its ASCII lowercase.
+var lowerTable = func() (t [256]byte) {
+	for i := range t {
+		t[i] = byte(i)
+	}
+	for b := 'A'; b <= 'Z'; b++ {
+		t[b] = byte(b) + 'a' - 'A'
+	}
+	return
+}()
+
+// nameBufSize is the size of the buffer on stack used to lowercase names.
+const nameBufSize = 64
+
+// attrRule is the compiled rule of an allowed attribute.
+type attrRule struct {
+	urlAttr bool
+	pattern *regexp.Regexp
+}
+
+// Policy is an immutable, compiled AllowList, created by AllowList.Compile.
+//
+// Unlike the AllowList, all the lookups of tags and attributes in a Policy
+// take O(1) time without any allocation, which makes it suitable for
+// the hot path. A Policy is safe for concurrent use.
+type Policy struct {
+	// frozen copy of the AllowList
+	list *AllowList
+
+	tags map[string]*Tag
+
+	// allowed attributes of each tag
+	attrs       map[*Tag]map[string]attrRule
+	globalAttr  map[string]attrRule
+	nonHTMLTags map[string]*Tag
+
+	// the longest tag name, longer names can never match
+	maxTagLen int
+
+	// AllowList.CustomElements with their compiled rules
+	customElements []*CustomElement
+	customTags     []*Tag
+
+	// compiled AllowList.SVG and AllowList.MathML
+	svgPolicy  *Policy
+	mathPolicy *Policy
+
+	// hash of the compiled AllowList
+	fingerprint [sha256.Size]byte
+}
+
+// Compile freezes the AllowList into an immutable Policy. The later changes
+// to the AllowList do not affect the Policy.
+func (l *AllowList) Compile() *Policy {
+	p := &Policy{
+		list:        new(AllowList),
+		tags:        make(map[string]*Tag),
+		attrs:       make(map[*Tag]map[string]attrRule),
+		globalAttr:  make(map[string]attrRule),
+		nonHTMLTags: make(map[string]*Tag),
+	}
+	p.fingerprint = l.fingerprint()
+	if l == nil {
+		p.list = nil
+		return p
+	}
+
+	for _, tag := range l.Tags {
+		tag = tag.Clone()
+		p.list.Tags = append(p.list.Tags, tag)
+
+		// the first one wins, the same as FindTag
+		if _, ok := p.tags[tag.Name]; ok {
+			continue
+		}
+
+		p.tags[tag.Name] = tag
+		p.attrs[tag] = attrRules(tag)
+		if len(tag.Name) > p.maxTagLen {
+			p.maxTagLen = len(tag.Name)
+		}
+	}
+
+	for _, c := range l.CustomElements {
+		if c == nil || c.Pattern == nil {
+			continue
+		}
+		c = c.clone()
+		tag := c.tag()
+		p.list.CustomElements = append(p.list.CustomElements, c)
+		p.customElements = append(p.customElements, c)
+		p.customTags = append(p.customTags, tag)
+		p.attrs[tag] = attrRules(tag)
+	}
+
+	p.list.GlobalAttr = append(p.list.GlobalAttr, l.GlobalAttr...)
+	p.list.GlobalAttrPattern = clonePatterns(l.GlobalAttrPattern)
+	for _, attr := range l.GlobalAttr {
+		p.globalAttr[attr] = attrRule{pattern: l.GlobalAttrPattern[attr]}
+	}
+
+	for _, tag := range l.NonHTMLTags {
+		tag = tag.Clone()
+		p.list.NonHTMLTags = append(p.list.NonHTMLTags, tag)
+		if _, ok := p.nonHTMLTags[tag.Name]; !ok {
+			p.nonHTMLTags[tag.Name] = tag
+		}
+	}
+
+	if l.SVG != nil {
+		p.svgPolicy = l.SVG.Compile()
+		p.list.SVG = p.svgPolicy.list
+	}
+	if l.MathML != nil {
+		p.mathPolicy = l.MathML.Compile()
+		p.list.MathML = p.mathPolicy.list
+	}
+
+	return p
+}
+
+// attrRules returns the compiled rules of the attributes of tag.
+func attrRules(tag *Tag) map[string]attrRule {
+	attrs := make(map[string]attrRule, len(tag.Attr)+len(tag.URLAttr))
+	for _, attr := range tag.Attr {
+		attrs[attr] = attrRule{pattern: tag.AttrPattern[attr]}
+	}
+	for _, attr := range tag.URLAttr {
+		attrs[attr] = attrRule{urlAttr: true, pattern: tag.AttrPattern[attr]}
+	}
+	return attrs
+}
+
+// AllowList returns a copy of the AllowList compiled into p, which can be
+// modified and compiled again.
+func (p *Policy) AllowList() *AllowList {
+	if p == nil {
+		return nil
+	}
+
+	return p.list.Clone()
+}
+
+// FindTag finds and returns tag by its name, case insensitive. The returned
+// Tag is shared by the Policy, and must not be modified. The Tag of a custom
+// element is created for each call.
+func (p *Policy) FindTag(name []byte) *Tag {
+	if p == nil {
+		return nil
+	}
+
+	// fast path for lowercase names
+	if tag, ok := p.tags[string(name)]; ok {
+		return tag
+	}
+
+	if len(name) > p.maxTagLen && len(p.customElements) == 0 {
+		return nil
+	}
+
+	var buf [nameBufSize]byte
+	if tag, ok := p.tags[string(toLower(buf[:0], name))]; ok {
+		return tag
+	}
+
+	// the lowercase name escapes to the patterns, so it's not on the stack
+	if len(p.customElements) == 0 {
+		return nil
+	}
+	return findCustomElement(p.customElements, p.customTags, bytes.ToLower(name))
+}
+
+func (p *Policy) checkNonHTMLTag(name []byte) *Tag {
+	if p == nil {
+		return nil
+	}
+
+	var buf [nameBufSize]byte
+	return p.nonHTMLTags[string(toLower(buf[:0], name))]
+}
+
+func (p *Policy) attrAllowed(tag *Tag, name []byte) (ok, urlAttr bool, pattern *regexp.Regexp) {
+	if p == nil {
+		return
+	}
+
+	if tag.rule != nil {
+		tag = tag.rule
+	}
+
+	rule, ok := p.attrs[tag][string(name)]
+	if !ok {
+		rule, ok = p.globalAttr[string(name)]
+	}
+	return ok, rule.urlAttr, rule.pattern
+}
+
+func (p *Policy) svg() lookup {
+	if p == nil || p.svgPolicy == nil {
+		return nil
+	}
+	return p.svgPolicy
+}
+
+func (p *Policy) math() lookup {
+	if p == nil || p.mathPolicy == nil {
+		return nil
+	}
+	return p.mathPolicy
+}
+
+// toLower appends the ASCII lowercase of p to dst.
+func toLower(dst, p []byte) []byte {
+	for _, b := range p {
+		dst = append(dst, lowerTable[b])
+	}
+	return dst
+}
+
+// Policy returns the Policy currently used by f, or nil if the AllowList is
+// used.
+func (f *HTMLSanitizer) Policy() *Policy {
+	p, _ := f.policy.Load().(*Policy)
+	return p
+}
+
+// SetPolicy atomically replaces the Policy used by f, which is used instead
+// of the AllowList. The AllowList of f is replaced with a copy of the one
+// compiled into p as well, so the two never disagree. A nil Policy makes f
+// use the AllowList again, which is left as is.
+//
+// Once f uses a Policy, it's safe to call SetPolicy while f is being used by
+// other goroutines, as they no longer read the AllowList. The first Policy
+// must be set before f is shared. The Writers created before keep using the
+// Policy when they were created.
+func (f *HTMLSanitizer) SetPolicy(p *Policy) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.setPolicy(p)
+}
+
+// setPolicy replaces the Policy and the AllowList used by f, with f.mu held.
+func (f *HTMLSanitizer) setPolicy(p *Policy) {
+	if p != nil {
+		f.AllowList = p.AllowList()
+	}
+	f.policy.Store(p)
+}
+
+// UpdatePolicy updates the Policy used by f with copy-on-write. The update
+// func is called with a copy of the current AllowList, either from the
+// current Policy or the AllowList of f, and the modified copy is compiled
+// and swapped in as a whole.
+//
+// Once f uses a Policy, the AllowList of f must only be modified by
+// UpdatePolicy. Unlike modifying it directly, it's safe to call UpdatePolicy
+// while f is being used by other goroutines, except for the first Policy, see
+// SetPolicy. The updates are serialized, so none of them is lost.
+func (f *HTMLSanitizer) UpdatePolicy(update func(l *AllowList)) *Policy {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var l *AllowList
+	if p := f.Policy(); p != nil {
+		l = p.AllowList()
+	} else {
+		l = f.AllowList.Compile().AllowList()
+	}
+	if l == nil {
+		l = new(AllowList)
+	}
+
+	update(l)
+	p := l.Compile()
+	f.setPolicy(p)
+	return p
+}
+
+// lookup returns the compiled Policy if any, otherwise the AllowList.
+func (f *HTMLSanitizer) lookup() lookup {
+	if p := f.Policy(); p != nil {
+		return p
+	}
+	return f.AllowList
+}
+
+// nonHTMLTags returns the NonHTMLTags currently used by f.
+func (f *HTMLSanitizer) nonHTMLTags() []*Tag {
+	if p := f.Policy(); p != nil {
+		if p.list == nil {
+			return nil
+		}
+		return p.list.NonHTMLTags
+	}
+	if f.AllowList == nil {
+		return nil
+	}
+	return f.NonHTMLTags
+}
@@ -0,0 +1,274 @@
+package htmlsanitizer_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleAllowList_Compile() {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.RemoveTag("a")
+
+	// freeze the AllowList for the hot path
+	sanitizer.SetPolicy(sanitizer.Compile())
+
+	// the later changes to the AllowList do not take effect until it's
+	// compiled again
+	sanitizer.RemoveTag("b")
+
+	output, _ := sanitizer.SanitizeString(`<B>bold</B> <a href="/">link</a>`)
+	fmt.Print(output)
+	// Output:
+	// <b>bold</b> link
+}
+
+var policyTestData = `<p ClaSs="p" onclick="x">hello <B>world</B><img src="/x.png" alt=x><a href="javascript:alert(1)" rel=x>link</a>
+<Script>alert(1)</sCript><style>p{}</style><table><tr><td COLSPAN=2>1</td></tr></table><iframe src=x></iframe></p>`
+
+func TestPolicy(t *testing.T) {
+	list := htmlsanitizer.DefaultAllowList.Clone()
+	list.Tags = append(list.Tags, &htmlsanitizer.Tag{Name: "style"})
+	list.Tags = append(list.Tags, &htmlsanitizer.Tag{Name: "p", Attr: []string{"onclick"}})
+
+	sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: list}
+	expected, err := sanitizer.SanitizeString(policyTestData)
+	if err != nil {
+		t.Errorf("unable to SanitizeString err: %s", err)
+	}
+
+	sanitizer.SetPolicy(list.Compile())
+	ret, err := sanitizer.SanitizeString(policyTestData)
+	if err != nil {
+		t.Errorf("unable to SanitizeString err: %s", err)
+	}
+	if ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+
+	// the Policy is used instead of the AllowList
+	sanitizer.AllowList = nil
+	ret, _ = sanitizer.SanitizeString(policyTestData)
+	if ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+}
+
+func TestPolicyImmutable(t *testing.T) {
+	list := &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "a", Attr: []string{"rel"}},
+			{Name: "abbr"},
+		},
+		GlobalAttr:  []string{"id"},
+		NonHTMLTags: []*htmlsanitizer.Tag{{Name: "script"}},
+	}
+	policy := list.Compile()
+
+	list.FindTag([]byte("a")).Attr = nil
+	list.GlobalAttr = nil
+	list.NonHTMLTags = nil
+
+	sanitizer := new(htmlsanitizer.HTMLSanitizer)
+	sanitizer.SetPolicy(policy)
+	data := `<a rel="x" id="y">link</a><script>x</script>`
+	expected := `<a rel="x" id="y">link</a>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+
+	// a copy of the AllowList is returned
+	copied := policy.AllowList()
+	copied.RemoveTag("a")
+	if policy.FindTag([]byte("A")) == nil {
+		t.Errorf("expect the Policy not changed")
+	}
+	if copied.FindTag([]byte("a")) != nil || copied.FindTag([]byte("abbr")) == nil {
+		t.Errorf("unexpected copy of the AllowList")
+	}
+
+	if (*htmlsanitizer.AllowList)(nil).Compile().FindTag([]byte("a")) != nil {
+		t.Errorf("expect no tag allowed by a nil AllowList")
+	}
+}
+
+func ExampleHTMLSanitizer_UpdatePolicy() {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.SetPolicy(sanitizer.Compile())
+
+	// safe to call while the sanitizer is being used by other goroutines
+	sanitizer.UpdatePolicy(func(l *htmlsanitizer.AllowList) {
+		l.RemoveTag("a")
+	})
+	sanitizer.UpdatePolicy(func(l *htmlsanitizer.AllowList) {
+		l.GlobalAttr = nil
+	})
+
+	output, _ := sanitizer.SanitizeString(`<p class="x"><a href="/">link</a></p>`)
+	fmt.Print(output)
+	// Output:
+	// <p>link</p>
+}
+
+func TestSetPolicyAllowList(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.SetPolicy((&htmlsanitizer.AllowList{Tags: []*htmlsanitizer.Tag{{Name: "b"}}}).Compile())
+	if sanitizer.FindTag([]byte("b")) == nil || sanitizer.FindTag([]byte("a")) != nil {
+		t.Errorf("expect the AllowList replaced by the Policy")
+	}
+
+	sanitizer.UpdatePolicy(func(l *htmlsanitizer.AllowList) {
+		l.RemoveTag("b")
+	})
+	if sanitizer.FindTag([]byte("b")) != nil {
+		t.Errorf("expect the AllowList updated with the Policy")
+	}
+
+	// the AllowList is modified, but not used until the Policy is unset
+	sanitizer.AllowList.Tags = append(sanitizer.AllowList.Tags, &htmlsanitizer.Tag{Name: "i"})
+	data := `<b>x</b><i>y</i>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != "xy" {
+		t.Errorf("expect the Policy used, got %#v", ret)
+	}
+	sanitizer.SetPolicy(nil)
+	if ret, _ := sanitizer.SanitizeString(data); ret != "x<i>y</i>" {
+		t.Errorf("expect the AllowList used, got %#v", ret)
+	}
+}
+
+func TestUpdatePolicyConcurrent(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	// set before sharing, as it replaces the AllowList
+	sanitizer.SetPolicy(sanitizer.Compile())
+	data := `<b>x</b><i>y</i>`
+	valid := map[string]bool{
+		`<b>x</b><i>y</i>`: true,
+		`x<i>y</i>`:        true,
+		`<b>x</b>y`:        true,
+		`xy`:               true,
+	}
+
+	// a Writer created before the updates keeps its snapshot
+	snapshot := new(bytes.Buffer)
+	w := sanitizer.NewWriter(snapshot)
+	var mu sync.Mutex
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				ret, err := sanitizer.SanitizeString(data)
+				if err != nil || !valid[ret] {
+					t.Errorf("unexpected output %#v, err: %v", ret, err)
+					return
+				}
+			}
+		}()
+	}
+
+	var updates sync.WaitGroup
+	for _, name := range []string{"b", "i"} {
+		name := name
+		updates.Add(1)
+		go func() {
+			defer updates.Done()
+			for i := 0; i < 100; i++ {
+				sanitizer.UpdatePolicy(func(l *htmlsanitizer.AllowList) {
+					if l.FindTag([]byte(name)) != nil {
+						l.RemoveTag(name)
+					} else {
+						l.Tags = append(l.Tags, &htmlsanitizer.Tag{Name: name})
+					}
+				})
+
+				mu.Lock()
+				_, _ = w.Write([]byte(data))
+				mu.Unlock()
+			}
+		}()
+	}
+	updates.Wait()
+	close(stop)
+	wg.Wait()
+
+	// no update is lost
+	if ret, _ := sanitizer.SanitizeString(data); ret != data {
+		t.Errorf("expect %#v, got %#v", data, ret)
+	}
+	if expected := strings.Repeat(data, 200); snapshot.String() != expected {
+		t.Errorf("expect the Writer to keep its snapshot, got %#v", snapshot.String())
+	}
+}
+
+func TestPolicyAllocs(t *testing.T) {
+	data := []byte(strings.Repeat(policyTestData, 16))
+	w := htmlsanitizer.NewWriter(ioutil.Discard)
+	if _, err := w.Write(data); err != nil {
+		t.Errorf("unable to Write err: %s", err)
+	}
+
+	// tags without any URL attributes
+	data = []byte(strings.Repeat(`<P class="p" onclick="x">hello <B>world</B><iframe></iframe></p><script>x</script>`, 16))
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = w.Write(data)
+	})
+	if allocs != 0 {
+		t.Errorf("expect no allocation, got %v", allocs)
+	}
+}
+
+func BenchmarkFindTag(b *testing.B) {
+	list := htmlsanitizer.DefaultAllowList.Clone()
+	policy := list.Compile()
+	names := [][]byte{[]byte("p"), []byte("SUMMARY"), []byte("iframe")}
+
+	b.Run("AllowList", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			list.FindTag(names[i%len(names)])
+		}
+	})
+	b.Run("Policy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			policy.FindTag(names[i%len(names)])
+		}
+	})
+}
+
+func BenchmarkSanitize(b *testing.B) {
+	data := []byte(strings.Repeat(policyTestData, 64))
+
+	run := func(b *testing.B, sanitizer *htmlsanitizer.HTMLSanitizer) {
+		w := sanitizer.NewWriter(ioutil.Discard)
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = w.Write(data)
+		}
+	}
+
+	b.Run("AllowList", func(b *testing.B) {
+		run(b, htmlsanitizer.NewHTMLSanitizer())
+	})
+	b.Run("Policy", func(b *testing.B) {
+		sanitizer := htmlsanitizer.NewHTMLSanitizer()
+		sanitizer.SetPolicy(sanitizer.Compile())
+		run(b, sanitizer)
+	})
+}
@@ -0,0 +1,61 @@
+package htmlsanitizer
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// Position of the input HTML content.
+type Position struct {
+	// Offset is the 0-based byte offset.
+	Offset int64
+
+	// Line is the 1-based line number.
+	Line int
+
+	// Column is the 1-based column number, counted in UTF-8 runes.
+	Column int
+}
+
+// advance moves the position forward by data. A multi-byte rune may be
+// split across successive calls.
+func (p *Position) advance(data []byte) {
+	p.Offset += int64(len(data))
+
+	if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+		p.Line += bytes.Count(data[:i+1], []byte{'\n'})
+		p.Column = 1
+		data = data[i+1:]
+	}
+
+	for _, b := range data {
+		if utf8.RuneStart(b) {
+			p.Column++
+		}
+	}
+}
+
+// cursor tracks the Position of the data being written. Since the state
+// machine never goes back, the cursor only moves forward.
+type cursor struct {
+	pos Position // position of data[off]
+	off int
+}
+
+func newCursor() cursor {
+	return cursor{pos: Position{Line: 1, Column: 1}}
+}
+
+// at returns the Position of data[off], off must not be less than the
+// last one within the same data.
+func (c *cursor) at(data []byte, off int) Position {
+	c.pos.advance(data[c.off:off])
+	c.off = off
+	return c.pos
+}
+
+// next moves the cursor to the beginning of the next data.
+func (c *cursor) next(data []byte) {
+	c.at(data, len(data))
+	c.off = 0
+}
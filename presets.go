@@ -0,0 +1,234 @@
+package htmlsanitizer
+
+import "regexp"
+
+// defaultNonHTMLTags are the NonHTMLTags of the presets.
+var defaultNonHTMLTags = []*Tag{
+	{Name: "script"},
+	{Name: "style"},
+	{Name: "object"},
+}
+
+// strictAllowList allows no tags at all.
+var strictAllowList = &AllowList{
+	NonHTMLTags: defaultNonHTMLTags,
+}
+
+// basicFormattingAllowList allows the basic inline formatting and links.
+var basicFormattingAllowList = &AllowList{
+	Tags: []*Tag{
+		{Name: "a", URLAttr: []string{"href"}},
+		{Name: "b"},
+		{Name: "br"},
+		{Name: "em"},
+		{Name: "i"},
+		{Name: "p"},
+		{Name: "strong"},
+	},
+	NonHTMLTags: defaultNonHTMLTags,
+}
+
+// ugcRel is the rel of all the links in the user-generated content.
+const ugcRel = "nofollow ugc noopener noreferrer"
+
+// ugcAllowList allows the formatting used in comments, without any global
+// attributes, so the content can not clobber the ids or the styles of the
+// page.
+var ugcAllowList = &AllowList{
+	Tags: []*Tag{
+		{
+			Name:    "a",
+			Attr:    []string{"rel"},
+			URLAttr: []string{"href"},
+			AttrPattern: map[string]*regexp.Regexp{
+				"href": regexp.MustCompile(`^https?://`),
+				"rel":  regexp.MustCompile(`^` + ugcRel + `$`),
+			},
+		},
+		{Name: "b"},
+		{Name: "blockquote"},
+		{Name: "br"},
+		{Name: "code"},
+		{Name: "del"},
+		{Name: "em"},
+		{Name: "i"},
+		{Name: "kbd"},
+		{Name: "li"},
+		{Name: "ol"},
+		{Name: "p"},
+		{Name: "pre"},
+		{Name: "s"},
+		{Name: "strong"},
+		{Name: "sub"},
+		{Name: "sup"},
+		{Name: "u"},
+		{Name: "ul"},
+	},
+	NonHTMLTags: defaultNonHTMLTags,
+}
+
+// ugcLinkHook replaces the rel of the links with ugcRel.
+var ugcLinkHook = HookFunc(func(dst []Token, t Token) []Token {
+	if t.Type != StartTagToken || t.Data != "a" {
+		return append(dst, t)
+	}
+
+	attrs := make([]Attribute, 0, len(t.Attr)+1)
+	for _, attr := range t.Attr {
+		if attr.Name != "rel" {
+			attrs = append(attrs, attr)
+		}
+	}
+	t.Attr = append(attrs, Attribute{Name: "rel", Value: ugcRel})
+	return append(dst, t)
+})
+
+// articleAllowList allows what the DefaultAllowList allows, with more
+// attributes of the media and the tables. It's derived from the
+// DefaultAllowList before any change to it.
+var articleAllowList = DefaultAllowList.Merge(&AllowList{
+	Tags: []*Tag{
+		{Name: "img", Attr: []string{"decoding", "title"}},
+		{Name: "source", Attr: []string{"media", "sizes"}},
+		{Name: "video", Attr: []string{"playsinline"}},
+		{Name: "td", Attr: []string{"headers"}},
+		{Name: "th", Attr: []string{"abbr", "headers"}},
+		{Name: "colgroup", Attr: []string{"span"}},
+	},
+	GlobalAttr: []string{"dir", "lang", "title"},
+})
+
+// patterns of the markdown output.
+var (
+	markdownHighlightClass = regexp.MustCompile(`^(?:hljs|(?:hljs|language|lang)-[A-Za-z0-9_+#-]+)(?: (?:hljs|(?:hljs|language|lang)-[A-Za-z0-9_+#-]+)){0,3}$`)
+	markdownFootnoteClass  = regexp.MustCompile(`^(?:footnotes|footnote-[a-z]+)$`)
+	markdownFootnoteID     = regexp.MustCompile(`^(?:user-content-)?fn(?:ref)?[-:]?[A-Za-z0-9_-]+$`)
+	markdownAlign          = regexp.MustCompile(`^(?:left|center|right)$`)
+)
+
+// markdownFootnote returns the tag of the footnotes, with its id and class
+// restricted.
+func markdownFootnote(name string) *Tag {
+	return &Tag{
+		Name:        name,
+		Attr:        []string{"class", "id"},
+		AttrPattern: map[string]*regexp.Regexp{"class": markdownFootnoteClass, "id": markdownFootnoteID},
+	}
+}
+
+// markdownAllowList allows the HTML rendered from markdown, with the task
+// lists, the footnotes and the code highlighting.
+var markdownAllowList = &AllowList{
+	Tags: []*Tag{
+		{Name: "h1"},
+		{Name: "h2"},
+		{Name: "h3"},
+		{Name: "h4"},
+		{Name: "h5"},
+		{Name: "h6"},
+		{Name: "p"},
+		{Name: "br"},
+		markdownFootnote("hr"),
+		{Name: "blockquote"},
+		{Name: "ul"},
+		{Name: "ol", Attr: []string{"start"}, AttrPattern: map[string]*regexp.Regexp{"start": regexp.MustCompile(`^[0-9]{1,9}$`)}},
+		markdownFootnote("li"),
+		markdownFootnote("section"),
+		markdownFootnote("sup"),
+		{
+			// the ids are of the footnotes only, so are the fragment links
+			Name:    "a",
+			Attr:    []string{"title", "class", "id"},
+			URLAttr: []string{"href"},
+			AttrPattern: map[string]*regexp.Regexp{
+				"class": markdownFootnoteClass,
+				"id":    markdownFootnoteID,
+			},
+		},
+		{Name: "em"},
+		{Name: "strong"},
+		{Name: "del"},
+		{Name: "s"},
+		{Name: "sub"},
+		{Name: "img", Attr: []string{"alt", "title"}, URLAttr: []string{"src"}},
+		{Name: "pre", Attr: []string{"class"}, AttrPattern: map[string]*regexp.Regexp{"class": markdownHighlightClass}},
+		{Name: "code", Attr: []string{"class"}, AttrPattern: map[string]*regexp.Regexp{"class": markdownHighlightClass}},
+		{Name: "span", Attr: []string{"class"}, AttrPattern: map[string]*regexp.Regexp{"class": markdownHighlightClass}},
+		{Name: "table"},
+		{Name: "thead"},
+		{Name: "tbody"},
+		{Name: "tr"},
+		{Name: "th", Attr: []string{"align"}, AttrPattern: map[string]*regexp.Regexp{"align": markdownAlign}},
+		{Name: "td", Attr: []string{"align"}, AttrPattern: map[string]*regexp.Regexp{"align": markdownAlign}},
+		{
+			// disabled checkboxes of the task lists only
+			Name:    "input",
+			Attr:    []string{"type", "checked", "disabled"},
+			Require: []string{"type", "disabled"},
+			AttrPattern: map[string]*regexp.Regexp{
+				"type":     regexp.MustCompile(`^(?i:checkbox)$`),
+				"checked":  regexp.MustCompile(`^(?i:checked)?$`),
+				"disabled": regexp.MustCompile(`^(?i:disabled)?$`),
+			},
+		},
+	},
+	NonHTMLTags: defaultNonHTMLTags,
+}
+
+// StrictPolicy returns a new HTMLSanitizer which allows no tags at all, so
+// only the text is kept. The content of the NonHTMLTags, such as <script>,
+// is removed as a whole.
+func StrictPolicy() *HTMLSanitizer {
+	return &HTMLSanitizer{
+		AllowList: strictAllowList.Clone(),
+	}
+}
+
+// BasicFormattingPolicy returns a new HTMLSanitizer which allows only the
+// b, i, em, strong, br, p and a tags, without any attributes except the href
+// of the links.
+func BasicFormattingPolicy() *HTMLSanitizer {
+	return &HTMLSanitizer{
+		AllowList: basicFormattingAllowList.Clone(),
+	}
+}
+
+// UGCPolicy returns a new HTMLSanitizer for the user-generated content, such
+// as comments. It allows the basic formatting, lists, code and quotes,
+// without any global attributes.
+//
+// The links are hardened: only the absolute http and https links are
+// allowed, and the rel of all the links is replaced with "nofollow ugc
+// noopener noreferrer" by the Hook, so the results are not cached by a
+// Cache.
+func UGCPolicy() *HTMLSanitizer {
+	return &HTMLSanitizer{
+		AllowList: ugcAllowList.Clone(),
+		Hook:      ugcLinkHook,
+	}
+}
+
+// ArticlePolicy returns a new HTMLSanitizer for the rich articles written by
+// trusted authors. It allows what the DefaultAllowList allows, including the
+// media and the tables, with more attributes of them, and the dir, lang and
+// title attributes globally.
+func ArticlePolicy() *HTMLSanitizer {
+	return &HTMLSanitizer{
+		AllowList: articleAllowList.Clone(),
+	}
+}
+
+// MarkdownPolicy returns a new HTMLSanitizer for the HTML rendered from
+// markdown, such as the comments or the README files.
+//
+// Besides the common markdown output, it allows the input only as a
+// disabled checkbox of the task lists, which is required to have both the
+// type and the disabled attributes, the ids and the classes of the
+// footnotes, so the fragment links can only point to the footnotes, and the
+// classes of the code highlighting with the hljs-, language- and lang-
+// prefixes.
+func MarkdownPolicy() *HTMLSanitizer {
+	return &HTMLSanitizer{
+		AllowList: markdownAllowList.Clone(),
+	}
+}
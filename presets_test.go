@@ -0,0 +1,170 @@
+package htmlsanitizer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleUGCPolicy() {
+	sanitizer := htmlsanitizer.UGCPolicy()
+
+	data := `<p id="x">see <a href="https://example.com/" rel="author" target="_top">this</a> and <a href="/logout">that</a></p>`
+	output, _ := sanitizer.SanitizeString(data)
+	fmt.Print(output)
+	// Output:
+	// <p>see <a href="https://example.com/" rel="nofollow ugc noopener noreferrer">this</a> and <a rel="nofollow ugc noopener noreferrer">that</a></p>
+}
+
+func TestPresets(t *testing.T) {
+	data := `<h1 class="x" lang="en">T</h1><p><b>b</b><em>e</em><br><a href="http://x/" rel="x">a</a></p>` +
+		`<ul><li><code>c</code></li></ul><blockquote>q</blockquote>` +
+		`<table><tr><th abbr="n" style="x">1</th></tr></table><img src="/x.png" decoding="async"><script>alert(1)</script>`
+
+	testCases := []struct {
+		name      string
+		sanitizer *htmlsanitizer.HTMLSanitizer
+		expected  string
+	}{
+		{
+			name:      "StrictPolicy",
+			sanitizer: htmlsanitizer.StrictPolicy(),
+			expected:  `Tbea` + `cq` + `1`,
+		},
+		{
+			name:      "BasicFormattingPolicy",
+			sanitizer: htmlsanitizer.BasicFormattingPolicy(),
+			expected:  `T<p><b>b</b><em>e</em><br><a href="http://x/">a</a></p>` + `cq` + `1`,
+		},
+		{
+			name:      "UGCPolicy",
+			sanitizer: htmlsanitizer.UGCPolicy(),
+			expected: `T<p><b>b</b><em>e</em><br><a href="http://x/" rel="nofollow ugc noopener noreferrer">a</a></p>` +
+				`<ul><li><code>c</code></li></ul><blockquote>q</blockquote>` + `1`,
+		},
+		{
+			name:      "ArticlePolicy",
+			sanitizer: htmlsanitizer.ArticlePolicy(),
+			expected: `<h1 class="x" lang="en">T</h1><p><b>b</b><em>e</em><br><a href="http://x/" rel="x">a</a></p>` +
+				`<ul><li><code>c</code></li></ul><blockquote>q</blockquote>` +
+				`<table><tr><th abbr="n">1</th></tr></table><img src="/x.png" decoding="async">`,
+		},
+	}
+
+	for _, item := range testCases {
+		if err := item.sanitizer.AllowList.Validate(); err != nil {
+			t.Errorf("%s: expect a valid AllowList, got %s", item.name, err)
+		}
+
+		ret, err := item.sanitizer.SanitizeString(data)
+		if err != nil {
+			t.Errorf("%s: unable to SanitizeString err: %s", item.name, err)
+		}
+		if ret != item.expected {
+			t.Errorf("%s: expect %#v, got %#v", item.name, item.expected, ret)
+		}
+	}
+}
+
+func TestPresetsIndependent(t *testing.T) {
+	a := htmlsanitizer.BasicFormattingPolicy()
+	a.RemoveTag("b")
+	a.FindTag([]byte("a")).RemoveAttr("href")
+
+	b := htmlsanitizer.BasicFormattingPolicy()
+	data := `<b>x</b><a href="/">y</a>`
+	if ret, _ := b.SanitizeString(data); ret != data {
+		t.Errorf("expect %#v, got %#v", data, ret)
+	}
+
+	c := htmlsanitizer.ArticlePolicy()
+	c.GlobalAttr = append(c.GlobalAttr, "style")
+	if ret, _ := htmlsanitizer.ArticlePolicy().SanitizeString(`<p style="x">y</p>`); ret != `<p>y</p>` {
+		t.Errorf("unexpected ArticlePolicy output %#v", ret)
+	}
+}
+
+func TestUGCPolicyLinks(t *testing.T) {
+	sanitizer := htmlsanitizer.UGCPolicy()
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{`<a href="javascript:alert(1)">x</a>`, `<a rel="nofollow ugc noopener noreferrer">x</a>`},
+		{`<a href="//example.com/">x</a>`, `<a rel="nofollow ugc noopener noreferrer">x</a>`},
+		{`<a href="HTTPS://example.com/">x</a>`, `<a href="https://example.com/" rel="nofollow ugc noopener noreferrer">x</a>`},
+		{`<a href="https://example.com/?q=a&amp;amp;b&amp;lt=1">x</a>`, `<a href="https://example.com/?q=a&amp;b&lt=1" rel="nofollow ugc noopener noreferrer">x</a>`},
+		{`<a rel="nofollow ugc noopener noreferrer" rel=me>x</a>`, `<a rel="nofollow ugc noopener noreferrer">x</a>`},
+		{`<b onclick="x" class="y">x</b>`, `<b>x</b>`},
+	}
+
+	for _, item := range testCases {
+		if ret, _ := sanitizer.SanitizeString(item.in); ret != item.expected {
+			t.Errorf("expect %#v, got %#v", item.expected, ret)
+		}
+	}
+}
+
+func ExampleMarkdownPolicy() {
+	sanitizer := htmlsanitizer.MarkdownPolicy()
+
+	data := `<ul><li><input type="checkbox" checked disabled> done</li><li><input type="checkbox" disabled> todo</li></ul>` +
+		`<pre><code class="language-go"><span class="hljs-keyword">func</span></code></pre>` +
+		`<p>x<sup class="footnote-ref"><a href="#fn1" id="fnref1">1</a></sup></p>`
+	output, _ := sanitizer.SanitizeString(data)
+	fmt.Println(output)
+	// Output:
+	// <ul><li><input type="checkbox" checked disabled> done</li><li><input type="checkbox" disabled> todo</li></ul><pre><code class="language-go"><span class="hljs-keyword">func</span></code></pre><p>x<sup class="footnote-ref"><a href="#fn1" id="fnref1">1</a></sup></p>
+}
+
+func TestMarkdownPolicy(t *testing.T) {
+	sanitizer := htmlsanitizer.MarkdownPolicy()
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		// task lists
+		{`<input type="checkbox" disabled="">`, `<input type="checkbox" disabled="">`},
+		{`<input type="CHECKBOX" checked="checked" disabled>`, `<input type="CHECKBOX" checked="checked" disabled>`},
+		{`<input>`, ``},
+		{`<input type="text" disabled>`, ``},
+		{`<input type="checkbox">`, ``},
+		{`<input type="checkbox" disabled="false">`, ``},
+		{`<input type="checkbox" value="x" name="y" onclick="z" disabled>`, `<input type="checkbox" disabled>`},
+		{`<input type="checkbox" type="text" disabled>`, `<input type="checkbox" disabled>`},
+		{`<input type="text" type="checkbox" disabled>`, `<input type="checkbox" disabled>`},
+
+		// footnotes
+		{`<section class="footnotes"><ol><li id="fn1"><a href="#fnref1" class="footnote-backref">↩</a></li></ol></section>`,
+			`<section class="footnotes"><ol><li id="fn1"><a href="#fnref1" class="footnote-backref">↩</a></li></ol></section>`},
+		{`<li id="user-content-fn-1"><a href="#user-content-fnref-1">x</a></li>`, `<li id="user-content-fn-1"><a href="#user-content-fnref-1">x</a></li>`},
+		{`<a id="login" class="btn" href="https://example.com/">x</a>`, `<a href="https://example.com/">x</a>`},
+		{`<p id="fn1" class="footnotes">x</p>`, `<p>x</p>`},
+
+		// code highlighting
+		{`<code class="hljs language-c++">x</code>`, `<code class="hljs language-c++">x</code>`},
+		{`<span class="hljs-title function_">x</span>`, `<span>x</span>`},
+		{`<span class="hljs-string hljs-meta">x</span><span class="btn">y</span>`, `<span class="hljs-string hljs-meta">x</span><span>y</span>`},
+		{`<div class="hljs">x</div>`, `x`},
+
+		// the others
+		{`<table><tr><th align="center">x</th><td align="justify" style="x">y</td></tr></table>`, `<table><tr><th align="center">x</th><td>y</td></tr></table>`},
+		{`<ol start="3" type="a"><li>x</li></ol>`, `<ol start="3"><li>x</li></ol>`},
+		{`<img src="javascript:alert(1)" alt="x" onerror="y">`, `<img alt="x">`},
+	}
+
+	for _, item := range testCases {
+		ret, err := sanitizer.SanitizeString(item.in)
+		if err != nil {
+			t.Errorf("unable to SanitizeString err: %s", err)
+		}
+		if ret != item.expected {
+			t.Errorf("expect %#v, got %#v", item.expected, ret)
+		}
+	}
+
+	if err := sanitizer.AllowList.Validate(); err != nil {
+		t.Errorf("expect a valid AllowList, got %s", err)
+	}
+}
@@ -0,0 +1,97 @@
+package htmlsanitizer
+
+import (
+	"bytes"
+	"io"
+)
+
+// readBufferSize is the size of the chunks read from the underlying reader.
+const readBufferSize = 4096
+
+// reader is the sanitizing Reader. It reads the HTML content from r only
+// when its sanitized output is drained, so at most one chunk of the input
+// and its sanitized output are buffered.
+type reader struct {
+	w *writer
+	r io.Reader
+
+	// buf for read
+	buf []byte
+
+	// sanitized output not read yet
+	out bytes.Buffer
+
+	// sticky error from r or w
+	err error
+}
+
+// NewReader returns a new Reader reading sanitized HTML content from r.
+// The content is sanitized lazily as the Reader is read.
+//
+// Like the Writer, a tag not completed at the end of r is dropped. The
+// returned Reader also implements io.WriterTo, which writes the sanitized
+// content to the destination directly.
+func (f *HTMLSanitizer) NewReader(r io.Reader) io.Reader {
+	ret := &reader{r: r}
+	ret.w = f.newWriter(&ret.out)
+	return ret
+}
+
+// fill reads the next chunk from r, and sanitizes it.
+func (r *reader) fill() {
+	if r.buf == nil {
+		r.buf = make([]byte, readBufferSize)
+	}
+
+	n, err := r.r.Read(r.buf)
+	if n > 0 {
+		if _, writeErr := r.w.Write(r.buf[:n]); writeErr != nil {
+			r.err = writeErr
+			return
+		}
+	}
+
+	if err == io.EOF {
+		if closeErr := r.w.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		r.err = err
+	}
+}
+
+func (r *reader) Read(p []byte) (n int, err error) {
+	for r.out.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.fill()
+	}
+
+	return r.out.Read(p)
+}
+
+// WriteTo writes the sanitized content to w until there's no more data to
+// read or an error occurs.
+func (r *reader) WriteTo(w io.Writer) (n int64, err error) {
+	if r.out.Len() > 0 {
+		if n, err = r.out.WriteTo(w); err != nil {
+			return
+		}
+	}
+
+	// write the sanitized content to w directly, without the out buffer
+	written := r.w.written
+	r.w.w = w
+	for r.err == nil {
+		r.fill()
+	}
+	r.w.w = &r.out
+	n += r.w.written - written
+
+	if r.err != io.EOF {
+		err = r.err
+	}
+	return
+}
@@ -0,0 +1,133 @@
+package htmlsanitizer_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleNewReader() {
+	// source reader for demo, e.g. the http.Response.Body
+	r := strings.NewReader(`<a href="javascript:alert(1)" class=link>link</a><img src=x onerror=alert(1)`)
+
+	_, _ = io.Copy(os.Stdout, htmlsanitizer.NewReader(r))
+	// Output:
+	// <a class="link">link</a>
+}
+
+// pullReader hides the io.WriterTo of the underlying reader.
+type pullReader struct {
+	io.Reader
+}
+
+func TestReader(t *testing.T) {
+	testCases := []string{
+		"",
+		"text only",
+		`<a href="http://example.com/" onclick="x">link</a><script>alert(1)</script>`,
+		strings.Repeat(`<p class="x">a&lt;b</p><!-- c --><style>p{}</style>`, 1024),
+		`trailing partial tag <a href="http://example.com/`,
+		`trailing partial end tag </p`,
+		`trailing lt sign <`,
+	}
+
+	readers := map[string]func(r io.Reader) io.Reader{
+		"full":     func(r io.Reader) io.Reader { return r },
+		"byte":     iotest.OneByteReader,
+		"half":     iotest.HalfReader,
+		"data-err": iotest.DataErrReader,
+	}
+
+	for _, data := range testCases {
+		expected, err := htmlsanitizer.SanitizeString(data)
+		if err != nil {
+			t.Errorf("unable to SanitizeString(%#v) err: %s", data, err)
+			continue
+		}
+
+		for name, wrap := range readers {
+			ret, err := ioutil.ReadAll(pullReader{htmlsanitizer.NewReader(wrap(strings.NewReader(data)))})
+			if err != nil {
+				t.Errorf("%s: unable to read %#v, err: %s", name, data, err)
+			}
+			if string(ret) != expected {
+				t.Errorf("%s: test failed for %#v, expect %#v, got %#v", name, data, expected, string(ret))
+			}
+
+			// io.WriterTo after a small read
+			r := htmlsanitizer.NewReader(wrap(strings.NewReader(data)))
+			head := make([]byte, 3)
+			m, _ := io.ReadFull(r, head)
+			buf := bytes.NewBuffer(head[:m])
+			n, err := r.(io.WriterTo).WriteTo(buf)
+			if err != nil {
+				t.Errorf("%s: unable to WriteTo for %#v, err: %s", name, data, err)
+			}
+			if buf.String() != expected || int(n)+m != len(expected) {
+				t.Errorf("%s: WriteTo failed for %#v, expect %#v, got %#v (%d bytes)", name, data, expected, buf.String(), n)
+			}
+		}
+	}
+}
+
+// countReader counts the bytes read from an endless content.
+type countReader struct {
+	data  []byte
+	count int
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		n += copy(p[n:], r.data[(r.count+n)%len(r.data):])
+	}
+	r.count += n
+	return n, nil
+}
+
+func TestReaderBuffering(t *testing.T) {
+	src := &countReader{data: []byte(`<p class="t">text</p><script>x</script>`)}
+	r := htmlsanitizer.NewReader(src)
+
+	buf := make([]byte, 64)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Errorf("unable to read, err: %s", err)
+	}
+	if !strings.HasPrefix(string(buf), `<p class="t">text</p><p class="t">`) {
+		t.Errorf("unexpected output %#v", string(buf))
+	}
+	if src.count > 8192 {
+		t.Errorf("expect the input to be read lazily, %d bytes read", src.count)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.Limits.MaxDepth = 2
+
+	data := `<div><p>x</p><p><b>y</b></p></div>`
+	ret, err := ioutil.ReadAll(sanitizer.NewReader(iotest.OneByteReader(strings.NewReader(data))))
+	var limitErr *htmlsanitizer.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Kind != htmlsanitizer.LimitDepth {
+		t.Errorf("expect a LimitError, got %v", err)
+	}
+	if string(ret) != `<div><p>x</p><p>` {
+		t.Errorf("unexpected output before the error %#v", string(ret))
+	}
+
+	r := htmlsanitizer.NewReader(iotest.TimeoutReader(strings.NewReader("<p>text</p>")))
+	n, err := r.(io.WriterTo).WriteTo(ioutil.Discard)
+	if err != iotest.ErrTimeout || n != 11 {
+		t.Errorf("expect ErrTimeout after 11 bytes, got %d, %v", n, err)
+	}
+	if _, err := r.Read(make([]byte, 1)); err != iotest.ErrTimeout {
+		t.Errorf("expect the error to be sticky, got %v", err)
+	}
+}
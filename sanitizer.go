@@ -1,9 +1,10 @@
 package htmlsanitizer
 
 import (
-	"bytes"
 	"io"
 	"net/url"
+	"sync"
+	"sync/atomic"
 )
 
 // DefaultURLSanitizer is a default and strict sanitizer.
@@ -34,6 +35,12 @@ func DefaultURLSanitizer(rawURL string) (sanitzed string, ok bool) {
 
 // HTMLSanitizer is a super fast HTML sanitizer for arbitrary HTML content.
 // This is a allowlist-based santizer, of which the time complexity is O(n).
+//
+// An HTMLSanitizer must not be copied after first use, as it holds the
+// current Policy, a lock and a pool of Writers, which go vet reports. Once a
+// Policy is set, the AllowList is only a copy of the one compiled into it,
+// and modifying the AllowList directly has no effect, use UpdatePolicy
+// instead.
 type HTMLSanitizer struct {
 	*AllowList
 
@@ -43,6 +50,48 @@ type HTMLSanitizer struct {
 	// the current attribute will be ignored.
 	// If the func is nil, then DefaultURLSanitizer will be used.
 	URLSanitizer func(rawURL string) (sanitzed string, ok bool)
+
+	// URLSanitizerName names the URLSanitizer for the Fingerprint, which
+	// should be changed whenever the URLSanitizer behaves differently,
+	// e.g. "example.com-only/v2".
+	URLSanitizerName string
+
+	// StyleSanitizer, if not nil, is a func used to sanitize the values of
+	// all the allowed style attributes, e.g. to keep only some of the CSS
+	// declarations. The value is unescaped first, and the sanitized one is
+	// matched against the pattern of the attribute, if any. If not
+	// acceptable, the current attribute will be ignored.
+	StyleSanitizer func(style string) (sanitized string, ok bool)
+
+	// StyleSanitizerName names the StyleSanitizer for the Fingerprint, the
+	// same as the URLSanitizerName.
+	StyleSanitizerName string
+
+	// Limits specifies the resource limits for each Writer. Once a limit is
+	// exceeded, the Writer fails with a *LimitError.
+	Limits Limits
+
+	// OnViolation, if not nil, is called for each piece of HTML content
+	// removed by the sanitizer, e.g. for auditing purposes.
+	OnViolation func(v Violation)
+
+	// Hook, if not nil, transforms the allowed tokens before they are
+	// written, e.g. to rename a tag or to add a wrapper element.
+	Hook Hook
+
+	// Document, if not nil, makes the sanitized content a full HTML
+	// document, with the doctype, and a single html, head and body element.
+	// See DocumentPolicy.
+	Document *Document
+
+	// compiled *Policy used instead of the AllowList
+	policy atomic.Value
+
+	// mu serializes the updates of policy
+	mu sync.Mutex
+
+	// pool of *writer
+	pool sync.Pool
 }
 
 // NewHTMLSanitizer creates a new HTMLSanitizer with the clone of
@@ -62,22 +111,62 @@ func (f *HTMLSanitizer) urlSanitizer(rawURL string) (sanitzed string, ok bool) {
 }
 
 // NewWriter returns a new Writer writing sanitized HTML content to w.
+//
+// The line and column numbers are only tracked if any of the Limits,
+// OnViolation or Hook is set when the Writer is created, otherwise only the
+// byte offsets are reported.
+//
+// The returned Writer also implements Reset(w io.Writer), which makes it
+// write the sanitized content of another HTML content to w, reusing its
+// allocated buffers, and io.Closer. In the document mode, the Writer must
+// be closed to write the end of the document.
 func (f *HTMLSanitizer) NewWriter(w io.Writer) io.Writer {
-	return &writer{
-		HTMLSanitizer: f,
-		w:             w,
-	}
+	return f.newWriter(w)
+}
+
+func (f *HTMLSanitizer) newWriter(w io.Writer) *writer {
+	ret := &writer{HTMLSanitizer: f}
+	ret.Reset(w)
+	return ret
 }
 
 // Sanitize the HTML data and return the sanitzed HTML.
 func (f *HTMLSanitizer) Sanitize(data []byte) ([]byte, error) {
-	buf := new(bytes.Buffer)
+	w := f.getWriter()
+	defer f.putWriter(w)
 
-	if _, err := f.NewWriter(buf).Write(data); err != nil {
+	w.out = make([]byte, 0, len(data))
+	w.Reset(&w.out)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
 		return nil, err
 	}
 
-	return buf.Bytes(), nil
+	return w.out, nil
+}
+
+// AppendSanitized appends the sanitized HTML data of src to dst, and returns
+// the extended buffer. The memory of dst is reused if it has enough
+// capacity, and dst must not overlap src.
+//
+// If any of the Limits is exceeded, AppendSanitized fails closed and returns
+// dst unchanged. Use Sanitize to get the error.
+func (f *HTMLSanitizer) AppendSanitized(dst, src []byte) []byte {
+	w := f.getWriter()
+	defer f.putWriter(w)
+
+	w.out = dst
+	w.Reset(&w.out)
+	if _, err := w.Write(src); err != nil {
+		return dst
+	}
+	if err := w.Close(); err != nil {
+		return dst
+	}
+
+	return w.out
 }
 
 // SanitizeString sanitizes the HTML string and return the sanitzed HTML.
@@ -91,7 +180,11 @@ func (f *HTMLSanitizer) SanitizeString(data string) (string, error) {
 	return retStr, err
 }
 
-var defaultHTMLSanitizer = NewHTMLSanitizer()
+var defaultHTMLSanitizer = func() *HTMLSanitizer {
+	f := NewHTMLSanitizer()
+	f.SetPolicy(f.Compile())
+	return f
+}()
 
 // NewWriter returns a new Writer, with DefaultAllowList,
 // writing sanitized HTML content to w.
@@ -99,6 +192,18 @@ func NewWriter(w io.Writer) io.Writer {
 	return defaultHTMLSanitizer.NewWriter(w)
 }
 
+// NewReader returns a new Reader, with DefaultAllowList,
+// reading sanitized HTML content from r.
+func NewReader(r io.Reader) io.Reader {
+	return defaultHTMLSanitizer.NewReader(r)
+}
+
+// AppendSanitized uses the DefaultAllowList to sanitize the HTML data of
+// src, and appends it to dst.
+func AppendSanitized(dst, src []byte) []byte {
+	return defaultHTMLSanitizer.AppendSanitized(dst, src)
+}
+
 // Sanitize uses the DefaultAllowList to sanitize the HTML data.
 func Sanitize(data []byte) ([]byte, error) {
 	return defaultHTMLSanitizer.Sanitize(data)
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/url"
 	"strings"
 	"testing"
@@ -118,7 +119,7 @@ func ExampleHTMLSanitizer_noTagsAllowed() {
 func ExampleHTMLSanitizer_onlyAllowHrefTag() {
 	sanitizer := htmlsanitizer.NewHTMLSanitizer()
 	sanitizer.AllowList.Tags = []*htmlsanitizer.Tag{
-		{"a", nil, []string{"href"}},
+		{Name: "a", URLAttr: []string{"href"}},
 	}
 
 	data := `
@@ -199,6 +200,166 @@ func TestSanitizeString(t *testing.T) {
 	}
 }
 
+func TestSanitizeNamespacedNames(t *testing.T) {
+	list := htmlsanitizer.DefaultAllowList.Clone()
+	list.Tags = append(list.Tags, &htmlsanitizer.Tag{Name: "epub:switch"}, &htmlsanitizer.Tag{Name: "x.y_z"})
+	list.GlobalAttr = append(list.GlobalAttr, "xml:lang", "epub:type", "v_on", "data-a.b")
+	list.NamespacePrefixes = []string{"epub"}
+	if err := list.Validate(); err != nil {
+		t.Fatalf("expect a valid AllowList, got %s", err)
+	}
+
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{`<p xml:lang="en" epub:type="note" v_on="x" data-a.b=1>a</p>`, `<p xml:lang="en" epub:type="note" v_on="x" data-a.b="1">a</p>`},
+		{`<EPUB:SWITCH>a</epub:switch><x.y_z>b</X.Y_Z>`, `<epub:switch>a</epub:switch><x.y_z>b</x.y_z>`},
+		{`<p class=a:b.c_d>x</p>`, `<p class="a:b.c_d">x</p>`},
+
+		// the names are never split to smuggle the attributes
+		{`<p xml:lang="en"onclick="alert(1)">x</p>`, `<p xml:lang="en">x</p>`},
+		{`<p epub:type:onclick="alert(1)" xml:lang.onclick=x>x</p>`, `<p>x</p>`},
+		{`<p on:click=x _onclick=x .onclick=x :onclick=x onclick.x=x>x</p>`, `<p>x</p>`},
+		{`<p v_on:click="alert(1)" class_="x" class.id="x">x</p>`, `<p>x</p>`},
+		{`<a xlink:href="javascript:alert(1)" href.x="javascript:alert(1)" href:x="x">x</a>`, `<a>x</a>`},
+		{`<img src.x="javascript:alert(1)" src="https://example.com/a.png">`, `<img src="https://example.com/a.png">`},
+		{`<p x@class="foo" x$class=y x"class=z>x</p>`, `<p>x</p>`},
+		{`<a x$href="javascript:alert(1)" x@href=y>x</a>`, `<a>x</a>`},
+		{"<p x\xa0class=\"foo\" x\x00class=y \"class=z =class=w>x</p>", `<p>x</p>`},
+		{`<p/class="foo" =class=x>x</p>`, `<p class="foo">x</p>`},
+
+		// the tags are matched by the whole names
+		{`<p.x>a</p.x><p:x>b</p:x><epub:p>c</epub:p><b_>d</b_>`, `abcd`},
+		{`<script.x><b onclick="alert(1)">a</b></script.x>`, `<b>a</b>`},
+		{`<script>a</script.x><b>b</b></script><i>c</i>`, `<i>c</i>`},
+	}
+
+	for _, compiled := range []bool{false, true} {
+		sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: list}
+		if compiled {
+			sanitizer.SetPolicy(sanitizer.Compile())
+		}
+
+		for _, item := range testCases {
+			ret, err := sanitizer.SanitizeString(item.in)
+			if err != nil {
+				t.Errorf("unable to SanitizeString err: %s", err)
+			}
+			if ret != item.expected {
+				t.Errorf("compiled %v: expect %#v, got %#v", compiled, item.expected, ret)
+			}
+		}
+	}
+}
+
+func ExampleAppendSanitized() {
+	comments := []string{
+		`<b onclick="alert(1)">first</b>`,
+		`<a href="javascript:alert(1)">second</a>`,
+	}
+
+	// reuse the buffer for each comment
+	var buf []byte
+	for _, comment := range comments {
+		buf = htmlsanitizer.AppendSanitized(buf[:0], []byte(comment))
+		fmt.Println(string(buf))
+	}
+	// Output:
+	// <b>first</b>
+	// <a>second</a>
+}
+
+func TestAppendSanitized(t *testing.T) {
+	prefix := []byte("prefix:")
+	for _, item := range testCases {
+		ret := htmlsanitizer.AppendSanitized(prefix, []byte(item.in))
+		if string(ret) != "prefix:"+item.out {
+			t.Errorf("test failed for %#v, expect %#v, got %#v", item.in, item.out, string(ret))
+			break
+		}
+	}
+
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.Limits.MaxDepth = 1
+	if ret := sanitizer.AppendSanitized(prefix, []byte(`<p><b>x</b></p>`)); string(ret) != "prefix:" {
+		t.Errorf("expect dst unchanged if a limit is exceeded, got %#v", string(ret))
+	}
+	if ret := sanitizer.AppendSanitized(nil, []byte(`<p>x</p>`)); string(ret) != "<p>x</p>" {
+		t.Errorf("expect a pooled writer to be reset, got %#v", string(ret))
+	}
+}
+
+func TestWriterReset(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.Limits.MaxDepth = 1
+
+	o := new(bytes.Buffer)
+	w := sanitizer.NewWriter(o)
+	_, err := w.Write([]byte(`<p><b>x</b></p><a href="/`))
+	if err == nil {
+		t.Errorf("expect a LimitError")
+	}
+
+	// both the error and the partial tag are discarded
+	o2 := new(bytes.Buffer)
+	w.(interface{ Reset(w io.Writer) }).Reset(o2)
+	if _, err := w.Write([]byte(`">link</a><i>y</i>`)); err != nil {
+		t.Errorf("unable to Write after Reset err: %s", err)
+	}
+	if o2.String() != `"&gt;link</a><i>y</i>` {
+		t.Errorf("unexpected output after Reset %#v", o2.String())
+	}
+}
+
+var benchmarkComment = []byte(`<p class="comment">Thanks for <b>sharing</b>, see <a href="https://example.com/">this</a><script>alert(1)</script></p>`)
+
+func BenchmarkSmallComments(b *testing.B) {
+	b.Run("NewWriter", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf := new(bytes.Buffer)
+			_, _ = htmlsanitizer.NewWriter(buf).Write(benchmarkComment)
+		}
+	})
+	b.Run("Sanitize", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = htmlsanitizer.Sanitize(benchmarkComment)
+		}
+	})
+	b.Run("Reset", func(b *testing.B) {
+		w := htmlsanitizer.NewWriter(ioutil.Discard).(interface {
+			io.Writer
+			Reset(w io.Writer)
+		})
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w.Reset(ioutil.Discard)
+			_, _ = w.Write(benchmarkComment)
+		}
+	})
+	b.Run("AppendSanitized", func(b *testing.B) {
+		var buf []byte
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf = htmlsanitizer.AppendSanitized(buf[:0], benchmarkComment)
+		}
+	})
+}
+
+func BenchmarkLargeDocument(b *testing.B) {
+	paragraph := strings.Repeat("Lorem ipsum dolor sit amet, consectetur adipiscing elit. ", 32)
+	data := []byte(strings.Repeat(`<p class="text" title="a long attribute value, which is quoted">`+paragraph+"</p>\n", 1024))
+
+	w := htmlsanitizer.NewWriter(ioutil.Discard)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = w.Write(data)
+	}
+}
+
 var testCases = []struct {
 	in  string
 	out string
@@ -271,6 +432,14 @@ var testCases = []struct {
 		in:  `<span class  =   a>`,
 		out: "<span class=\"a\">",
 	},
+	{
+		in:  `<a href>test</a>`,
+		out: "<a href>test</a>",
+	},
+	{
+		in:  `<video src controls>test</video><img src />`,
+		out: "<video src controls>test</video><img src />",
+	},
 	{
 		in:  `<//>`,
 		out: "",
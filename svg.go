@@ -0,0 +1,154 @@
+package htmlsanitizer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// svgTagNames are the SVG tag names in the SVG case.
+var svgTagNames = caseNames(
+	"altGlyph", "altGlyphDef", "altGlyphItem", "animateColor", "animateMotion",
+	"animateTransform", "clipPath", "feBlend", "feColorMatrix",
+	"feComponentTransfer", "feComposite", "feConvolveMatrix",
+	"feDiffuseLighting", "feDisplacementMap", "feDistantLight", "feDropShadow",
+	"feFlood", "feFuncA", "feFuncB", "feFuncG", "feFuncR", "feGaussianBlur",
+	"feImage", "feMerge", "feMergeNode", "feMorphology", "feOffset",
+	"fePointLight", "feSpecularLighting", "feSpotLight", "feTile",
+	"feTurbulence", "foreignObject", "glyphRef", "linearGradient",
+	"radialGradient", "textPath",
+)
+
+// svgAttrNames are the SVG attribute names in the SVG case.
+var svgAttrNames = caseNames(
+	"attributeName", "attributeType", "baseFrequency", "baseProfile",
+	"calcMode", "clipPathUnits", "diffuseConstant", "edgeMode", "filterUnits",
+	"glyphRef", "gradientTransform", "gradientUnits", "kernelMatrix",
+	"kernelUnitLength", "keyPoints", "keySplines", "keyTimes",
+	"lengthAdjust", "limitingConeAngle", "markerHeight", "markerUnits",
+	"markerWidth", "maskContentUnits", "maskUnits", "numOctaves",
+	"pathLength", "patternContentUnits", "patternTransform", "patternUnits",
+	"pointsAtX", "pointsAtY", "pointsAtZ", "preserveAlpha",
+	"preserveAspectRatio", "primitiveUnits", "refX", "refY", "repeatCount",
+	"repeatDur", "requiredExtensions", "requiredFeatures",
+	"specularConstant", "specularExponent", "spreadMethod", "startOffset",
+	"stdDeviation", "stitchTiles", "surfaceScale", "systemLanguage",
+	"tableValues", "targetX", "targetY", "textLength", "viewBox",
+	"viewTarget", "xChannelSelector", "yChannelSelector", "zoomAndPan",
+)
+
+// svgHrefAttr checks whether the lowercase value of an attributeName refers
+// to an href attribute, of which the animations are always removed.
+func svgHrefAttr(attributeName string) bool {
+	name := strings.ToLower(strings.TrimSpace(attributeName))
+	return name == "href" || strings.HasSuffix(name, ":href")
+}
+
+// patterns of the DefaultSVGAllowList.
+var (
+	svgFragment = regexp.MustCompile(`^#[A-Za-z0-9_.:-]+$`)
+	svgPaint    = regexp.MustCompile(`^(?:none|currentColor|transparent|#[0-9a-fA-F]{3,8}|[a-zA-Z]+|rgba?\([0-9.,% ]+\)|url\(#[A-Za-z0-9_.:-]+\)(?: [^()]+)?)$`)
+	svgFuncIRI  = regexp.MustCompile(`^(?:none|url\(#[A-Za-z0-9_.:-]+\))$`)
+	svgAnimated = regexp.MustCompile(`^(?:cx|cy|d|fill-opacity|height|offset|opacity|points|r|rx|ry|stop-color|stop-opacity|stroke-dasharray|stroke-dashoffset|stroke-opacity|stroke-width|transform|visibility|width|x|x1|x2|y|y1|y2)$`)
+)
+
+// svgShape returns the tag of an SVG element, with the attributes.
+func svgShape(name string, attrs ...string) *Tag {
+	return &Tag{Name: name, Attr: attrs}
+}
+
+// svgLink returns the tag of an SVG element referring to another one in the
+// same document by its href.
+func svgLink(name string, attrs ...string) *Tag {
+	return &Tag{
+		Name:        name,
+		Attr:        attrs,
+		URLAttr:     []string{"href", "xlink:href"},
+		AttrPattern: map[string]*regexp.Regexp{"href": svgFragment, "xlink:href": svgFragment},
+	}
+}
+
+// svgAnimation returns the tag of an SVG animation element, which can only
+// animate the presentation attributes.
+func svgAnimation(name string, attrs ...string) *Tag {
+	return &Tag{
+		Name: name,
+		Attr: append([]string{
+			"attributename", "begin", "dur", "end", "fill", "from", "to", "by",
+			"values", "keytimes", "keysplines", "calcmode", "repeatcount", "repeatdur",
+		}, attrs...),
+		AttrPattern: map[string]*regexp.Regexp{"attributename": svgAnimated},
+	}
+}
+
+// DefaultSVGAllowList for the inline SVG, such as the icons and diagrams,
+// which can be used as the AllowList.SVG. The references by url() and href
+// are allowed only within the same document, except the href of the <a>
+// and <image> elements, which is sanitized by the URLSanitizer. The filters
+// and the external resources such as fonts are not allowed.
+//
+// It is not recommended to modify the default list directly, use .Clone()
+// and then modify the new one instead.
+var DefaultSVGAllowList = &AllowList{
+	Tags: []*Tag{
+		{
+			Name: "svg",
+			Attr: []string{"viewbox", "width", "height", "x", "y", "preserveaspectratio", "version", "xmlns", "xmlns:xlink"},
+			AttrPattern: map[string]*regexp.Regexp{
+				"xmlns":       regexp.MustCompile(`^http://www\.w3\.org/2000/svg$`),
+				"xmlns:xlink": regexp.MustCompile(`^http://www\.w3\.org/1999/xlink$`),
+			},
+		},
+		svgShape("g"),
+		svgShape("defs"),
+		svgShape("symbol", "viewbox", "preserveaspectratio", "x", "y", "width", "height", "refx", "refy"),
+		svgLink("use", "x", "y", "width", "height"),
+		svgShape("title"),
+		svgShape("desc"),
+		svgShape("switch"),
+		svgShape("path", "d", "pathlength"),
+		svgShape("rect", "x", "y", "width", "height", "rx", "ry", "pathlength"),
+		svgShape("circle", "cx", "cy", "r", "pathlength"),
+		svgShape("ellipse", "cx", "cy", "rx", "ry", "pathlength"),
+		svgShape("line", "x1", "y1", "x2", "y2", "pathlength"),
+		svgShape("polyline", "points", "pathlength"),
+		svgShape("polygon", "points", "pathlength"),
+		svgShape("text", "x", "y", "dx", "dy", "rotate", "textlength", "lengthadjust"),
+		svgShape("tspan", "x", "y", "dx", "dy", "rotate", "textlength", "lengthadjust"),
+		svgLink("textpath", "startoffset", "method", "spacing", "side", "textlength", "lengthadjust"),
+		svgLink("lineargradient", "x1", "y1", "x2", "y2", "gradientunits", "gradienttransform", "spreadmethod"),
+		svgLink("radialgradient", "cx", "cy", "r", "fx", "fy", "fr", "gradientunits", "gradienttransform", "spreadmethod"),
+		svgShape("stop", "offset", "stop-color", "stop-opacity"),
+		svgShape("clippath", "clippathunits"),
+		svgShape("mask", "x", "y", "width", "height", "maskunits", "maskcontentunits"),
+		svgLink("pattern", "x", "y", "width", "height", "viewbox", "preserveaspectratio", "patternunits", "patterncontentunits", "patterntransform"),
+		svgShape("marker", "viewbox", "preserveaspectratio", "refx", "refy", "markerunits", "markerwidth", "markerheight", "orient"),
+		{Name: "a", Attr: []string{"target"}, URLAttr: []string{"href", "xlink:href"}},
+		{Name: "image", Attr: []string{"x", "y", "width", "height", "preserveaspectratio"}, URLAttr: []string{"href", "xlink:href"}},
+		svgAnimation("animate"),
+		svgAnimation("animatetransform", "type"),
+		// no set, which can set any value without the from and to
+		// no foreignObject
+		// no script, style
+		// no filters
+	},
+	GlobalAttr: []string{
+		"id", "class", "transform", "opacity", "display", "visibility", "color",
+		"fill", "fill-opacity", "fill-rule",
+		"stroke", "stroke-width", "stroke-opacity", "stroke-linecap", "stroke-linejoin",
+		"stroke-dasharray", "stroke-dashoffset", "stroke-miterlimit",
+		"clip-path", "clip-rule", "mask", "marker-start", "marker-mid", "marker-end",
+		"font-family", "font-size", "font-style", "font-weight",
+		"text-anchor", "dominant-baseline", "vector-effect", "xml:space",
+		"role", "aria-label", "aria-hidden",
+	},
+	GlobalAttrPattern: map[string]*regexp.Regexp{
+		"fill":         svgPaint,
+		"stroke":       svgPaint,
+		"clip-path":    svgFuncIRI,
+		"mask":         svgFuncIRI,
+		"marker-start": svgFuncIRI,
+		"marker-mid":   svgFuncIRI,
+		"marker-end":   svgFuncIRI,
+		"font-family":  regexp.MustCompile(`^[a-zA-Z0-9 ,'"-]{1,256}$`),
+	},
+}
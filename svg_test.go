@@ -0,0 +1,201 @@
+package htmlsanitizer_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func svgAllowList() *htmlsanitizer.AllowList {
+	list := htmlsanitizer.DefaultAllowList.Clone()
+	list.SVG = htmlsanitizer.DefaultSVGAllowList.Clone()
+	return list
+}
+
+func ExampleAllowList_svg() {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.AllowList.SVG = htmlsanitizer.DefaultSVGAllowList
+
+	data := `<svg viewbox="0 0 10 10" onload="alert(1)"><linearGradient id="g"><stop offset="0" stop-color="red"/></linearGradient>` +
+		`<rect width="10" height="10" fill="url(#g)"/><foreignObject><p>x</p></foreignObject></svg>`
+	output, _ := sanitizer.SanitizeString(data)
+	fmt.Println(output)
+	// Output:
+	// <svg viewBox="0 0 10 10"><linearGradient id="g"><stop offset="0" stop-color="red" /></linearGradient><rect width="10" height="10" fill="url(#g)" />x</svg>
+}
+
+func TestSVG(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		// the names are written in the SVG case
+		{`<SVG VIEWBOX="0 0 1 1" preserveAspectRatio="none"><clippath id="c" CLIPPATHUNITS="userSpaceOnUse"></CLIPPATH></svg>`,
+			`<svg viewBox="0 0 1 1" preserveAspectRatio="none"><clipPath id="c" clipPathUnits="userSpaceOnUse"></clipPath></svg>`},
+		{`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" xml:space="preserve"></svg>`,
+			`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" xml:space="preserve"></svg>`},
+		{`<svg xmlns="http://evil.example.com/"></svg>`, `<svg></svg>`},
+
+		// the references are sanitized
+		{`<svg><use href="#icon"/><use xlink:href="#icon"/></svg>`, `<svg><use href="#icon" /><use xlink:href="#icon" /></svg>`},
+		{`<svg><use href="https://example.com/sprite.svg#icon"/><use xlink:href="javascript:alert(1)"/></svg>`, `<svg><use /><use /></svg>`},
+		{`<svg><a href="javascript:alert(1)">x</a><a xlink:href="https://example.com/">y</a></svg>`,
+			`<svg><a>x</a><a xlink:href="https://example.com/">y</a></svg>`},
+		{`<svg><image href="https://example.com/a.png"/><image xlink:href="data:image/svg+xml,x"/></svg>`,
+			`<svg><image href="https://example.com/a.png" /><image /></svg>`},
+		{`<svg><rect fill="url(https://evil.example.com/#x)" mask="url(#m)"/></svg>`, `<svg><rect mask="url(#m)" /></svg>`},
+
+		// always removed
+		{`<svg onload="alert(1)"><rect onclick="alert(1)" onmouseover="x"/></svg>`, `<svg><rect /></svg>`},
+		{`<svg><foreignObject><iframe srcdoc="x"></iframe>text</foreignObject></svg>`, `<svg>text</svg>`},
+		{`<svg><a><animate attributeName="href" values="javascript:alert(1)"/></a></svg>`, `<svg><a><animate values="javascript:alert(1)" /></a></svg>`},
+		{`<svg><animate attributeName=" xlink:HREF " to="javascript:alert(1)"/></svg>`, `<svg><animate to="javascript:alert(1)" /></svg>`},
+		{`<svg><animate attributeName="opacity" from="0" to="1" dur="1s"/></svg>`, `<svg><animate attributeName="opacity" from="0" to="1" dur="1s" /></svg>`},
+		{`<svg><set attributeName="href" to="javascript:alert(1)"/></svg>`, `<svg></svg>`},
+
+		// the content of the NonHTMLTags is removed, which is markup inside svg
+		{`<svg><style><img src=x onerror=alert(1)></style></svg>`, `<svg></svg>`},
+		{`<svg><script>alert(1)</script><title><style>x</style></title></svg>`, `<svg><title></title></svg>`},
+
+		// the HTML elements are not allowed inside svg
+		{`<svg><p>x</p><img src="https://example.com/a.png"><b>y</b></svg>`, `<svg>xy</svg>`},
+		{`<svg><linearGradient>x</linearGradient></svg><linearGradient>y</linearGradient><p>z</p>`,
+			`<svg><linearGradient>x</linearGradient></svg>y<p>z</p>`},
+
+		// nested svg
+		{`<svg><svg viewBox="0 0 1 1"><path d="M0 0"/></svg><rect/></svg><p>x</p>`,
+			`<svg><svg viewBox="0 0 1 1"><path d="M0 0" /></svg><rect /></svg><p>x</p>`},
+		{`<svg/><p>x</p>`, `<svg /><p>x</p>`},
+		{`</svg><p>x</p>`, `</svg><p>x</p>`},
+	}
+
+	for _, compiled := range []bool{false, true} {
+		sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: svgAllowList()}
+		if compiled {
+			sanitizer.SetPolicy(sanitizer.Compile())
+		}
+
+		for _, item := range testCases {
+			ret, err := sanitizer.SanitizeString(item.in)
+			if err != nil {
+				t.Errorf("unable to SanitizeString err: %s", err)
+			}
+			if ret != item.expected {
+				t.Errorf("compiled %v: expect %#v, got %#v", compiled, item.expected, ret)
+			}
+		}
+	}
+
+	// the svg tag of the AllowList is not used, nor svg allowed without SVG
+	list := htmlsanitizer.DefaultAllowList.Clone()
+	list.Tags = append(list.Tags, &htmlsanitizer.Tag{Name: "svg", Attr: []string{"onload"}}, &htmlsanitizer.Tag{Name: "rect"})
+	sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: list}
+	if ret, _ := sanitizer.SanitizeString(`<svg onload="x"><rect></rect></svg>`); ret != `<svg onload="x"><rect></rect></svg>` {
+		t.Errorf("expect the svg tag of the AllowList without SVG, got %#v", ret)
+	}
+	list.SVG = htmlsanitizer.DefaultSVGAllowList
+	if ret, _ := sanitizer.SanitizeString(`<svg onload="x"><rect></rect></svg>`); ret != `<svg><rect></rect></svg>` {
+		t.Errorf("expect the svg tag of the SVG, got %#v", ret)
+	}
+}
+
+func TestSVGHook(t *testing.T) {
+	sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: svgAllowList()}
+	var hooked []string
+	sanitizer.Hook = htmlsanitizer.HookFunc(func(dst []htmlsanitizer.Token, t htmlsanitizer.Token) []htmlsanitizer.Token {
+		if t.Type != htmlsanitizer.StartTagToken {
+			return append(dst, t)
+		}
+		hooked = append(hooked, t.Data)
+		switch t.Data {
+		case "rect":
+			// the output of the Hook is checked in the SVG namespace
+			t.Attr = append(t.Attr, htmlsanitizer.Attribute{Name: "onclick", Value: "x"}, htmlsanitizer.Attribute{Name: "viewbox", Value: "0 0 1 1"})
+			t.Data = "foreignobject"
+		case "circle":
+			t.Attr = append(t.Attr, htmlsanitizer.Attribute{Name: "attributename", Value: "href"})
+			t.Data = "animate"
+		}
+		return append(dst, t)
+	})
+
+	data := `<svg viewBox="0 0 1 1"><rect/><circle/><symbol viewBox="0 0 1 1"/></svg><symbol>`
+	expected := `<svg viewBox="0 0 1 1"><animate /><symbol viewBox="0 0 1 1" /></svg>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+	expectedHooked := []string{"svg", "rect", "circle", "symbol"}
+	if !reflect.DeepEqual(hooked, expectedHooked) {
+		t.Errorf("expect the Hook called with %v, got %v", expectedHooked, hooked)
+	}
+}
+
+func TestSVGJSON(t *testing.T) {
+	list := svgAllowList()
+	data, err := json.Marshal(list)
+	if err != nil {
+		t.Fatalf("unable to marshal err: %s", err)
+	}
+	if !strings.Contains(string(data), `"svg":{"tags":[{"name":"svg"`) {
+		t.Errorf("expect the SVG in the JSON, got %s", data)
+	}
+
+	decoded := new(htmlsanitizer.AllowList)
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("unable to unmarshal err: %s", err)
+	}
+	if decoded.Fingerprint() != list.Fingerprint() {
+		t.Errorf("expect the same AllowList after unmarshal, got %+v", decoded)
+	}
+	if list.Fingerprint() == htmlsanitizer.DefaultAllowList.Fingerprint() {
+		t.Errorf("expect the SVG to change the fingerprint")
+	}
+	if err := htmlsanitizer.DefaultSVGAllowList.Validate(); err != nil {
+		t.Errorf("expect a valid DefaultSVGAllowList, got %s", err)
+	}
+}
+
+func TestSVGInvalid(t *testing.T) {
+	testCases := []struct {
+		in    string
+		field string
+	}{
+		{`{"version": 1, "svg": {"tags": [{"name": "RECT"}]}}`, "svg.tags[0].name"},
+		{`{"version": 1, "svg": {"tags": [{"name": "rect"}, {"name": "foreignobject"}]}}`, "svg.tags[1].name"},
+		{`{"version": 1, "svg": {"tags": [{"name": "p"}]}}`, "svg.tags[0].name"},
+		{`{"version": 1, "svg": {"tags": [{"name": "use", "attr": ["x", "href"]}]}}`, "svg.tags[0].attr[1]"},
+		{`{"version": 1, "svg": {"tags": [{"name": "use", "attr": ["xlink:href"]}]}}`, "svg.tags[0].attr[0]"},
+		{`{"version": 1, "svg": {"tags": [{"name": "set", "attr": ["attributename"]}]}}`, "svg.tags[0].attrPattern.attributename"},
+		{`{"version": 1, "svg": {"tags": [{"name": "set", "attr": ["attributename"], "attrPattern": {"attributename": "^[a-z:]+$"}}]}}`,
+			"svg.tags[0].attrPattern.attributename"},
+		{`{"version": 1, "svg": {"globalAttr": ["onload"]}}`, "svg.globalAttr[0]"},
+		{`{"version": 1, "svg": {"globalAttr": ["attributename"]}}`, "svg.globalAttrPattern.attributename"},
+		{`{"version": 1, "svg": {"tags": [{"name": "a", "attr": ["a:b:c"]}]}}`, "svg.tags[0].attr[0]"},
+	}
+
+	for _, item := range testCases {
+		var list htmlsanitizer.AllowList
+		err := json.Unmarshal([]byte(item.in), &list)
+
+		var policyErr *htmlsanitizer.PolicyError
+		if !errors.As(err, &policyErr) || policyErr.Field != item.field {
+			t.Errorf("expect a PolicyError at %s for %s, got %v", item.field, item.in, err)
+		}
+	}
+
+	for field, svg := range map[string]*htmlsanitizer.AllowList{
+		"svg.nonHTMLTags": {NonHTMLTags: []*htmlsanitizer.Tag{{Name: "style"}}},
+		"svg.svg":         {SVG: &htmlsanitizer.AllowList{}},
+	} {
+		list := &htmlsanitizer.AllowList{SVG: svg}
+		var policyErr *htmlsanitizer.PolicyError
+		if err := list.Validate(); !errors.As(err, &policyErr) || policyErr.Field != field {
+			t.Errorf("expect a PolicyError at %s, got %v", field, err)
+		}
+	}
+}
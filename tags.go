@@ -1,23 +1,70 @@
 package htmlsanitizer
 
-import "bytes"
+import (
+	"bytes"
+	"regexp"
+)
 
 // Tag with its attributes.
 type Tag struct {
 	// Name for current tag, must be lowercase.
-	Name string
+	Name string `json:"name"`
 
 	// Attr specifies the allowed attributes for current tag,
 	// must be lowercase.
 	//
 	// e.g. colspan, rowspan
-	Attr []string
+	Attr []string `json:"attr,omitempty"`
 
 	// URLAttr specifies the allowed, URL-relatedd attributes for current tag,
 	// must be lowercase.
 	//
 	// e.g. src, href
-	URLAttr []string
+	URLAttr []string `json:"urlAttr,omitempty"`
+
+	// AttrPattern optionally restricts the values of the attributes above.
+	// An attribute is dropped if its value does not match the pattern. The
+	// value is unescaped before matched, or sanitized by the URLSanitizer
+	// for a URL-related attribute. The patterns should be anchored, such as
+	// ^[a-z]+$ .
+	AttrPattern map[string]*regexp.Regexp `json:"-"`
+
+	// Require lists at most 64 attributes the tag must have, which must be
+	// allowed for the tag, either by the tag itself or globally. A tag missing
+	// any of them after its attributes are sanitized is removed, as well as
+	// its end tag. Combined with the AttrPattern, a tag can be allowed only
+	// if an attribute has some values, e.g. <button type="button">.
+	Require []string `json:"require,omitempty"`
+
+	// compiled rule of the CustomElement which the tag is resolved from
+	rule *Tag
+}
+
+// Clone returns a deep copy of t.
+func (t *Tag) Clone() *Tag {
+	if t == nil {
+		return nil
+	}
+
+	return &Tag{
+		Name:        t.Name,
+		Attr:        append([]string(nil), t.Attr...),
+		URLAttr:     append([]string(nil), t.URLAttr...),
+		AttrPattern: clonePatterns(t.AttrPattern),
+		Require:     append([]string(nil), t.Require...),
+	}
+}
+
+func clonePatterns(patterns map[string]*regexp.Regexp) map[string]*regexp.Regexp {
+	if patterns == nil {
+		return nil
+	}
+
+	ret := make(map[string]*regexp.Regexp, len(patterns))
+	for name, pattern := range patterns {
+		ret[name] = pattern
+	}
+	return ret
 }
 
 // attrExists checks whether attr exists. Case sensitive
@@ -57,11 +104,65 @@ type AllowList struct {
 	// any URL-related attribute.
 	GlobalAttr []string
 
+	// GlobalAttrPattern optionally restricts the values of GlobalAttr, the
+	// same as Tag.AttrPattern.
+	GlobalAttrPattern map[string]*regexp.Regexp
+
 	// NonHTMLTags defines a set of special tags, such as <script> and <style>.
 	// The content of these kind of tags is actually not a real HTML content.
 	// So we should treat it as a single element, without any child elements.
 	// TODO: rename this one
 	NonHTMLTags []*Tag
+
+	// SVG, if not nil, allows the inline <svg> elements, and the elements
+	// and attributes in the SVG namespace, such as <path d>. It's used
+	// instead of the AllowList inside the <svg> elements, and the svg tag in
+	// the Tags is ignored. The names must be lowercase, and are written in
+	// the SVG case, such as viewBox. Its NonHTMLTags are not used, the
+	// content of all the NonHTMLTags inside <svg> is removed.
+	//
+	// Regardless of the SVG, the foreignObject elements, the event handler
+	// attributes and the animations of the href attributes are always
+	// removed inside <svg>.
+	SVG *AllowList
+
+	// MathML, if not nil, allows the inline <math> elements, and the
+	// elements and attributes in the MathML namespace, such as <mfrac>. It's
+	// used inside the <math> elements the same as the SVG, and the math tag
+	// in the Tags is ignored.
+	//
+	// Regardless of the MathML, the annotation-xml elements, in which the
+	// content may be parsed as HTML, and the event handler attributes are
+	// always removed inside <math>.
+	MathML *AllowList
+
+	// CustomElements allows the custom elements matched by the patterns,
+	// such as <ds-card>, which are used only if the names are not found in
+	// the Tags. The first matched one wins.
+	CustomElements []*CustomElement
+
+	// NamespacePrefixes lists the namespace prefixes allowed in the names of
+	// the tags and attributes besides xml, xlink and xmlns, such as epub for
+	// epub:type. They only affect Validate, which rejects the namespaced
+	// names of the other prefixes. The content is always sanitized by the
+	// listed names in full, so a prefix alone never allows any name.
+	NamespacePrefixes []string
+}
+
+// svg returns the lookup of the SVG namespace, or nil if not allowed.
+func (l *AllowList) svg() lookup {
+	if l == nil || l.SVG == nil {
+		return nil
+	}
+	return l.SVG
+}
+
+// math returns the lookup of the MathML namespace, or nil if not allowed.
+func (l *AllowList) math() lookup {
+	if l == nil || l.MathML == nil {
+		return nil
+	}
+	return l.MathML
 }
 
 // attrExists checks whether global attr exists. Case sensitive
@@ -100,6 +201,8 @@ func (l *AllowList) checkNonHTMLTag(p []byte) *Tag {
 // RemoveTag removes all tags name `name`, must be lowercase
 // It is not recommended to modify the default list directly, use .Clone() and
 // then modify the new one instead.
+// It's not safe to modify an AllowList being used by a sanitizer, use
+// HTMLSanitizer.UpdatePolicy instead.
 func (l *AllowList) RemoveTag(name string) {
 	if l == nil || l.Tags == nil {
 		return
@@ -119,32 +222,47 @@ func (l *AllowList) RemoveTag(name string) {
 	}
 }
 
-// FindTag finds and returns tag by its name, case insensitive.
+// FindTag finds and returns tag by its name, case insensitive. If not found
+// in the Tags, the Tag of the matched CustomElement is created for each
+// call, which shares the attributes with the CustomElement.
 func (l *AllowList) FindTag(p []byte) *Tag {
 	if l == nil {
 		return nil
 	}
 
-	name := string(bytes.ToLower(p))
+	lower := bytes.ToLower(p)
+	name := string(lower)
 	for _, tag := range l.Tags {
 		if name == tag.Name {
 			return tag
 		}
 	}
 
-	return nil
+	return findCustomElement(l.CustomElements, nil, lower)
 }
 
-// Clone a new AllowList.
+// Clone a new AllowList. It's a deep copy, so modifying the tags of the new
+// one does not affect l.
 func (l *AllowList) Clone() *AllowList {
 	if l == nil {
 		return l
 	}
 
 	newList := new(AllowList)
-	newList.Tags = append(newList.Tags, l.Tags...)
+	for _, tag := range l.Tags {
+		newList.Tags = append(newList.Tags, tag.Clone())
+	}
 	newList.GlobalAttr = append(newList.GlobalAttr, l.GlobalAttr...)
-	newList.NonHTMLTags = append(newList.NonHTMLTags, l.NonHTMLTags...)
+	newList.GlobalAttrPattern = clonePatterns(l.GlobalAttrPattern)
+	for _, tag := range l.NonHTMLTags {
+		newList.NonHTMLTags = append(newList.NonHTMLTags, tag.Clone())
+	}
+	newList.SVG = l.SVG.Clone()
+	newList.MathML = l.MathML.Clone()
+	for _, c := range l.CustomElements {
+		newList.CustomElements = append(newList.CustomElements, c.clone())
+	}
+	newList.NamespacePrefixes = append([]string(nil), l.NamespacePrefixes...)
 
 	return newList
 }
@@ -157,87 +275,86 @@ func (l *AllowList) Clone() *AllowList {
 // then modify the new one instead.
 var DefaultAllowList = &AllowList{
 	Tags: []*Tag{
-		{"address", []string{}, []string{}},
-		{"article", []string{}, []string{}},
-		{"aside", []string{}, []string{}},
-		{"footer", []string{}, []string{}},
-		{"header", []string{}, []string{}},
-		{"h1", []string{}, []string{}},
-		{"h2", []string{}, []string{}},
-		{"h3", []string{}, []string{}},
-		{"h4", []string{}, []string{}},
-		{"h5", []string{}, []string{}},
-		{"h6", []string{}, []string{}},
-		{"hgroup", []string{}, []string{}},
-		{"main", []string{}, []string{}},
-		{"nav", []string{}, []string{}},
-		{"section", []string{}, []string{}},
-		{"blockquote", []string{}, []string{"cite"}},
-		{"dd", []string{}, []string{}},
-		{"div", []string{}, []string{}},
-		{"dl", []string{}, []string{}},
-		{"dt", []string{}, []string{}},
-		{"figcaption", []string{}, []string{}},
-		{"figure", []string{}, []string{}},
-		{"hr", []string{}, []string{}},
-		{"li", []string{}, []string{}},
-		{"main", []string{}, []string{}},
-		{"ol", []string{}, []string{}},
-		{"p", []string{}, []string{}},
-		{"pre", []string{}, []string{}},
-		{"ul", []string{}, []string{}},
-		{"a", []string{"rel", "target", "referrerpolicy"}, []string{"href"}},
-		{"abbr", []string{"title"}, []string{}},
-		{"b", []string{}, []string{}},
-		{"bdi", []string{}, []string{}},
-		{"bdo", []string{}, []string{}},
-		{"br", []string{}, []string{}},
-		{"cite", []string{}, []string{}},
-		{"code", []string{}, []string{}},
-		{"data", []string{"value"}, []string{}},
-		{"em", []string{}, []string{}},
-		{"i", []string{}, []string{}},
-		{"kbd", []string{}, []string{}},
-		{"mark", []string{}, []string{}},
-		{"q", []string{}, []string{"cite"}},
-		{"s", []string{}, []string{}},
-		{"small", []string{}, []string{}},
-		{"span", []string{}, []string{}},
-		{"strong", []string{}, []string{}},
-		{"sub", []string{}, []string{}},
-		{"sup", []string{}, []string{}},
-		{"time", []string{"datetime"}, []string{}},
-		{"u", []string{}, []string{}},
-		{"area", []string{"alt", "coords", "shape", "target", "rel", "referrerpolicy"}, []string{"href"}},
-		{"audio", []string{"autoplay", "controls", "crossorigin", "duration", "loop", "muted", "preload"}, []string{"src"}},
-		{"img", []string{"alt", "crossorigin", "height", "width", "loading", "referrerpolicy"}, []string{"src"}},
-		{"map", []string{"name"}, []string{}},
-		{"track", []string{"default", "kind", "label", "srclang"}, []string{"src"}},
-		{"video", []string{"autoplay", "buffered", "controls", "crossorigin", "duration", "loop", "muted", "preload", "height", "width"}, []string{"src", "poster"}},
+		{Name: "address"},
+		{Name: "article"},
+		{Name: "aside"},
+		{Name: "footer"},
+		{Name: "header"},
+		{Name: "h1"},
+		{Name: "h2"},
+		{Name: "h3"},
+		{Name: "h4"},
+		{Name: "h5"},
+		{Name: "h6"},
+		{Name: "hgroup"},
+		{Name: "main"},
+		{Name: "nav"},
+		{Name: "section"},
+		{Name: "blockquote", URLAttr: []string{"cite"}},
+		{Name: "dd"},
+		{Name: "div"},
+		{Name: "dl"},
+		{Name: "dt"},
+		{Name: "figcaption"},
+		{Name: "figure"},
+		{Name: "hr"},
+		{Name: "li"},
+		{Name: "ol"},
+		{Name: "p"},
+		{Name: "pre"},
+		{Name: "ul"},
+		{Name: "a", Attr: []string{"rel", "target", "referrerpolicy"}, URLAttr: []string{"href"}},
+		{Name: "abbr", Attr: []string{"title"}},
+		{Name: "b"},
+		{Name: "bdi"},
+		{Name: "bdo"},
+		{Name: "br"},
+		{Name: "cite"},
+		{Name: "code"},
+		{Name: "data", Attr: []string{"value"}},
+		{Name: "em"},
+		{Name: "i"},
+		{Name: "kbd"},
+		{Name: "mark"},
+		{Name: "q", URLAttr: []string{"cite"}},
+		{Name: "s"},
+		{Name: "small"},
+		{Name: "span"},
+		{Name: "strong"},
+		{Name: "sub"},
+		{Name: "sup"},
+		{Name: "time", Attr: []string{"datetime"}},
+		{Name: "u"},
+		{Name: "area", Attr: []string{"alt", "coords", "shape", "target", "rel", "referrerpolicy"}, URLAttr: []string{"href"}},
+		{Name: "audio", Attr: []string{"autoplay", "controls", "crossorigin", "duration", "loop", "muted", "preload"}, URLAttr: []string{"src"}},
+		{Name: "img", Attr: []string{"alt", "crossorigin", "height", "width", "loading", "referrerpolicy"}, URLAttr: []string{"src"}},
+		{Name: "map", Attr: []string{"name"}},
+		{Name: "track", Attr: []string{"default", "kind", "label", "srclang"}, URLAttr: []string{"src"}},
+		{Name: "video", Attr: []string{"autoplay", "buffered", "controls", "crossorigin", "duration", "loop", "muted", "preload", "height", "width"}, URLAttr: []string{"src", "poster"}},
 		// no embed
 		// no iframe
 		// no object
 		// no param
-		{"picture", []string{}, []string{}},
-		{"source", []string{"type"}, []string{"src"}},
+		{Name: "picture"},
+		{Name: "source", Attr: []string{"type"}, URLAttr: []string{"src"}},
 		// no canvas
 		// no script
-		{"del", []string{}, []string{}},
-		{"ins", []string{}, []string{}},
-		{"caption", []string{}, []string{}},
-		{"col", []string{"span"}, []string{}},
-		{"colgroup", []string{}, []string{}},
-		{"table", []string{}, []string{}},
-		{"tbody", []string{}, []string{}},
-		{"td", []string{"colspan", "rowspan"}, []string{}},
-		{"tfoot", []string{}, []string{}},
-		{"th", []string{"colspan", "rowspan", "scope"}, []string{}},
-		{"thead", []string{}, []string{}},
-		{"tr", []string{}, []string{}},
+		{Name: "del"},
+		{Name: "ins"},
+		{Name: "caption"},
+		{Name: "col", Attr: []string{"span"}},
+		{Name: "colgroup"},
+		{Name: "table"},
+		{Name: "tbody"},
+		{Name: "td", Attr: []string{"colspan", "rowspan"}},
+		{Name: "tfoot"},
+		{Name: "th", Attr: []string{"colspan", "rowspan", "scope"}},
+		{Name: "thead"},
+		{Name: "tr"},
 		// no Forms
-		{"details", []string{"open"}, []string{}},
-		{"summary", []string{}, []string{}},
-		// no Web Components
+		{Name: "details", Attr: []string{"open"}},
+		{Name: "summary"},
+		// no Web Components, see AllowList.CustomElements
 	},
 	GlobalAttr: []string{
 		"class",
@@ -249,3 +366,21 @@ var DefaultAllowList = &AllowList{
 		{Name: "object"},
 	},
 }
+
+// voidElements are the elements which can not have any child nodes.
+var voidElements = map[string]bool{
+	"area":   true,
+	"base":   true,
+	"br":     true,
+	"col":    true,
+	"embed":  true,
+	"hr":     true,
+	"img":    true,
+	"input":  true,
+	"link":   true,
+	"meta":   true,
+	"param":  true,
+	"source": true,
+	"track":  true,
+	"wbr":    true,
+}
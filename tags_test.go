@@ -2,6 +2,9 @@ package htmlsanitizer_test
 
 import (
 	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
 
 	"github.com/sym01/htmlsanitizer"
 )
@@ -27,3 +30,108 @@ func ExampleAllowList_RemoveTag() {
 	// 	Welcome to use htmlsanitizer
 	// </p>
 }
+
+// formControls allows the button for UI widgets, and the input for task
+// lists only.
+func formControls() *htmlsanitizer.AllowList {
+	return &htmlsanitizer.AllowList{
+		Tags: []*htmlsanitizer.Tag{
+			{Name: "p"},
+			{
+				Name:        "button",
+				Attr:        []string{"type"},
+				AttrPattern: map[string]*regexp.Regexp{"type": regexp.MustCompile(`^(?i:button)$`)},
+				Require:     []string{"type"},
+			},
+			{
+				Name: "input",
+				Attr: []string{"type", "checked", "disabled"},
+				AttrPattern: map[string]*regexp.Regexp{
+					"type":     regexp.MustCompile(`^(?i:checkbox)$`),
+					"disabled": regexp.MustCompile(`^(?i:disabled)?$`),
+				},
+				Require: []string{"type", "disabled"},
+			},
+		},
+		GlobalAttr: []string{"class"},
+	}
+}
+
+func TestTagRequire(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected string
+	}{
+		{`<button type="button" class="x">ok</button>`, `<button type="button" class="x">ok</button>`},
+		{`<button type="submit">x</button>y`, `xy`},
+		{`<button>x</button>`, `x`},
+		{`<button type="button" formaction="/x">x</button>`, `<button type="button">x</button>`},
+		{`<button type="submit" formaction="/x">x</button>`, `x`},
+		{`<input type="checkbox" checked disabled>`, `<input type="checkbox" checked disabled>`},
+		{`<input type="checkbox">`, ``},
+		{`<input type="text" disabled>`, ``},
+		{`<input type="checkbox" disabled="false">`, ``},
+
+		// only the end tags of the removed start tags are removed
+		{`<button type="button"><button>x</button></button>y</button>`, `<button type="button">x</button>y</button>`},
+		{`<button><button type="button">x</button></button>`, `<button type="button">x</button>`},
+		{`<button type="reset"/>x</button>`, `x</button>`},
+	}
+
+	for _, compiled := range []bool{false, true} {
+		sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: formControls()}
+		if compiled {
+			sanitizer.SetPolicy(sanitizer.Compile())
+		}
+
+		for _, item := range testCases {
+			ret, err := sanitizer.SanitizeString(item.in)
+			if err != nil {
+				t.Errorf("unable to SanitizeString err: %s", err)
+			}
+			if ret != item.expected {
+				t.Errorf("compiled %v: expect %#v, got %#v", compiled, item.expected, ret)
+			}
+		}
+	}
+
+	if err := formControls().Validate(); err != nil {
+		t.Errorf("expect a valid AllowList, got %s", err)
+	}
+}
+
+func TestTagRequireHook(t *testing.T) {
+	sanitizer := &htmlsanitizer.HTMLSanitizer{AllowList: formControls()}
+	var hooked []string
+	sanitizer.Hook = htmlsanitizer.HookFunc(func(dst []htmlsanitizer.Token, t htmlsanitizer.Token) []htmlsanitizer.Token {
+		hooked = append(hooked, fmt.Sprintf("%v %s", t.Type, t.Data))
+		if t.Type == htmlsanitizer.StartTagToken && t.Data == "p" {
+			// the output of the Hook is checked again
+			t.Data = "button"
+		}
+		return append(dst, t)
+	})
+	var violations []string
+	sanitizer.OnViolation = func(v htmlsanitizer.Violation) {
+		violations = append(violations, v.Kind.String()+" "+v.Tag+" "+v.Attr)
+	}
+
+	data := `<button type="submit">x</button><p>y</p>`
+	expected := `xy</p>`
+	if ret, _ := sanitizer.SanitizeString(data); ret != expected {
+		t.Errorf("expect %#v, got %#v", expected, ret)
+	}
+
+	expectedHooked := []string{"Text x", "StartTag p", "Text y", "EndTag p"}
+	if !reflect.DeepEqual(hooked, expectedHooked) {
+		t.Errorf("expect the Hook called with %v, got %v", expectedHooked, hooked)
+	}
+	expectedViolations := []string{
+		"attribute not allowed button type",
+		"required attribute missing button type",
+		"required attribute missing button type",
+	}
+	if !reflect.DeepEqual(violations, expectedViolations) {
+		t.Errorf("expect the violations %v, got %v", expectedViolations, violations)
+	}
+}
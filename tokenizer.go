@@ -0,0 +1,165 @@
+package htmlsanitizer
+
+import (
+	"io"
+	"strings"
+)
+
+// Tokenizer splits the HTML content from an io.Reader into tokens, with the
+// same state machine used by the HTMLSanitizer. So the tools built on it
+// always see the same tags and attributes as the sanitizer does.
+//
+// Like the sanitizer, comments, doctypes and malformed tags are skipped, and
+// a tag not completed at the end of the content is dropped.
+type Tokenizer struct {
+	lexer
+	r io.Reader
+
+	// lowercase names of the raw text elements
+	rawTags []string
+
+	buf   []byte
+	queue []Token
+	head  int
+	err   error
+}
+
+// NewTokenizer returns a new Tokenizer reading HTML content from r. The
+// NonHTMLTags of the DefaultAllowList are treated as raw text elements.
+func NewTokenizer(r io.Reader) *Tokenizer {
+	return defaultHTMLSanitizer.NewTokenizer(r)
+}
+
+// NewTokenizer returns a new Tokenizer reading HTML content from r, which
+// uses the NonHTMLTags and Limits of f.
+func (f *HTMLSanitizer) NewTokenizer(r io.Reader) *Tokenizer {
+	z := &Tokenizer{r: r}
+	for _, tag := range f.nonHTMLTags() {
+		z.rawTags = append(z.rawTags, tag.Name)
+	}
+	z.lexer = newLexer(z, f.Limits, true)
+	return z
+}
+
+// Next returns the next token. Adjacent text is merged into a single token.
+// At the end of the content, Next returns io.EOF. It may also return the
+// error from the underlying reader, or a *LimitError.
+func (z *Tokenizer) Next() (Token, error) {
+	for !z.ready() {
+		if z.err != nil {
+			return Token{}, z.err
+		}
+		z.read()
+	}
+
+	t := z.queue[z.head]
+	z.queue[z.head] = Token{}
+	z.head++
+	if z.head == len(z.queue) {
+		z.queue = z.queue[:0]
+		z.head = 0
+	}
+	return t, nil
+}
+
+// ready reports whether the first token in the queue is completed. A text
+// token is completed only if it's followed by another token, or there is
+// nothing more to read.
+func (z *Tokenizer) ready() bool {
+	switch len(z.queue) - z.head {
+	case 0:
+		return false
+	case 1:
+		t := z.queue[z.head].Type
+		return (t != TextToken && t != RawTextToken) || z.err != nil
+	}
+
+	return true
+}
+
+func (z *Tokenizer) read() {
+	if z.buf == nil {
+		z.buf = make([]byte, readBufferSize)
+	}
+
+	n, err := z.r.Read(z.buf)
+	if n > 0 {
+		if _, feedErr := z.feed(z.buf[:n]); feedErr != nil {
+			z.err = feedErr
+			return
+		}
+	}
+
+	if err != nil {
+		z.err = err
+	}
+}
+
+// pushText pushes the text into the queue, or merges it into the last token.
+func (z *Tokenizer) pushText(typ TokenType, p []byte, pos Position) {
+	if len(p) == 0 {
+		return
+	}
+
+	if n := len(z.queue); n > z.head && z.queue[n-1].Type == typ {
+		z.queue[n-1].Data += string(p)
+		return
+	}
+
+	z.queue = append(z.queue, Token{Type: typ, Data: string(p), Position: pos})
+}
+
+func (z *Tokenizer) startTagName() bool {
+	return true
+}
+
+func (z *Tokenizer) rawTextElement(name []byte) string {
+	for _, tag := range z.rawTags {
+		if equalLower(name, tag) {
+			return tag
+		}
+	}
+	return ""
+}
+
+func (z *Tokenizer) startTag(selfClosing bool) error {
+	t := Token{
+		Type:        StartTagToken,
+		Data:        strings.ToLower(string(z.tagName)),
+		SelfClosing: selfClosing,
+		Position:    z.tagPos,
+	}
+	for _, a := range z.attrs {
+		t.Attr = append(t.Attr, Attribute{
+			Name:  string(z.attrBuf[a.start:a.nameEnd]),
+			Value: string(z.attrBuf[a.nameEnd:a.end]),
+			Bare:  a.bare,
+		})
+	}
+
+	z.queue = append(z.queue, t)
+	return nil
+}
+
+func (z *Tokenizer) endTag() error {
+	z.queue = append(z.queue, Token{
+		Type:     EndTagToken,
+		Data:     strings.ToLower(string(z.tagName)),
+		Position: z.tagPos,
+	})
+	return nil
+}
+
+func (z *Tokenizer) text(p []byte, pos Position) error {
+	z.pushText(TextToken, p, pos)
+	return nil
+}
+
+func (z *Tokenizer) rawText(p []byte, pos Position) error {
+	z.pushText(RawTextToken, p, pos)
+	return nil
+}
+
+func (z *Tokenizer) flush() error {
+	return nil
+}
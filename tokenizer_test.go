@@ -0,0 +1,167 @@
+package htmlsanitizer_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleTokenizer() {
+	// extract all the links
+	data := `<p>Visit <A HREF="https://example.com/">example</a>
+<!-- <a href="https://example.com/commented">x</a> -->
+<script>document.write('<a href="https://example.com/script">')</script></p>`
+
+	z := htmlsanitizer.NewTokenizer(strings.NewReader(data))
+	for {
+		t, err := z.Next()
+		if err != nil {
+			break
+		}
+
+		if t.Type != htmlsanitizer.StartTagToken || t.Data != "a" {
+			continue
+		}
+		for _, attr := range t.Attr {
+			if attr.Name == "href" {
+				fmt.Printf("%s at line %d, column %d\n", attr.Value, t.Line, t.Column)
+			}
+		}
+	}
+	// Output:
+	// https://example.com/ at line 1, column 10
+}
+
+func collectTokens(z *htmlsanitizer.Tokenizer) ([]htmlsanitizer.Token, error) {
+	var tokens []htmlsanitizer.Token
+	for {
+		t, err := z.Next()
+		if err != nil {
+			return tokens, err
+		}
+		tokens = append(tokens, t)
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	data := "a>b<P class=x\nHidden checked/><br/><!-- c --></p ><Style>p>a{}</b></STYLE>\n<span"
+	expected := []htmlsanitizer.Token{
+		{
+			Type:     htmlsanitizer.TextToken,
+			Data:     "a>b",
+			Position: htmlsanitizer.Position{Offset: 0, Line: 1, Column: 1},
+		},
+		{
+			Type: htmlsanitizer.StartTagToken,
+			Data: "p",
+			Attr: []htmlsanitizer.Attribute{
+				{Name: "class", Value: "x"},
+				{Name: "hidden", Bare: true},
+				{Name: "checked", Bare: true},
+			},
+			SelfClosing: true,
+			Position:    htmlsanitizer.Position{Offset: 3, Line: 1, Column: 4},
+		},
+		{
+			Type:        htmlsanitizer.StartTagToken,
+			Data:        "br",
+			SelfClosing: true,
+			Position:    htmlsanitizer.Position{Offset: 30, Line: 2, Column: 17},
+		},
+		{
+			Type:     htmlsanitizer.EndTagToken,
+			Data:     "p",
+			Position: htmlsanitizer.Position{Offset: 45, Line: 2, Column: 32},
+		},
+		{
+			Type:     htmlsanitizer.StartTagToken,
+			Data:     "style",
+			Position: htmlsanitizer.Position{Offset: 50, Line: 2, Column: 37},
+		},
+		{
+			Type:     htmlsanitizer.RawTextToken,
+			Data:     "p>a{}</b>",
+			Position: htmlsanitizer.Position{Offset: 57, Line: 2, Column: 44},
+		},
+		{
+			Type:     htmlsanitizer.EndTagToken,
+			Data:     "style",
+			Position: htmlsanitizer.Position{Offset: 66, Line: 2, Column: 53},
+		},
+		{
+			Type:     htmlsanitizer.TextToken,
+			Data:     "\n",
+			Position: htmlsanitizer.Position{Offset: 74, Line: 2, Column: 61},
+		},
+	}
+
+	readers := map[string]func() io.Reader{
+		"full": func() io.Reader { return strings.NewReader(data) },
+		"byte": func() io.Reader { return iotest.OneByteReader(strings.NewReader(data)) },
+		"half": func() io.Reader { return iotest.HalfReader(strings.NewReader(data)) },
+	}
+	for name, r := range readers {
+		tokens, err := collectTokens(htmlsanitizer.NewTokenizer(r()))
+		if err != io.EOF {
+			t.Errorf("%s: expect io.EOF, got %v", name, err)
+		}
+		if !reflect.DeepEqual(tokens, expected) {
+			t.Errorf("%s: expect %+v, got %+v", name, expected, tokens)
+		}
+	}
+}
+
+func TestTokenizerNames(t *testing.T) {
+	data := `<epub:Switch XML:lang="en" v_on.click=x data-a.b_c :bind/><x.y_z></x.y_z><script.x>a</script.x>`
+	expected := []string{
+		`StartTag epub:switch [{xml:lang en false} {v_on.click x false} {data-a.b_c  true} {:bind  true}]`,
+		`StartTag x.y_z []`,
+		`EndTag x.y_z []`,
+		`StartTag script.x []`,
+		`Text a []`,
+		`EndTag script.x []`,
+	}
+
+	tokens, err := collectTokens(htmlsanitizer.NewTokenizer(strings.NewReader(data)))
+	if err != io.EOF {
+		t.Errorf("expect io.EOF, got %v", err)
+	}
+	var ret []string
+	for _, token := range tokens {
+		ret = append(ret, fmt.Sprintf("%v %s %v", token.Type, token.Data, token.Attr))
+	}
+	if !reflect.DeepEqual(ret, expected) {
+		t.Errorf("expect %q, got %q", expected, ret)
+	}
+}
+
+func TestTokenizerLimits(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.Limits.MaxTagNameLen = 3
+
+	tokens, err := collectTokens(sanitizer.NewTokenizer(strings.NewReader("<p>x</p><abcd>")))
+	var limitErr *htmlsanitizer.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Kind != htmlsanitizer.LimitTagNameLen {
+		t.Errorf("expect a LimitError, got %v", err)
+	}
+	if len(tokens) != 3 {
+		t.Errorf("expect 3 tokens before the error, got %+v", tokens)
+	}
+}
+
+func TestTokenizerReaderError(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("text"), iotest.TimeoutReader(strings.NewReader("<p>")))
+	tokens, err := collectTokens(htmlsanitizer.NewTokenizer(iotest.OneByteReader(r)))
+	if err != iotest.ErrTimeout {
+		t.Errorf("expect ErrTimeout, got %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].Data != "text" {
+		t.Errorf("unexpected tokens %+v", tokens)
+	}
+}
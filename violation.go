@@ -0,0 +1,61 @@
+package htmlsanitizer
+
+import "fmt"
+
+// ViolationKind indicates why some content was removed.
+type ViolationKind int
+
+// All the kinds of violations.
+const (
+	// ViolationTag means a start tag is not allowed. The tag is removed,
+	// as well as its content if it's one of the NonHTMLTags.
+	ViolationTag ViolationKind = iota + 1
+
+	// ViolationAttr means an attribute is not allowed for the tag.
+	ViolationAttr
+
+	// ViolationURL means the value of a URL attribute is rejected by the
+	// URLSanitizer.
+	ViolationURL
+
+	// ViolationRequiredAttr means a required attribute of the tag is missing
+	// or not allowed. The tag is removed, as well as its end tag.
+	ViolationRequiredAttr
+)
+
+func (k ViolationKind) String() string {
+	switch k {
+	case ViolationTag:
+		return "tag not allowed"
+	case ViolationAttr:
+		return "attribute not allowed"
+	case ViolationURL:
+		return "URL not allowed"
+	case ViolationRequiredAttr:
+		return "required attribute missing"
+	}
+
+	return fmt.Sprintf("ViolationKind(%d)", int(k))
+}
+
+// Violation reports a piece of HTML content removed by the sanitizer.
+type Violation struct {
+	Kind ViolationKind
+
+	// Tag is the lowercase tag name.
+	Tag string
+
+	// Attr is the lowercase attribute name, empty for ViolationTag.
+	Attr string
+
+	// Position of the tag for ViolationTag and ViolationRequiredAttr, or
+	// position of the attribute for the others.
+	Position
+}
+
+func (v Violation) String() string {
+	if v.Attr == "" {
+		return fmt.Sprintf("%s: <%s> at line %d, column %d", v.Kind, v.Tag, v.Line, v.Column)
+	}
+	return fmt.Sprintf("%s: <%s %s> at line %d, column %d", v.Kind, v.Tag, v.Attr, v.Line, v.Column)
+}
@@ -0,0 +1,107 @@
+package htmlsanitizer_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/sym01/htmlsanitizer"
+)
+
+func ExampleHTMLSanitizer_OnViolation() {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.OnViolation = func(v htmlsanitizer.Violation) {
+		fmt.Println(v)
+	}
+
+	data := "<p>héllo\n<a href=\"javascript:alert(1)\" onclick=x>wörld</a>\n<script>alert(1)</script></p>"
+	_, _ = sanitizer.SanitizeString(data)
+	// Output:
+	// URL not allowed: <a href> at line 2, column 4
+	// attribute not allowed: <a onclick> at line 2, column 31
+	// tag not allowed: <script> at line 3, column 1
+}
+
+func TestViolationPosition(t *testing.T) {
+	data := "日本語<xx>\r\n\t<a\n  hreF='ftp://x' 中=1>\n<IMG SRC=\"\" onerror=x>"
+	expected := []htmlsanitizer.Violation{
+		{
+			Kind:     htmlsanitizer.ViolationTag,
+			Tag:      "xx",
+			Position: htmlsanitizer.Position{Offset: 9, Line: 1, Column: 4},
+		},
+		{
+			Kind:     htmlsanitizer.ViolationURL,
+			Tag:      "a",
+			Attr:     "href",
+			Position: htmlsanitizer.Position{Offset: 21, Line: 3, Column: 3},
+		},
+		{
+			Kind:     htmlsanitizer.ViolationAttr,
+			Tag:      "a",
+			Attr:     "中",
+			Position: htmlsanitizer.Position{Offset: 36, Line: 3, Column: 18},
+		},
+		{
+			Kind:     htmlsanitizer.ViolationAttr,
+			Tag:      "img",
+			Attr:     "onerror",
+			Position: htmlsanitizer.Position{Offset: 55, Line: 4, Column: 13},
+		},
+	}
+
+	// the result should be the same no matter how the data is split
+	for _, size := range []int{len(data), 1, 2, 5} {
+		var got []htmlsanitizer.Violation
+		sanitizer := htmlsanitizer.NewHTMLSanitizer()
+		sanitizer.OnViolation = func(v htmlsanitizer.Violation) {
+			got = append(got, v)
+		}
+
+		w := sanitizer.NewWriter(ioutil.Discard)
+		for i := 0; i < len(data); i += size {
+			end := i + size
+			if end > len(data) {
+				end = len(data)
+			}
+			if _, err := w.Write([]byte(data[i:end])); err != nil {
+				t.Errorf("unable to Write err: %s", err)
+				return
+			}
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("test failed for chunk size %d, expect %+v, got %+v", size, expected, got)
+		}
+	}
+}
+
+func TestLimitErrorRuneColumn(t *testing.T) {
+	sanitizer := htmlsanitizer.NewHTMLSanitizer()
+	sanitizer.Limits.MaxAttrsPerTag = 1
+
+	data := "ünïcödé\n→ <p a b>"
+	for _, size := range []int{len(data), 1, 3} {
+		w := sanitizer.NewWriter(ioutil.Discard)
+
+		var err error
+		for i := 0; i < len(data) && err == nil; i += size {
+			end := i + size
+			if end > len(data) {
+				end = len(data)
+			}
+			_, err = w.Write([]byte(data[i:end]))
+		}
+
+		limitErr, ok := err.(*htmlsanitizer.LimitError)
+		if !ok {
+			t.Errorf("expect a LimitError, got %v", err)
+			continue
+		}
+		expected := htmlsanitizer.Position{Offset: 21, Line: 2, Column: 8}
+		if limitErr.Position != expected {
+			t.Errorf("test failed for chunk size %d, expect %+v, got %+v", size, expected, limitErr.Position)
+		}
+	}
+}
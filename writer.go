@@ -0,0 +1,645 @@
+package htmlsanitizer
+
+import (
+	"bytes"
+	"html"
+	"io"
+)
+
+// writer is the sanitizing Writer, which handles the tokens from lexer,
+// and writes the allowed ones.
+type writer struct {
+	lexer
+	*HTMLSanitizer
+	w io.Writer
+
+	// allowed tags and attributes in the current namespace
+	allow lookup
+
+	// allowed tags and attributes in the HTML, the SVG and the MathML
+	// namespaces, and the current foreign namespace with the number of its
+	// open root elements
+	html      lookup
+	svgAllow  lookup
+	mathAllow lookup
+	ns        *foreignNamespace
+	nsDepth   int
+
+	// current start tag
+	tag *Tag
+
+	// buf for write
+	buf []byte
+
+	// tokens returned by the Hook
+	tokens []Token
+
+	// resource usage for limits
+	written int64
+
+	// names of the open non-void elements for the MaxDepth, the innermost
+	// last
+	open []string
+
+	// number of the open tags removed for missing required attributes, by
+	// the tag names, whose end tags are removed as well
+	dropped map[string]int
+
+	// document structure written in the document mode
+	docState docState
+
+	// output buffer for pooled writers
+	out appendWriter
+}
+
+// maxPooledBufSize is the max size of buffers kept by the pooled writers.
+const maxPooledBufSize = 64 << 10
+
+// appendWriter appends all the data written to itself.
+type appendWriter []byte
+
+func (a *appendWriter) Write(p []byte) (int, error) {
+	*a = append(*a, p...)
+	return len(p), nil
+}
+
+// getWriter returns a writer from the pool of f, which must be Reset before
+// use.
+func (f *HTMLSanitizer) getWriter() *writer {
+	if w, ok := f.pool.Get().(*writer); ok {
+		return w
+	}
+	return &writer{HTMLSanitizer: f}
+}
+
+// putWriter puts w back into the pool of f, unless its buffers are too
+// large to keep.
+func (f *HTMLSanitizer) putWriter(w *writer) {
+	w.w = nil
+	w.out = nil
+	if cap(w.buf) > maxPooledBufSize || cap(w.attrBuf) > maxPooledBufSize {
+		return
+	}
+	f.pool.Put(w)
+}
+
+// Reset discards the state of w, and makes it write the sanitized content to
+// dst. The latest Policy and Limits of the HTMLSanitizer are used.
+func (w *writer) Reset(dst io.Writer) {
+	w.w = dst
+	w.allow = w.lookup()
+	w.html = w.allow
+	w.svgAllow = w.allow.svg()
+	w.mathAllow = w.allow.math()
+	w.ns = nil
+	w.nsDepth = 0
+	w.tag = nil
+	w.buf = w.buf[:0]
+	w.tokens = w.tokens[:0]
+	w.written = 0
+	w.open = w.open[:0]
+	w.docState = docInitial
+	for name := range w.dropped {
+		delete(w.dropped, name)
+	}
+
+	trackPos := w.Limits != (Limits{}) || w.OnViolation != nil || w.Hook != nil
+	w.lexer.reset(w, w.Limits, trackPos)
+}
+
+func (w *writer) Write(p []byte) (n int, err error) {
+	return w.feed(p)
+}
+
+// Close writes the end of the document in the document mode. A tag not
+// completed is dropped.
+func (w *writer) Close() error {
+	if w.limitErr != nil {
+		return w.limitErr
+	}
+
+	if w.Document != nil {
+		w.closeDocument()
+	}
+	return w.flush()
+}
+
+// useTokens checks whether the tokens are written by writeToken, for the
+// Hook or the document mode.
+func (w *writer) useTokens() bool {
+	return w.Hook != nil || w.Document != nil
+}
+
+func (w *writer) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	if max := w.limits.MaxOutputBytes; max > 0 && w.written+int64(len(w.buf)) > max {
+		w.buf = w.buf[:0]
+		return w.limitError(LimitOutputBytes, max)
+	}
+
+	n, err := w.w.Write(w.buf)
+	w.written += int64(n)
+
+	// reset buf
+	w.buf = w.buf[:0]
+	return err
+}
+
+// attrEscapes maps the bytes to be escaped in attribute values to their
+// escaped form.
+var attrEscapes = [256]string{
+	'\'': `&#39;`,
+	'<':  `&lt;`,
+	'>':  `&gt;`,
+	'"':  `&#34;`,
+}
+
+// appendText appends the escaped text to buf.
+func (w *writer) appendText(p []byte) {
+	for len(p) > 0 {
+		// find the first '<' or '>'
+		i := bytes.IndexByte(p, '>')
+		if i < 0 {
+			i = len(p)
+		}
+		if j := bytes.IndexByte(p[:i], '<'); j >= 0 {
+			i = j
+		}
+
+		w.buf = append(w.buf, p[:i]...)
+		if i == len(p) {
+			return
+		}
+
+		w.buf = append(w.buf, attrEscapes[p[i]]...)
+		p = p[i+1:]
+	}
+}
+
+func (w *writer) safeAppend(p []byte) {
+	last := 0
+	for i, b := range p {
+		if esc := attrEscapes[b]; esc != "" {
+			w.buf = append(w.buf, p[last:i]...)
+			w.buf = append(w.buf, esc...)
+			last = i + 1
+		}
+	}
+
+	w.buf = append(w.buf, p[last:]...)
+}
+
+func (w *writer) report(kind ViolationKind, tagName string, attrName []byte, pos Position) {
+	if w.OnViolation == nil {
+		return
+	}
+
+	w.OnViolation(Violation{
+		Kind:     kind,
+		Tag:      tagName,
+		Attr:     string(attrName),
+		Position: pos,
+	})
+}
+
+// findTag finds the allowed tag in the current namespace. The svg and the
+// math elements start the foreign namespaces, in which their HTML
+// integration points are never allowed. In the document mode, the html,
+// head and body tags are always found.
+func (w *writer) findTag(name []byte) *Tag {
+	if w.ns != nil {
+		if equalLower(name, w.ns.integration) {
+			return nil
+		}
+		return w.allow.FindTag(name)
+	}
+
+	switch {
+	case w.svgAllow != nil && equalLower(name, svgNamespace.root):
+		return w.svgAllow.FindTag(name)
+	case w.mathAllow != nil && equalLower(name, mathNamespace.root):
+		return w.mathAllow.FindTag(name)
+	}
+	if tag := w.allow.FindTag(name); tag != nil || w.Document == nil {
+		return tag
+	}
+	return documentTag(name)
+}
+
+// namespaceOf returns the foreign namespace of tag, or nil for HTML.
+func (w *writer) namespaceOf(tag *Tag) *foreignNamespace {
+	switch {
+	case w.ns != nil:
+		return w.ns
+	case w.svgAllow != nil && tag.Name == svgNamespace.root:
+		return svgNamespace
+	case w.mathAllow != nil && tag.Name == mathNamespace.root:
+		return mathNamespace
+	}
+	return nil
+}
+
+// foreignAllow returns the allowed tags and attributes of ns.
+func (w *writer) foreignAllow(ns *foreignNamespace) lookup {
+	if ns == svgNamespace {
+		return w.svgAllow
+	}
+	return w.mathAllow
+}
+
+// attrValue returns the value of an attribute, which is unescaped if
+// escaped.
+func attrValue(val []byte, escaped bool) string {
+	if escaped {
+		return html.UnescapeString(string(val))
+	}
+	return string(val)
+}
+
+// sanitizeValue sanitizes the value of an attribute by sanitize. The
+// sanitized value is written as is, and unescaped again by the browsers, so
+// it must be acceptable after unescaped as well.
+func sanitizeValue(sanitize func(string) (string, bool), val []byte, escaped bool) (string, bool) {
+	sanitized, ok := sanitize(attrValue(val, escaped))
+	if ok {
+		if unescaped := html.UnescapeString(sanitized); unescaped != sanitized {
+			_, ok = sanitize(unescaped)
+		}
+	}
+	return sanitized, ok
+}
+
+// sanitizeAttr checks whether the attribute is allowed for tag, and returns
+// its sanitized value. The values of the URL attributes, and of the style
+// attributes if the StyleSanitizer is set, are unescaped first if escaped,
+// i.e. not from the tokens.
+func (w *writer) sanitizeAttr(tag *Tag, name, val []byte, bare, escaped bool, pos Position) ([]byte, bool) {
+	allow := w.allow
+	if ns := w.namespaceOf(tag); ns != nil {
+		allow = w.foreignAllow(ns)
+		if !foreignAttrAllowed(name, val) {
+			w.report(ViolationAttr, tag.Name, name, pos)
+			return nil, false
+		}
+	}
+
+	ok, urlAttr, pattern := allow.attrAllowed(tag, name)
+	if !ok {
+		w.report(ViolationAttr, tag.Name, name, pos)
+		return nil, false
+	}
+
+	if !urlAttr && w.StyleSanitizer != nil && string(name) == "style" {
+		style, ok := sanitizeValue(w.StyleSanitizer, val, escaped)
+		if ok && pattern != nil {
+			ok = pattern.MatchString(style)
+		}
+		if !ok {
+			w.report(ViolationAttr, tag.Name, name, pos)
+			return nil, false
+		}
+		return []byte(style), true
+	}
+
+	if !urlAttr {
+		if pattern != nil && !pattern.MatchString(html.UnescapeString(string(val))) {
+			w.report(ViolationAttr, tag.Name, name, pos)
+			return nil, false
+		}
+		return val, true
+	}
+
+	newURL, ok := sanitizeValue(w.urlSanitizer, val, escaped)
+	if ok && pattern != nil {
+		ok = pattern.MatchString(newURL)
+	}
+	if !ok {
+		w.report(ViolationURL, tag.Name, name, pos)
+		return nil, false
+	}
+	return []byte(newURL), true
+}
+
+// appendTagName appends the name of tag to buf, in the case of its foreign
+// namespace if any.
+func (w *writer) appendTagName(tag *Tag) {
+	if ns := w.namespaceOf(tag); ns != nil {
+		if name, ok := ns.tagNames[tag.Name]; ok {
+			w.buf = append(w.buf, name...)
+			return
+		}
+	}
+	w.buf = append(w.buf, tag.Name...)
+}
+
+// appendAttr appends the attribute with its sanitized value to buf, the name
+// is in the case of the foreign namespace ns if not nil.
+func (w *writer) appendAttr(name, val []byte, bare bool, ns *foreignNamespace) {
+	w.buf = append(w.buf, ' ')
+	if ns == nil {
+		w.buf = append(w.buf, name...)
+	} else if nsName, ok := ns.attrNames[string(name)]; ok {
+		w.buf = append(w.buf, nsName...)
+	} else {
+		w.buf = append(w.buf, name...)
+	}
+	if bare && len(val) == 0 {
+		return
+	}
+
+	w.buf = append(w.buf, `="`...)
+	w.safeAppend(val)
+	w.buf = append(w.buf, '"')
+}
+
+// requireMask returns the bits of name in the Require of tag.
+func requireMask(tag *Tag, name []byte) (mask uint64) {
+	for i, attr := range tag.Require {
+		if string(name) == attr {
+			mask |= 1 << uint(i)
+		}
+	}
+	return
+}
+
+// checkRequire checks whether all the required attributes of tag are found.
+// Otherwise the tag is reported, and its end tag will be removed.
+func (w *writer) checkRequire(tag *Tag, found uint64, selfClosing bool, pos Position) bool {
+	if found == uint64(1)<<uint(len(tag.Require))-1 {
+		return true
+	}
+
+	if w.OnViolation != nil {
+		for i, attr := range tag.Require {
+			if found&(1<<uint(i)) == 0 {
+				w.report(ViolationRequiredAttr, tag.Name, []byte(attr), pos)
+				break
+			}
+		}
+	}
+
+	if !selfClosing && !voidElements[tag.Name] {
+		if w.dropped == nil {
+			w.dropped = make(map[string]int)
+		}
+		w.dropped[tag.Name]++
+	}
+	return false
+}
+
+// droppedEndTag checks whether the end tag of tag is of a removed start
+// tag.
+func (w *writer) droppedEndTag(tag *Tag) bool {
+	if w.dropped[tag.Name] == 0 {
+		return false
+	}
+
+	w.dropped[tag.Name]--
+	return true
+}
+
+// openTag ends the start tag in buf, and checks the nesting depth.
+func (w *writer) openTag(tag *Tag, selfClosing bool) error {
+	if selfClosing {
+		w.buf = append(w.buf, ` />`...)
+		return nil
+	}
+
+	if max := w.limits.MaxDepth; max > 0 && !voidElements[tag.Name] {
+		w.open = append(w.open, tag.Name)
+		if len(w.open) > max {
+			w.buf = w.buf[:0]
+			return w.limitError(LimitDepth, int64(max))
+		}
+	}
+
+	// enter the foreign namespace
+	if ns := w.namespaceOf(tag); ns != nil && tag.Name == ns.root {
+		w.ns = ns
+		w.nsDepth++
+		w.allow = w.foreignAllow(ns)
+	}
+
+	w.buf = append(w.buf, '>')
+	return nil
+}
+
+// closeTag writes the end tag. Only the end tag of an open element reduces
+// the nesting depth, which closes the elements in it as well.
+func (w *writer) closeTag(tag *Tag) {
+	for i := len(w.open) - 1; i >= 0; i-- {
+		if w.open[i] == tag.Name {
+			w.open = w.open[:i]
+			break
+		}
+	}
+
+	w.buf = append(w.buf, `</`...)
+	w.appendTagName(tag)
+	w.buf = append(w.buf, '>')
+
+	// leave the foreign namespace
+	if w.ns != nil && tag.Name == w.ns.root {
+		if w.nsDepth--; w.nsDepth == 0 {
+			w.ns = nil
+			w.allow = w.html
+		}
+	}
+}
+
+func (w *writer) startTagName() bool {
+	w.tag = w.findTag(w.tagName)
+	return w.tag != nil
+}
+
+// rawTextElement always uses the NonHTMLTags of the HTML namespace, and the
+// content of them is removed in the foreign namespaces.
+func (w *writer) rawTextElement(name []byte) string {
+	if tag := w.html.checkNonHTMLTag(name); tag != nil {
+		return tag.Name
+	}
+	return ""
+}
+
+// startTag writes the current start tag with its allowed attributes.
+func (w *writer) startTag(selfClosing bool) error {
+	if w.tag == nil {
+		if w.OnViolation != nil {
+			w.report(ViolationTag, string(bytes.ToLower(w.tagName)), nil, w.tagPos)
+		}
+		return nil
+	}
+
+	if w.useTokens() {
+		t := Token{
+			Type:        StartTagToken,
+			Data:        w.tag.Name,
+			SelfClosing: selfClosing,
+			Position:    w.tagPos,
+		}
+		var found uint64
+		for _, a := range w.attrs {
+			name := w.attrBuf[a.start:a.nameEnd]
+			if val, ok := w.sanitizeAttr(w.tag, name, w.attrBuf[a.nameEnd:a.end], a.bare, true, a.pos); ok {
+				t.Attr = append(t.Attr, Attribute{Name: string(name), Value: string(val), Bare: a.bare})
+				found |= requireMask(w.tag, name)
+			}
+		}
+		if !w.checkRequire(w.tag, found, selfClosing, w.tagPos) {
+			return nil
+		}
+		return w.hook(t)
+	}
+
+	start := len(w.buf)
+	w.buf = append(w.buf, '<')
+	w.appendTagName(w.tag)
+	ns := w.namespaceOf(w.tag)
+	var found uint64
+	for _, a := range w.attrs {
+		name := w.attrBuf[a.start:a.nameEnd]
+		if val, ok := w.sanitizeAttr(w.tag, name, w.attrBuf[a.nameEnd:a.end], a.bare, true, a.pos); ok {
+			w.appendAttr(name, val, a.bare, ns)
+			found |= requireMask(w.tag, name)
+		}
+	}
+	if !w.checkRequire(w.tag, found, selfClosing, w.tagPos) {
+		w.buf = w.buf[:start]
+		return nil
+	}
+	return w.openTag(w.tag, selfClosing)
+}
+
+// endTag writes the current end tag if allowed.
+func (w *writer) endTag() error {
+	tag := w.findTag(w.tagName)
+	if tag == nil || w.droppedEndTag(tag) {
+		return nil
+	}
+
+	if w.useTokens() {
+		return w.hook(Token{Type: EndTagToken, Data: tag.Name, Position: w.tagPos})
+	}
+
+	w.closeTag(tag)
+	return nil
+}
+
+func (w *writer) text(p []byte, pos Position) error {
+	if len(p) == 0 {
+		return nil
+	}
+
+	if w.useTokens() {
+		return w.hook(Token{Type: TextToken, Data: string(p), Position: pos})
+	}
+
+	w.appendText(p)
+	return nil
+}
+
+// rawText writes the content of NonHTMLTags element only if the element is
+// allowed.
+func (w *writer) rawText(p []byte, pos Position) error {
+	if len(p) == 0 || w.tag == nil || w.tag.Name != w.rawTag || w.ns != nil {
+		return nil
+	}
+
+	if w.useTokens() {
+		return w.hook(Token{Type: RawTextToken, Data: string(p), Position: pos})
+	}
+
+	w.appendText(p)
+	return nil
+}
+
+// hook calls the Hook if any, and writes the returned tokens.
+func (w *writer) hook(t Token) error {
+	if w.Hook == nil {
+		return w.writeToken(t, t)
+	}
+
+	w.tokens = w.Hook.Transform(w.tokens[:0], t)
+	for _, out := range w.tokens {
+		if err := w.writeToken(out, t); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeToken validates the token returned by the Hook against the AllowList
+// again, and writes it.
+func (w *writer) writeToken(t, orig Token) error {
+	switch t.Type {
+	case StartTagToken, EndTagToken:
+		name := []byte(t.Data)
+		tag := w.findTag(name)
+
+		// do not let the hook open or close any NonHTMLTags, which changes
+		// how the browsers parse the following content. Only the original
+		// tag of the same type is kept.
+		if tag != nil && (t.Type != orig.Type || tag.Name != orig.Data) && w.html.checkNonHTMLTag(name) != nil {
+			tag = nil
+		}
+
+		if tag == nil {
+			if t.Type == StartTagToken {
+				w.report(ViolationTag, string(bytes.ToLower(name)), nil, t.Position)
+			}
+			return nil
+		}
+
+		if t.Type == EndTagToken {
+			if w.droppedEndTag(tag) || (w.Document != nil && !w.documentToken(tag, t)) {
+				return nil
+			}
+			w.closeTag(tag)
+			return nil
+		}
+
+		if w.Document != nil && !w.documentToken(tag, t) {
+			return nil
+		}
+
+		start := len(w.buf)
+		w.buf = append(w.buf, '<')
+		w.appendTagName(tag)
+		ns := w.namespaceOf(tag)
+		var found uint64
+		var doc documentAttrs
+		for _, a := range t.Attr {
+			name := bytes.ToLower([]byte(a.Name))
+			bare := a.Bare && len(a.Value) == 0
+			if val, ok := w.sanitizeAttr(tag, name, []byte(a.Value), bare, false, t.Position); ok {
+				w.appendAttr(name, val, bare, ns)
+				found |= requireMask(tag, name)
+				doc.add(name, val)
+			}
+		}
+		if !w.checkRequire(tag, found, t.SelfClosing, t.Position) {
+			w.buf = w.buf[:start]
+			return nil
+		}
+		if w.Document != nil && ns == nil && !doc.allowed(w.Document, tag) {
+			w.report(ViolationTag, tag.Name, nil, t.Position)
+			w.buf = w.buf[:start]
+			return nil
+		}
+		return w.openTag(tag, t.SelfClosing)
+
+	case TextToken:
+		if w.Document == nil || w.documentText([]byte(t.Data)) {
+			w.appendText([]byte(t.Data))
+		}
+
+	case RawTextToken:
+		w.appendText([]byte(t.Data))
+	}
+
+	return nil
+}